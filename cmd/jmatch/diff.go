@@ -0,0 +1,95 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rodic/jmatch/diff"
+)
+
+// arrayKeys is a flag that can be given many times, each an element
+// pattern and a key path joined by =.
+type arrayKeys map[string]string
+
+func (a arrayKeys) String() string {
+	var keys []string
+
+	for elements, key := range a {
+		keys = append(keys, elements+"="+key)
+	}
+	return strings.Join(keys, ",")
+}
+
+func (a arrayKeys) Set(value string) error {
+	elements, key, ok := strings.Cut(value, "=")
+
+	if !ok {
+		return fmt.Errorf("expected elements=key, got %q", value)
+	}
+	a[elements] = key
+	return nil
+}
+
+func runDiff(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("jmatch diff", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: jmatch diff [flags] left right")
+		flags.PrintDefaults()
+	}
+
+	keys := arrayKeys{}
+
+	flags.Var(keys, "key", "match the elements of arrays by a key, as in `.items[]=.id`, instead of by position")
+	patch := flags.Bool("patch", false, "print an RFC 6902 JSON Patch instead of a line per change")
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitMatch
+		}
+		return exitError
+	}
+
+	if flags.NArg() != 2 {
+		flags.Usage()
+		return exitError
+	}
+
+	var readers [2]io.ReadCloser
+
+	for i, file := range flags.Args() {
+		r, err := open(file, stdin)
+
+		if err != nil {
+			printErr(stderr, file, err)
+			return exitError
+		}
+		defer r.Close()
+		readers[i] = r
+	}
+
+	changes, err := diff.Diff(readers[0], readers[1], diff.Config{ArrayKeys: keys})
+
+	if err != nil {
+		fmt.Fprintf(stderr, "jmatch: %v\n", err)
+		return exitError
+	}
+
+	write := diff.WriteText
+
+	if *patch {
+		write = diff.WritePatch
+	}
+
+	if err := write(stdout, changes); err != nil {
+		fmt.Fprintf(stderr, "jmatch: %v\n", err)
+		return exitError
+	}
+
+	if len(changes) > 0 {
+		return exitNoMatch
+	}
+	return exitMatch
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// globs is a flag that can be given many times.
+type globs []string
+
+func (g *globs) String() string {
+	return strings.Join(*g, ",")
+}
+
+func (g *globs) Set(glob string) error {
+	if _, err := filepath.Match(glob, ""); err != nil {
+		return err
+	}
+	*g = append(*g, glob)
+	return nil
+}
+
+func (g globs) match(name string) bool {
+	for _, glob := range g {
+		if ok, _ := filepath.Match(glob, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// lister turns the arguments into the files to search.
+type lister struct {
+	recursive bool
+	include   globs // of files found in directories, by base name
+	exclude   globs // of files and directories found in directories
+}
+
+var errIsDirectory = errors.New("is a directory")
+
+// list calls visit for every file to search, in the order of the
+// arguments, along with the error that prevents searching it if any.
+func (l lister) list(args []string, visit func(file string, err error)) {
+	for _, arg := range args {
+		if arg == "-" {
+			visit(arg, nil)
+			continue
+		}
+
+		info, err := os.Stat(arg)
+
+		if err != nil {
+			visit(arg, err)
+		} else if !info.IsDir() {
+			visit(arg, nil)
+		} else if !l.recursive {
+			visit(arg, errIsDirectory)
+		} else {
+			l.walk(arg, visit)
+		}
+	}
+}
+
+func (l lister) walk(root string, visit func(file string, err error)) {
+	filepath.WalkDir(root, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			visit(path, err)
+			return nil
+		}
+
+		if path == root {
+			return nil
+		}
+
+		if entry.IsDir() {
+			if l.exclude.match(entry.Name()) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if l.selects(entry.Name()) {
+			visit(path, nil)
+		}
+		return nil
+	})
+}
+
+func (l lister) selects(name string) bool {
+	if len(l.include) > 0 && !l.include.match(name) {
+		return false
+	}
+	return !l.exclude.match(name)
+}
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (r readCloser) Close() error {
+	return r.close()
+}
+
+// open opens the file, or the standard input for -, and decompresses it on
+// the fly if it is gzipped.
+func open(file string, stdin io.Reader) (io.ReadCloser, error) {
+	var f io.ReadCloser = io.NopCloser(stdin)
+
+	if file != "-" {
+		var err error
+
+		if f, err = os.Open(file); err != nil {
+			return nil, err
+		}
+	}
+
+	buffered := bufio.NewReader(f)
+
+	if magic, _ := buffered.Peek(len(gzipMagic)); !bytes.Equal(magic, gzipMagic) {
+		return readCloser{Reader: buffered, close: f.Close}, nil
+	}
+
+	gz, err := gzip.NewReader(buffered)
+
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return readCloser{Reader: gz, close: func() error {
+		gz.Close()
+		return f.Close()
+	}}, nil
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/rodic/jmatch"
+	"github.com/rodic/jmatch/format"
+	"github.com/rodic/jmatch/paths"
+)
+
+// filter selects the values to print. Unset criteria select everything.
+type filter struct {
+	pattern *paths.Pattern
+	value   *string
+	regex   *regexp.Regexp
+	types   map[string]bool
+}
+
+var typeNames = []string{"string", "number", "boolean", "null"}
+
+func newFilter(pattern string, value *string, regex string, types string) (filter, error) {
+	var f filter
+
+	if pattern != "" {
+		p, err := paths.Compile(pattern)
+		if err != nil {
+			return f, err
+		}
+		f.pattern = &p
+	}
+
+	f.value = value
+
+	if regex != "" {
+		r, err := regexp.Compile(regex)
+		if err != nil {
+			return f, fmt.Errorf("invalid regex: %w", err)
+		}
+		f.regex = r
+	}
+
+	if types != "" {
+		f.types = map[string]bool{}
+
+		for _, name := range strings.Split(types, ",") {
+			name = strings.TrimSpace(name)
+
+			if name == "bool" {
+				name = "boolean"
+			}
+			if !isTypeName(name) {
+				return f, fmt.Errorf("invalid type %q, expected one of %s", name, strings.Join(typeNames, ", "))
+			}
+			f.types[name] = true
+		}
+	}
+
+	return f, nil
+}
+
+func isTypeName(name string) bool {
+	for _, n := range typeNames {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// matches tells whether the value passes the filter. Objects and arrays,
+// passed along for some formats, pass it only when values are not filtered.
+func (f filter) matches(path string, token jmatch.Token) bool {
+	if token.IsContainer() && (f.types != nil || f.value != nil || f.regex != nil) {
+		return false
+	}
+	if f.types != nil && !f.types[format.TypeName(token)] {
+		return false
+	}
+	if f.value != nil && token.Value != *f.value {
+		return false
+	}
+	if f.regex != nil && !f.regex.MatchString(token.Value) {
+		return false
+	}
+	if f.pattern != nil && !f.pattern.Match(path) {
+		return false
+	}
+	return true
+}
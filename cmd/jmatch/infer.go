@@ -0,0 +1,63 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/rodic/jmatch"
+	"github.com/rodic/jmatch/schema"
+)
+
+func runInfer(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("jmatch infer", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: jmatch infer [flags] [file ...]")
+		flags.PrintDefaults()
+	}
+
+	ndjson := flags.Bool("ndjson", false, "read a document per line")
+	maxEnum := flags.Int("max-enum", 10, "describe strings taking at most `n` distinct values, some repeated, by an enum, 0 to never do it")
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitMatch
+		}
+		return exitError
+	}
+
+	if *maxEnum < 0 {
+		fmt.Fprintf(stderr, "jmatch: invalid number of enum values %d\n", *maxEnum)
+		return exitError
+	}
+
+	files := flags.Args()
+
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	inferrer := schema.NewInferrer(schema.Config{MaxEnum: *maxEnum})
+	match := jmatch.Match
+
+	if *ndjson {
+		match = jmatch.MatchNDJSON
+	}
+
+	for _, file := range files {
+		r, err := open(file, stdin)
+
+		if err == nil {
+			err = match(r, inferrer.Add, jmatch.WithContainers())
+			r.Close()
+		}
+		if err != nil {
+			printErr(stderr, file, err)
+			return exitError
+		}
+	}
+
+	fmt.Fprintln(stdout, inferrer.Schema())
+	return exitMatch
+}
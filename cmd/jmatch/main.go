@@ -0,0 +1,390 @@
+// Command jmatch is grep for JSON. It prints the path and value of every
+// leaf of the input that passes the filters given:
+//
+//	jmatch [flags] [file ...]
+//
+// Paths are written in jq notation so they can be pasted into jq. The
+// output format is picked with --output, see the format package. With no
+// file, or when file is -, the standard input is read. Directories are
+// searched with -r, gzipped files are decompressed on the fly and files
+// are searched in parallel, their values printed in the order of the
+// files. With --context, the object or array around every value found is
+// printed instead, as grep -C prints the lines around matches, and with
+// --count, --count-by-path and --stats a summary of the values. Output to a
+// terminal is colored, see --color. The exit status is 0 if a value was
+// printed, 1 if none was and 2 if an error occurred.
+//
+// The ungron subcommand does the opposite, it reads the lines printed in
+// the tsv or gron formats and prints the JSON document they describe:
+//
+//	jmatch ungron [file ...]
+//
+// The infer subcommand prints a JSON Schema describing the documents read,
+// or the records of NDJSON input with --ndjson:
+//
+//	jmatch infer [flags] [file ...]
+//
+// The validate subcommand checks the documents read against a JSON Schema
+// as it streams through them, printing a line per violation. It exits
+// with 0 if the documents are valid and 1 if they are not:
+//
+//	jmatch validate --schema file [flags] [file ...]
+//
+// The project subcommand prints every document read cut down to the
+// values at paths matching the patterns given, keeping their nesting:
+//
+//	jmatch project --keep pattern [flags] [file ...]
+//
+// The diff subcommand prints the values added, removed and changed
+// between two documents, or a JSON Patch with --patch. Like diff, it exits
+// with 0 if the documents are the same and 1 if they differ:
+//
+//	jmatch diff [flags] left right
+//
+// The patch subcommand applies a JSON Patch, or a JSON Merge Patch with
+// --merge, to the documents read:
+//
+//	jmatch patch [flags] patch [file ...]
+//
+// The fmt subcommand pretty-prints the documents read, or minifies them
+// with --compact:
+//
+//	jmatch fmt [flags] [file ...]
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+
+	"github.com/rodic/jmatch"
+	c "github.com/rodic/jmatch/common"
+	"github.com/rodic/jmatch/format"
+)
+
+const (
+	exitMatch   = 0
+	exitNoMatch = 1
+	exitError   = 2
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// optionalString is a string flag that tells whether it was set, so that
+// an empty value can be looked for.
+type optionalString struct {
+	value *string
+}
+
+func (o *optionalString) String() string {
+	if o.value == nil {
+		return ""
+	}
+	return *o.value
+}
+
+func (o *optionalString) Set(value string) error {
+	o.value = &value
+	return nil
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	if len(args) > 0 && args[0] == "ungron" {
+		return runUngron(args[1:], stdin, stdout, stderr)
+	}
+	if len(args) > 0 && args[0] == "infer" {
+		return runInfer(args[1:], stdin, stdout, stderr)
+	}
+	if len(args) > 0 && args[0] == "validate" {
+		return runValidate(args[1:], stdin, stdout, stderr)
+	}
+	if len(args) > 0 && args[0] == "project" {
+		return runProject(args[1:], stdin, stdout, stderr)
+	}
+	if len(args) > 0 && args[0] == "diff" {
+		return runDiff(args[1:], stdin, stdout, stderr)
+	}
+	if len(args) > 0 && args[0] == "patch" {
+		return runPatch(args[1:], stdin, stdout, stderr)
+	}
+	if len(args) > 0 && args[0] == "fmt" {
+		return runFormat(args[1:], stdin, stdout, stderr)
+	}
+
+	flags := flag.NewFlagSet("jmatch", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: jmatch [flags] [file ...]")
+		fmt.Fprintln(stderr, "       jmatch ungron [file ...]")
+		fmt.Fprintln(stderr, "       jmatch infer [flags] [file ...]")
+		fmt.Fprintln(stderr, "       jmatch validate --schema file [flags] [file ...]")
+		fmt.Fprintln(stderr, "       jmatch project --keep pattern [flags] [file ...]")
+		fmt.Fprintln(stderr, "       jmatch diff [flags] left right")
+		fmt.Fprintln(stderr, "       jmatch patch [flags] patch [file ...]")
+		fmt.Fprintln(stderr, "       jmatch fmt [flags] [file ...]")
+		flags.PrintDefaults()
+	}
+
+	var value optionalString
+
+	pattern := flags.String("path", "", "print only values whose path matches the `pattern`, e.g. .users[*].name or ..id")
+	flags.Var(&value, "value", "print only values equal to `value`, strings compared unquoted")
+	regex := flags.String("regex", "", "print only values matching the regular `expression`")
+	types := flags.String("type", "", "print only values of the comma separated `types`: string, number, boolean, null")
+	output := flags.String("output", "tsv", "print in the `format` given: "+strings.Join(format.Names(), ", "))
+
+	var lister lister
+
+	flags.BoolVar(&lister.recursive, "r", false, "search the files in the directories given, recursively")
+	flags.Var(&lister.include, "include", "search only files found in directories whose name matches the `glob`, may be repeated")
+	flags.Var(&lister.exclude, "exclude", "skip files and directories found in directories whose name matches the `glob`, may be repeated")
+	withFilename := flags.Bool("H", false, "print the file name of every value, the default when searching more than one file")
+	noFilename := flags.Bool("no-filename", false, "never print file names")
+	workers := flags.Int("jobs", runtime.NumCPU(), "search `n` files at once")
+	levels := flags.Int("context", 0, "print the object or array `n` levels up from every value instead, as indented JSON, 1 being the one the value is in")
+	flags.IntVar(levels, "C", 0, "short for --context")
+	prunedRoot := flags.Bool("pruned-root", false, "with --context, print the root holding only the values found in it and the keys leading to them, so that it is not kept in memory")
+	count := flags.Bool("count", false, "print the number of values found instead, per file when file names are printed")
+	countByPath := flags.Bool("count-by-path", false, "print the number of values found per path instead, indexes left out")
+	stats := flags.Bool("stats", false, "print a summary of the values found per path instead: types, nulls, numbers range and strings length")
+	color := flags.String("color", "auto", "color the output: `when` is auto, always or never, auto coloring it on terminals unless NO_COLOR is set")
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitMatch
+		}
+		return exitError
+	}
+
+	filter, err := newFilter(*pattern, value.value, *regex, *types)
+
+	if err != nil {
+		fmt.Fprintf(stderr, "jmatch: %v\n", err)
+		return exitError
+	}
+
+	colored, err := useColor(*color, stdout)
+
+	if err != nil {
+		fmt.Fprintf(stderr, "jmatch: %v\n", err)
+		return exitError
+	}
+
+	if *workers < 1 {
+		fmt.Fprintf(stderr, "jmatch: invalid number of jobs %d\n", *workers)
+		return exitError
+	}
+
+	if *levels < 0 {
+		fmt.Fprintf(stderr, "jmatch: invalid number of context levels %d\n", *levels)
+		return exitError
+	}
+
+	if *prunedRoot && *levels == 0 {
+		fmt.Fprintln(stderr, "jmatch: --pruned-root can only be used with --context")
+		return exitError
+	}
+
+	modes := 0
+
+	for _, on := range []bool{*levels > 0, *count, *countByPath, *stats} {
+		if on {
+			modes++
+		}
+	}
+
+	if modes > 1 {
+		fmt.Fprintln(stderr, "jmatch: only one of --context, --count, --count-by-path and --stats can be used")
+		return exitError
+	}
+
+	if modes > 0 && *output != "tsv" {
+		fmt.Fprintln(stderr, "jmatch: --output can't be used with --context, --count, --count-by-path or --stats")
+		return exitError
+	}
+
+	out := bufio.NewWriter(stdout)
+	defer out.Flush()
+
+	var writer printer
+	var inner any // the writer behind the output
+
+	if *levels > 0 {
+		contexts := format.NewContext(out)
+		writer, inner = contextPrinter{contexts}, contexts
+	} else {
+		var values format.Writer
+
+		switch {
+		case *count:
+			values = format.NewCount(out)
+		case *countByPath:
+			values = format.NewPathCount(out)
+		case *stats:
+			values = format.NewStats(out)
+		default:
+			values, err = format.New(*output, out)
+		}
+
+		if err != nil {
+			fmt.Fprintf(stderr, "jmatch: %v, expected one of %s\n", err, strings.Join(format.Names(), ", "))
+			return exitError
+		}
+		writer, inner = valuePrinter{values}, values
+	}
+
+	if colorWriter, ok := inner.(colorer); ok && colored {
+		var highlight format.Highlighter
+
+		if filter.regex != nil {
+			highlight = func(value string) [][]int {
+				return filter.regex.FindAllStringIndex(value, -1)
+			}
+		}
+		colorWriter.SetColors(format.DefaultColors, highlight)
+	}
+
+	// containers are listed so that empty ones can be rebuilt
+	var opts []jmatch.Option
+
+	if *output == "gron" || *output == "document" {
+		opts = append(opts, jmatch.WithContainers())
+	}
+	if *prunedRoot {
+		opts = append(opts, jmatch.WithPrunedRoot())
+	}
+
+	files := flags.Args()
+
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	fileWriter, canName := inner.(fileNamer)
+	showFile := canName && !*noFilename && (*withFilename || lister.recursive || len(files) > 1)
+
+	searcher := searcher{
+		filter:  filter,
+		opts:    opts,
+		stdin:   stdin,
+		workers: *workers,
+		levels:  *levels,
+	}
+
+	status := exitNoMatch
+	failed := false
+	var writeErr error
+
+	list := func(visit func(file string, err error)) {
+		lister.list(files, visit)
+	}
+
+	searcher.run(list, func(j *job) {
+		if showFile {
+			fileWriter.SetFile(displayName(j.file))
+		}
+
+		for m := range j.results {
+			if m.context != nil || !isClosing(m.token) {
+				status = exitMatch
+			}
+			if writeErr == nil {
+				writeErr = writer.write(m)
+			}
+		}
+
+		if j.err != nil {
+			writer.Flush()
+			out.Flush()
+			printErr(stderr, j.file, j.err)
+			failed = true
+		}
+	})
+
+	if writeErr == nil {
+		writeErr = writer.Flush()
+	}
+	if writeErr != nil {
+		fmt.Fprintf(stderr, "jmatch: %v\n", writeErr)
+		failed = true
+	}
+
+	if failed {
+		return exitError
+	}
+	return status
+}
+
+func runUngron(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("jmatch ungron", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: jmatch ungron [file ...]")
+	}
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitMatch
+		}
+		return exitError
+	}
+
+	files := flags.Args()
+
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	readers := make([]io.Reader, 0, len(files))
+
+	for _, file := range files {
+		if file == "-" {
+			readers = append(readers, stdin)
+			continue
+		}
+
+		f, err := os.Open(file)
+		if err != nil {
+			fmt.Fprintf(stderr, "jmatch: %v\n", err)
+			return exitError
+		}
+		defer f.Close()
+		readers = append(readers, f)
+	}
+
+	if err := format.Ungron(io.MultiReader(readers...), stdout); err != nil {
+		fmt.Fprintf(stderr, "jmatch: %v\n", err)
+		return exitError
+	}
+	return exitMatch
+}
+
+func displayName(file string) string {
+	if file == "-" {
+		return "(standard input)"
+	}
+	return file
+}
+
+// printErr reports the error found in the file, followed by the part of
+// the input where it is when the error points at a token.
+func printErr(stderr io.Writer, file string, err error) {
+	fmt.Fprintf(stderr, "jmatch: %s: %v\n", displayName(file), err)
+
+	var unexpected c.UnexpectedTokenErr
+
+	if errors.As(err, &unexpected) && unexpected.Excerpt != "" {
+		fmt.Fprintln(stderr, unexpected.Excerpt)
+	}
+}
+
+func isClosing(token jmatch.Token) bool {
+	return token.IsRightBrace() || token.IsRightBracket()
+}
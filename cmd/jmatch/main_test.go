@@ -0,0 +1,706 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const users = `{"users": [{"name": "ann", "age": 31, "admin": true}, {"name": "bob", "age": 4, "nick": null}]}`
+
+func TestRun(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		input    string
+		expected string
+		status   int
+	}{
+		{name: "all",
+			args:     []string{},
+			input:    `{"a": [1, "x\ty"], "b c": false}`,
+			expected: ".a[0]\t1\n.a[1]\t\"x\\ty\"\n.\"b c\"\tfalse\n",
+			status:   exitMatch},
+		{name: "path",
+			args:     []string{"--path", ".users[*].name"},
+			input:    users,
+			expected: ".users[0].name\t\"ann\"\n.users[1].name\t\"bob\"\n",
+			status:   exitMatch},
+		{name: "recursivePath",
+			args:     []string{"-path", "..age"},
+			input:    users,
+			expected: ".users[0].age\t31\n.users[1].age\t4\n",
+			status:   exitMatch},
+		{name: "value",
+			args:     []string{"--value", "bob"},
+			input:    users,
+			expected: ".users[1].name\t\"bob\"\n",
+			status:   exitMatch},
+		{name: "regex",
+			args:     []string{"--regex", "^a"},
+			input:    users,
+			expected: ".users[0].name\t\"ann\"\n",
+			status:   exitMatch},
+		{name: "type",
+			args:     []string{"--type", "boolean,null"},
+			input:    users,
+			expected: ".users[0].admin\ttrue\n.users[1].nick\tnull\n",
+			status:   exitMatch},
+		{name: "combined",
+			args:     []string{"--type", "number", "--path", ".users[1]..", "--regex", "^4$"},
+			input:    users,
+			expected: ".users[1].age\t4\n",
+			status:   exitMatch},
+		{name: "noMatch",
+			args:     []string{"--value", "carl"},
+			input:    users,
+			expected: "",
+			status:   exitNoMatch},
+		{name: "gron",
+			args:     []string{"--output", "gron", "--path", ".users[1].."},
+			input:    users,
+			expected: "json.users[1] = {};\njson.users[1].name = \"bob\";\njson.users[1].age = 4;\njson.users[1].nick = null;\n",
+			status:   exitMatch},
+		{name: "gronFilteredValues",
+			args:     []string{"--output", "gron", "--type", "number"},
+			input:    users,
+			expected: "json.users[0].age = 31;\njson.users[1].age = 4;\n",
+			status:   exitMatch},
+		{name: "json",
+			args:     []string{"--output", "json", "--value", "bob"},
+			input:    users,
+			expected: "{\"path\":\".users[1].name\",\"type\":\"string\",\"value\":\"bob\",\"line\":1,\"col\":64}\n",
+			status:   exitMatch},
+		{name: "csv",
+			args:     []string{"--output", "csv", "--path", "..age"},
+			input:    users,
+			expected: "path,type,value,line,col\n.users[0].age,number,31,1,35\n.users[1].age,number,4,1,78\n",
+			status:   exitMatch},
+		{name: "values",
+			args:     []string{"--output", "values", "--path", "..name"},
+			input:    users,
+			expected: "ann\nbob\n",
+			status:   exitMatch},
+		{name: "invalidOutput",
+			args:     []string{"--output", "xml"},
+			input:    users,
+			expected: "",
+			status:   exitError},
+		{name: "invalidJSON",
+			args:     []string{},
+			input:    `{"a": 1,`,
+			expected: ".a\t1\n",
+			status:   exitError},
+		{name: "invalidPattern",
+			args:     []string{"--path", "users"},
+			input:    users,
+			expected: "",
+			status:   exitError},
+		{name: "invalidType",
+			args:     []string{"--type", "object"},
+			input:    users,
+			expected: "",
+			status:   exitError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			status := run(tc.args, strings.NewReader(tc.input), &stdout, &stderr)
+
+			if status != tc.status {
+				t.Errorf("Expected status %d, got %d instead, stderr: %s\n", tc.status, status, stderr.String())
+			}
+			if stdout.String() != tc.expected {
+				t.Errorf("Expected '%s', got '%s' instead\n", tc.expected, stdout.String())
+			}
+		})
+	}
+}
+
+func TestRunErrorExcerpt(t *testing.T) {
+	for _, args := range [][]string{{}, {"fmt"}} {
+		var stdout, stderr bytes.Buffer
+
+		status := run(args, strings.NewReader("{\"a\": 1,\n  \"b\" 2}"), &stdout, &stderr)
+
+		if status != exitError {
+			t.Errorf("Expected status %d, got %d instead\n", exitError, status)
+		}
+		if expected := "\n2 |   \"b\" 2}\n  |       ^\n"; !strings.HasSuffix(stderr.String(), expected) {
+			t.Errorf("Expected '%s' at the end, got '%s' instead\n", expected, stderr.String())
+		}
+	}
+}
+
+func TestRunFiles(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "users.json")
+
+	if err := os.WriteFile(file, []byte(users), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var stdout, stderr bytes.Buffer
+
+	status := run([]string{"--value", "ann", file, "-"}, strings.NewReader(`["ann"]`), &stdout, &stderr)
+
+	if status != exitMatch {
+		t.Errorf("Expected status %d, got %d instead, stderr: %s\n", exitMatch, status, stderr.String())
+	}
+
+	expected := file + ":.users[0].name\t\"ann\"\n(standard input):.[0]\t\"ann\"\n"
+
+	if stdout.String() != expected {
+		t.Errorf("Expected '%s', got '%s' instead\n", expected, stdout.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+
+	status = run([]string{"--no-filename", "--value", "ann", file, "-"}, strings.NewReader(`["ann"]`), &stdout, &stderr)
+
+	if status != exitMatch {
+		t.Errorf("Expected status %d, got %d instead, stderr: %s\n", exitMatch, status, stderr.String())
+	}
+
+	expected = ".users[0].name\t\"ann\"\n.[0]\t\"ann\"\n"
+
+	if stdout.String() != expected {
+		t.Errorf("Expected '%s', got '%s' instead\n", expected, stdout.String())
+	}
+
+	stdout.Reset()
+	stderr.Reset()
+
+	status = run([]string{filepath.Join(dir, "missing.json"), file}, nil, &stdout, &stderr)
+
+	if status != exitError {
+		t.Errorf("Expected status %d, got %d instead\n", exitError, status)
+	}
+	if !strings.HasPrefix(stderr.String(), "jmatch: "+filepath.Join(dir, "missing.json")) {
+		t.Errorf("Expected the missing file reported, got '%s' instead\n", stderr.String())
+	}
+}
+
+func TestRunDirectories(t *testing.T) {
+	dir := t.TempDir()
+
+	write := func(name string, content string, compress bool) {
+		file := filepath.Join(dir, name)
+
+		if err := os.MkdirAll(filepath.Dir(file), 0o755); err != nil {
+			t.Fatal(err)
+		}
+
+		var data bytes.Buffer
+
+		if compress {
+			gz := gzip.NewWriter(&data)
+			gz.Write([]byte(content))
+			gz.Close()
+		} else {
+			data.WriteString(content)
+		}
+
+		if err := os.WriteFile(file, data.Bytes(), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write("a.json", `{"id": 1}`, false)
+	write("b/c.json.gz", `{"id": 2}`, true)
+	write("b/d.txt", `{"id": 3}`, false)
+	write("e/f.json", `{"id": 4}`, false)
+
+	name := func(file string) string {
+		return filepath.Join(dir, file)
+	}
+
+	testCases := []struct {
+		name     string
+		args     []string
+		expected string
+		status   int
+	}{
+		{name: "recursive",
+			args: []string{"-r", dir},
+			expected: name("a.json") + ":.id\t1\n" +
+				name("b/c.json.gz") + ":.id\t2\n" +
+				name("b/d.txt") + ":.id\t3\n" +
+				name("e/f.json") + ":.id\t4\n",
+			status: exitMatch},
+		{name: "include",
+			args: []string{"-r", "--include", "*.json", "--include", "*.gz", dir},
+			expected: name("a.json") + ":.id\t1\n" +
+				name("b/c.json.gz") + ":.id\t2\n" +
+				name("e/f.json") + ":.id\t4\n",
+			status: exitMatch},
+		{name: "exclude",
+			args:     []string{"-r", "--exclude", "b", "--exclude", "a.*", dir},
+			expected: name("e/f.json") + ":.id\t4\n",
+			status:   exitMatch},
+		{name: "oneJob",
+			args:     []string{"-r", "--jobs", "1", "--no-filename", dir},
+			expected: ".id\t1\n.id\t2\n.id\t3\n.id\t4\n",
+			status:   exitMatch},
+		{name: "withFilename",
+			args:     []string{"-H", name("b/c.json.gz")},
+			expected: name("b/c.json.gz") + ":.id\t2\n",
+			status:   exitMatch},
+		{name: "ndjson",
+			args:     []string{"-H", "--output", "json", name("a.json")},
+			expected: `{"file":"` + name("a.json") + `","path":".id","type":"number","value":1,"line":1,"col":8}` + "\n",
+			status:   exitMatch},
+		{name: "directoryWithoutRecursion",
+			args:     []string{dir},
+			expected: "",
+			status:   exitError},
+		{name: "invalidGlob",
+			args:     []string{"-r", "--include", "[", dir},
+			expected: "",
+			status:   exitError},
+		{name: "invalidJobs",
+			args:     []string{"--jobs", "0", dir},
+			expected: "",
+			status:   exitError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			status := run(tc.args, nil, &stdout, &stderr)
+
+			if status != tc.status {
+				t.Errorf("Expected status %d, got %d instead, stderr: %s\n", tc.status, status, stderr.String())
+			}
+			if stdout.String() != tc.expected {
+				t.Errorf("Expected '%s', got '%s' instead\n", tc.expected, stdout.String())
+			}
+		})
+	}
+}
+
+func TestRunColor(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		noColor  string
+		expected string
+		status   int
+	}{
+		{name: "always",
+			args:     []string{"--color", "always", "--regex", "nn", "--path", ".users[0].name"},
+			expected: ".\x1b[1;34musers\x1b[0m[\x1b[34m0\x1b[0m].\x1b[1;34mname\x1b[0m\t\x1b[32m\"\x1b[0m\x1b[32ma\x1b[0m\x1b[1;31mnn\x1b[0m\x1b[32m\"\x1b[0m\n",
+			status:   exitMatch},
+		{name: "alwaysDespiteNoColor",
+			args:     []string{"--color", "always", "--output", "values", "--path", ".users[1].age"},
+			noColor:  "1",
+			expected: "\x1b[33m4\x1b[0m\n",
+			status:   exitMatch},
+		{name: "never",
+			args:     []string{"--color", "never", "--path", ".users[1].age"},
+			expected: ".users[1].age\t4\n",
+			status:   exitMatch},
+		{name: "autoNotTerminal",
+			args:     []string{"--path", ".users[1].age"},
+			expected: ".users[1].age\t4\n",
+			status:   exitMatch},
+		{name: "machineReadable",
+			args:     []string{"--color", "always", "--output", "csv", "--path", ".users[1].age"},
+			expected: "path,type,value,line,col\n.users[1].age,number,4,1,78\n",
+			status:   exitMatch},
+		{name: "invalid",
+			args:     []string{"--color", "sometimes"},
+			expected: "",
+			status:   exitError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", tc.noColor)
+
+			var stdout, stderr bytes.Buffer
+
+			status := run(tc.args, strings.NewReader(users), &stdout, &stderr)
+
+			if status != tc.status {
+				t.Errorf("Expected status %d, got %d instead, stderr: %s\n", tc.status, status, stderr.String())
+			}
+			if stdout.String() != tc.expected {
+				t.Errorf("Expected %q, got %q instead\n", tc.expected, stdout.String())
+			}
+		})
+	}
+}
+
+func TestRunModes(t *testing.T) {
+	testCases := []struct {
+		name     string
+		args     []string
+		expected string
+		status   int
+	}{
+		{name: "enclosingObject",
+			args:     []string{"--context", "1", "--value", "bob"},
+			expected: ".users[1]\n{\n  \"name\": \"bob\",\n  \"age\": 4,\n  \"nick\": null\n}\n",
+			status:   exitMatch},
+		{name: "matchesMerged",
+			args:     []string{"-C", "1", "--path", ".users[1].*"},
+			expected: ".users[1]\n{\n  \"name\": \"bob\",\n  \"age\": 4,\n  \"nick\": null\n}\n",
+			status:   exitMatch},
+		{name: "twoLevels",
+			args:     []string{"-C", "2", "--value", "ann"},
+			expected: ".users\n[\n  {\n    \"name\": \"ann\",\n    \"age\": 31,\n    \"admin\": true\n  },\n  {\n    \"name\": \"bob\",\n    \"age\": 4,\n    \"nick\": null\n  }\n]\n",
+			status:   exitMatch},
+		{name: "prunedRoot",
+			args:     []string{"-C", "3", "--value", "bob", "--pruned-root"},
+			expected: ".\n{\n  \"users\": [\n    {\n      \"name\": \"bob\"\n    }\n  ]\n}\n",
+			status:   exitMatch},
+		{name: "prunedRootAlone",
+			args:     []string{"--pruned-root"},
+			expected: "",
+			status:   exitError},
+		{name: "noMatch",
+			args:     []string{"-C", "1", "--value", "cid"},
+			expected: "",
+			status:   exitNoMatch},
+		{name: "withOutput",
+			args:     []string{"-C", "1", "--output", "json"},
+			expected: "",
+			status:   exitError},
+		{name: "negative",
+			args:     []string{"-C", "-1"},
+			expected: "",
+			status:   exitError},
+		{name: "count",
+			args:     []string{"--count", "--type", "number"},
+			expected: "2\n",
+			status:   exitMatch},
+		{name: "countNone",
+			args:     []string{"--count", "--value", "cid"},
+			expected: "0\n",
+			status:   exitNoMatch},
+		{name: "countByPath",
+			args:     []string{"--count-by-path"},
+			expected: ".users[].name\t2\n.users[].age\t2\n.users[].admin\t1\n.users[].nick\t1\n",
+			status:   exitMatch},
+		{name: "stats",
+			args:     []string{"--stats", "--path", ".users[*].age"},
+			expected: `{"path":".users[].age","count":2,"types":{"number":2},"null_ratio":0,"min":4,"max":31}` + "\n",
+			status:   exitMatch},
+		{name: "twoModes",
+			args:     []string{"--count", "--stats"},
+			expected: "",
+			status:   exitError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			status := run(tc.args, strings.NewReader(users), &stdout, &stderr)
+
+			if status != tc.status {
+				t.Errorf("Expected status %d, got %d instead, stderr: %s\n", tc.status, status, stderr.String())
+			}
+			if stdout.String() != tc.expected {
+				t.Errorf("Expected '%s', got '%s' instead\n", tc.expected, stdout.String())
+			}
+		})
+	}
+}
+
+func TestRunUngron(t *testing.T) {
+	var flattened, stdout, stderr bytes.Buffer
+
+	if status := run([]string{"--output", "gron", "--path", ".users[0].."}, strings.NewReader(users), &flattened, &stderr); status != exitMatch {
+		t.Fatalf("Expected status %d, got %d instead, stderr: %s\n", exitMatch, status, stderr.String())
+	}
+
+	status := run([]string{"ungron"}, &flattened, &stdout, &stderr)
+
+	if status != exitMatch {
+		t.Errorf("Expected status %d, got %d instead, stderr: %s\n", exitMatch, status, stderr.String())
+	}
+
+	expected := "{\n  \"users\": [\n    {\n      \"name\": \"ann\",\n      \"age\": 31,\n      \"admin\": true\n    }\n  ]\n}\n"
+
+	if stdout.String() != expected {
+		t.Errorf("Expected '%s', got '%s' instead\n", expected, stdout.String())
+	}
+
+	if status := run([]string{"ungron"}, strings.NewReader("not a line"), &stdout, &stderr); status != exitError {
+		t.Errorf("Expected status %d, got %d instead\n", exitError, status)
+	}
+}
+
+func TestRunInfer(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	status := run([]string{"infer", "--ndjson"}, strings.NewReader("{\"a\": 1}\n{\"a\": 2, \"b\": \"x\"}\n"), &stdout, &stderr)
+
+	if status != exitMatch {
+		t.Errorf("Expected status %d, got %d instead, stderr: %s\n", exitMatch, status, stderr.String())
+	}
+
+	expected := `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "properties": {
+    "a": {
+      "type": "integer",
+      "minimum": 1,
+      "maximum": 2
+    },
+    "b": {
+      "type": "string"
+    }
+  },
+  "required": [
+    "a"
+  ]
+}
+`
+
+	if stdout.String() != expected {
+		t.Errorf("Expected '%s', got '%s' instead\n", expected, stdout.String())
+	}
+
+	stdout.Reset()
+
+	if status := run([]string{"infer", filepath.Join(t.TempDir(), "missing.json")}, nil, &stdout, &stderr); status != exitError {
+		t.Errorf("Expected status %d for a missing file, got %d instead\n", exitError, status)
+	}
+}
+
+func TestRunValidate(t *testing.T) {
+	dir := t.TempDir()
+	schemaFile := filepath.Join(dir, "schema.json")
+
+	if err := os.WriteFile(schemaFile, []byte(`{"properties": {"age": {"type": "integer", "minimum": 18}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		args     []string
+		input    string
+		expected string
+		status   int
+	}{
+		{name: "valid",
+			args:     []string{"validate", "--schema", schemaFile},
+			input:    `{"age": 31}`,
+			expected: "",
+			status:   exitMatch},
+		{name: "invalid",
+			args:     []string{"validate", "--schema", schemaFile, "--ndjson"},
+			input:    "{\"age\": 31}\n{\"age\": 4.5}\n",
+			expected: "(standard input):2:9: .age: expected integer, got number\n(standard input):2:9: .age: 4.5 is less than the minimum 18\n",
+			status:   exitNoMatch},
+		{name: "noSchema",
+			args:     []string{"validate"},
+			input:    `{}`,
+			expected: "",
+			status:   exitError},
+		{name: "brokenDocument",
+			args:     []string{"validate", "--schema", schemaFile},
+			input:    `{"age": }`,
+			expected: "",
+			status:   exitError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			status := run(tc.args, strings.NewReader(tc.input), &stdout, &stderr)
+
+			if status != tc.status {
+				t.Errorf("Expected status %d, got %d instead, stderr: %s\n", tc.status, status, stderr.String())
+			}
+			if stdout.String() != tc.expected {
+				t.Errorf("Expected '%s', got '%s' instead\n", tc.expected, stdout.String())
+			}
+		})
+	}
+}
+
+func TestRunProject(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+
+	input := `{"name": "x", "age": 3, "address": {"city": "y", "zip": 1}}`
+	status := run([]string{"project", "--keep", ".name", "--keep", ".address.city"}, strings.NewReader(input), &stdout, &stderr)
+
+	if status != exitMatch {
+		t.Errorf("Expected status %d, got %d instead, stderr: %s\n", exitMatch, status, stderr.String())
+	}
+
+	expected := "{\"name\":\"x\",\"address\":{\"city\":\"y\"}}\n"
+
+	if stdout.String() != expected {
+		t.Errorf("Expected '%s', got '%s' instead\n", expected, stdout.String())
+	}
+
+	for _, args := range [][]string{{"project"}, {"project", "--keep", ".a["}} {
+		if status := run(args, strings.NewReader(input), &stdout, &stderr); status != exitError {
+			t.Errorf("Expected status %d for %v, got %d instead\n", exitError, args, status)
+		}
+	}
+}
+
+func TestRunDiff(t *testing.T) {
+	dir := t.TempDir()
+	left := filepath.Join(dir, "left.json")
+	right := filepath.Join(dir, "right.json")
+
+	if err := os.WriteFile(left, []byte(`{"items": [{"id": 1, "n": 1}, {"id": 2, "n": 2}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(right, []byte(`{"items": [{"id": 2, "n": 3}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		args     []string
+		expected string
+		status   int
+	}{
+		{name: "text",
+			args:     []string{"diff", left, right},
+			expected: "~ .items[0].id: 1 -> 2\n~ .items[0].n: 1 -> 3\n- .items[1]: {\"id\":2,\"n\":2}\n",
+			status:   exitNoMatch},
+		{name: "key",
+			args:     []string{"diff", "--key", ".items[]=.id", left, right},
+			expected: "- .items[0]: {\"id\":1,\"n\":1}\n~ .items[1].n: 2 -> 3\n",
+			status:   exitNoMatch},
+		{name: "patch",
+			args:     []string{"diff", "--patch", "--key", ".items[]=.id", left, right},
+			expected: "[\n  {\"op\":\"replace\",\"path\":\"/items/1/n\",\"value\":3},\n  {\"op\":\"remove\",\"path\":\"/items/0\"}\n]\n",
+			status:   exitNoMatch},
+		{name: "same",
+			args:     []string{"diff", left, left},
+			expected: "",
+			status:   exitMatch},
+		{name: "oneFile",
+			args:     []string{"diff", left},
+			expected: "",
+			status:   exitError},
+		{name: "missingFile",
+			args:     []string{"diff", left, filepath.Join(dir, "missing.json")},
+			expected: "",
+			status:   exitError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			if status := run(tc.args, nil, &stdout, &stderr); status != tc.status {
+				t.Errorf("Expected status %d, got %d instead, stderr: %s\n", tc.status, status, stderr.String())
+			}
+			if stdout.String() != tc.expected {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, stdout.String())
+			}
+		})
+	}
+}
+
+func TestRunPatch(t *testing.T) {
+	dir := t.TempDir()
+	jsonPatch := filepath.Join(dir, "patch.json")
+	mergePatch := filepath.Join(dir, "merge.json")
+
+	if err := os.WriteFile(jsonPatch, []byte(`[{"op": "replace", "path": "/a", "value": 2}, {"op": "remove", "path": "/b/0"}]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(mergePatch, []byte(`{"a": null, "c": {"d": true}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	input := `{"a": 1, "b": [1, 2]}`
+
+	testCases := []struct {
+		name     string
+		args     []string
+		expected string
+		status   int
+	}{
+		{name: "patch",
+			args:     []string{"patch", jsonPatch},
+			expected: "{\"a\":2,\"b\":[2]}\n",
+			status:   exitMatch},
+		{name: "merge",
+			args:     []string{"patch", "--merge", mergePatch, "-"},
+			expected: "{\"b\":[1,2],\"c\":{\"d\":true}}\n",
+			status:   exitMatch},
+		{name: "notAPatch",
+			args:     []string{"patch", mergePatch},
+			expected: "",
+			status:   exitError},
+		{name: "noPatch",
+			args:     []string{"patch"},
+			expected: "",
+			status:   exitError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			if status := run(tc.args, strings.NewReader(input), &stdout, &stderr); status != tc.status {
+				t.Errorf("Expected status %d, got %d instead, stderr: %s\n", tc.status, status, stderr.String())
+			}
+			if stdout.String() != tc.expected {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, stdout.String())
+			}
+		})
+	}
+}
+
+func TestRunFormat(t *testing.T) {
+	input := `{"b": [1.50], "a": "é"}`
+
+	testCases := []struct {
+		name     string
+		args     []string
+		input    string
+		expected string
+		status   int
+	}{
+		{name: "indent",
+			args:     []string{"fmt"},
+			input:    input,
+			expected: "{\n  \"b\": [\n    1.50\n  ],\n  \"a\": \"é\"\n}\n",
+			status:   exitMatch},
+		{name: "compact",
+			args:     []string{"fmt", "--compact", "--sort-keys", "--ascii", "--shortest-numbers"},
+			input:    input,
+			expected: "{\"a\":\"\\u00e9\",\"b\":[1.5]}\n",
+			status:   exitMatch},
+		{name: "invalid",
+			args:   []string{"fmt"},
+			input:  `{"a": }`,
+			status: exitError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			if status := run(tc.args, strings.NewReader(tc.input), &stdout, &stderr); status != tc.status {
+				t.Errorf("Expected status %d, got %d instead, stderr: %s\n", tc.status, status, stderr.String())
+			}
+			if tc.status == exitMatch && stdout.String() != tc.expected {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, stdout.String())
+			}
+		})
+	}
+}
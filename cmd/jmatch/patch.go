@@ -0,0 +1,80 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rodic/jmatch/patch"
+)
+
+// applier is a JSON Patch or a JSON Merge Patch.
+type applier interface {
+	Apply(r io.Reader, w io.Writer) error
+}
+
+func runPatch(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("jmatch patch", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: jmatch patch [flags] patch [file ...]")
+		flags.PrintDefaults()
+	}
+
+	merge := flags.Bool("merge", false, "read the patch as an RFC 7396 JSON Merge Patch instead of an RFC 6902 JSON Patch")
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitMatch
+		}
+		return exitError
+	}
+
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return exitError
+	}
+
+	patchFile := flags.Arg(0)
+	p, err := readPatch(patchFile, *merge)
+
+	if err != nil {
+		fmt.Fprintf(stderr, "jmatch: %s: %v\n", patchFile, err)
+		return exitError
+	}
+
+	files := flags.Args()[1:]
+
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	for _, file := range files {
+		r, err := open(file, stdin)
+
+		if err == nil {
+			err = p.Apply(r, stdout)
+			r.Close()
+		}
+		if err != nil {
+			printErr(stderr, file, err)
+			return exitError
+		}
+	}
+	return exitMatch
+}
+
+func readPatch(file string, merge bool) (applier, error) {
+	f, err := os.Open(file)
+
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if merge {
+		return patch.ReadMerge(f)
+	}
+	return patch.Read(f)
+}
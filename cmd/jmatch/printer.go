@@ -0,0 +1,42 @@
+package main
+
+import (
+	"github.com/rodic/jmatch"
+	"github.com/rodic/jmatch/format"
+)
+
+// printer writes the matches, through a format.Writer or, in context mode,
+// a format.ContextWriter.
+type printer interface {
+	write(m match) error
+	Flush() error
+}
+
+type valuePrinter struct {
+	format.Writer
+}
+
+func (o valuePrinter) write(m match) error {
+	return o.Write(m.path, m.token)
+}
+
+type contextPrinter struct {
+	*format.ContextWriter
+}
+
+func (o contextPrinter) write(m match) error {
+	matches := make([]jmatch.Token, len(m.context.Matches))
+
+	for i, value := range m.context.Matches {
+		matches[i] = value.Token
+	}
+	return o.Write(m.context.Path, m.context.Tokens, matches)
+}
+
+// the writers able to name files and color their output, of either kind
+type (
+	fileNamer interface{ SetFile(name string) }
+	colorer   interface {
+		SetColors(colors format.Colors, highlight format.Highlighter)
+	}
+)
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/rodic/jmatch"
+	"github.com/rodic/jmatch/paths"
+)
+
+// patterns is a flag that can be given many times.
+type patterns []string
+
+func (p *patterns) String() string {
+	return strings.Join(*p, ",")
+}
+
+func (p *patterns) Set(pattern string) error {
+	if _, err := paths.Compile(pattern); err != nil {
+		return err
+	}
+	*p = append(*p, pattern)
+	return nil
+}
+
+func runProject(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("jmatch project", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: jmatch project --keep pattern [flags] [file ...]")
+		flags.PrintDefaults()
+	}
+
+	var keep patterns
+
+	flags.Var(&keep, "keep", "keep the values whose path matches the `pattern`, can be given many times")
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitMatch
+		}
+		return exitError
+	}
+
+	if len(keep) == 0 {
+		fmt.Fprintln(stderr, "jmatch: --keep is required")
+		return exitError
+	}
+
+	files := flags.Args()
+
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	for _, file := range files {
+		r, err := open(file, stdin)
+
+		if err == nil {
+			err = jmatch.Project(r, stdout, keep...)
+			r.Close()
+		}
+		if err != nil {
+			printErr(stderr, file, err)
+			return exitError
+		}
+	}
+	return exitMatch
+}
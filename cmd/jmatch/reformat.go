@@ -0,0 +1,71 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/rodic/jmatch/format"
+)
+
+func runFormat(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("jmatch fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: jmatch fmt [flags] [file ...]")
+		flags.PrintDefaults()
+	}
+
+	indent := flags.String("indent", "  ", "indent nested values by `string`")
+	compact := flags.Bool("compact", false, "write every value on a single line, with no whitespace")
+	canonical := flags.Bool("canonical", false, "write the RFC 8785 canonical form of the document, with no new line at the end")
+	sortKeys := flags.Bool("sort-keys", false, "sort the members of objects by key")
+	ascii := flags.Bool("ascii", false, "escape the characters outside of ASCII")
+	shortestNumbers := flags.Bool("shortest-numbers", false, "write numbers the way JavaScript does, not as they are")
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitMatch
+		}
+		return exitError
+	}
+
+	var opts []format.Option
+
+	if *sortKeys {
+		opts = append(opts, format.SortKeys())
+	}
+	if *ascii {
+		opts = append(opts, format.ASCII())
+	}
+	if *shortestNumbers {
+		opts = append(opts, format.ShortestNumbers())
+	}
+
+	files := flags.Args()
+
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	for _, file := range files {
+		r, err := open(file, stdin)
+
+		if err == nil {
+			switch {
+			case *canonical:
+				err = format.Canonical(r, stdout)
+			case *compact:
+				err = format.Compact(r, stdout, opts...)
+			default:
+				err = format.Indent(r, stdout, *indent, opts...)
+			}
+			r.Close()
+		}
+		if err != nil {
+			printErr(stderr, file, err)
+			return exitError
+		}
+	}
+	return exitMatch
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"io"
+
+	"github.com/rodic/jmatch"
+)
+
+// match is a value found to pass the filter, or in context mode the
+// container around such values.
+type match struct {
+	path    string
+	token   jmatch.Token
+	context *jmatch.Context
+}
+
+// job is the search of a file. Matches are sent on the results channel
+// as they are found and err is set once it is closed.
+type job struct {
+	file    string
+	err     error
+	results chan match
+}
+
+// resultsBuffer is how many matches a file may get ahead of the output.
+const resultsBuffer = 256
+
+type searcher struct {
+	filter  filter
+	opts    []jmatch.Option
+	stdin   io.Reader
+	workers int
+	levels  int // of the containers printed around matches, none if 0
+}
+
+func (s searcher) search(j *job) {
+	defer close(j.results)
+
+	if j.err != nil {
+		return // the file could not be listed
+	}
+
+	r, err := open(j.file, s.stdin)
+
+	if err != nil {
+		j.err = err
+		return
+	}
+	defer r.Close()
+
+	if s.levels > 0 {
+		j.err = jmatch.MatchContext(r, s.filter.matches, s.levels, func(context jmatch.Context) {
+			j.results <- match{context: &context}
+		}, s.opts...)
+		return
+	}
+
+	j.err = jmatch.Match(r, func(path string, token jmatch.Token) {
+		if s.filter.matches(path, token) {
+			j.results <- match{path: path, token: token}
+		}
+	}, s.opts...)
+}
+
+// run searches the files listed in parallel and passes every file, with
+// its matches, to the handler in the order they are listed. Each file
+// gets ahead of the handler by resultsBuffer matches at most, so memory
+// does not grow with the input.
+func (s searcher) run(list func(visit func(file string, err error)), handle func(j *job)) {
+	pending := make(chan *job, s.workers)
+	jobs := make(chan *job)
+
+	go func() {
+		list(func(file string, err error) {
+			j := &job{file: file, err: err, results: make(chan match, resultsBuffer)}
+			pending <- j
+			jobs <- j
+		})
+		close(pending)
+		close(jobs)
+	}()
+
+	for i := 0; i < s.workers; i++ {
+		go func() {
+			for j := range jobs {
+				s.search(j)
+			}
+		}()
+	}
+
+	for j := range pending {
+		handle(j)
+	}
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/rodic/jmatch"
+	"github.com/rodic/jmatch/schema"
+)
+
+func runValidate(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+	flags := flag.NewFlagSet("jmatch validate", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: jmatch validate --schema file [flags] [file ...]")
+		flags.PrintDefaults()
+	}
+
+	schemaFile := flags.String("schema", "", "validate against the JSON Schema in `file`")
+	ndjson := flags.Bool("ndjson", false, "read a document per line")
+
+	if err := flags.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitMatch
+		}
+		return exitError
+	}
+
+	if *schemaFile == "" {
+		fmt.Fprintln(stderr, "jmatch: validate needs a --schema")
+		return exitError
+	}
+
+	s, err := readSchema(*schemaFile)
+
+	if err != nil {
+		fmt.Fprintf(stderr, "jmatch: %s: %v\n", *schemaFile, err)
+		return exitError
+	}
+
+	files := flags.Args()
+
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+
+	out := bufio.NewWriter(stdout)
+	defer out.Flush()
+
+	match := jmatch.Match
+
+	if *ndjson {
+		match = jmatch.MatchNDJSON
+	}
+
+	status := exitMatch
+
+	for _, file := range files {
+		name := displayName(file)
+
+		validator, err := schema.NewValidator(s, func(v schema.Violation) {
+			fmt.Fprintf(out, "%s:%d:%d: %s: %s\n", name, v.Line, v.Column, v.Path, v.Message)
+			status = exitNoMatch
+		})
+
+		if err != nil {
+			fmt.Fprintf(stderr, "jmatch: %s: %v\n", *schemaFile, err)
+			return exitError
+		}
+
+		r, err := open(file, stdin)
+
+		if err == nil {
+			err = match(r, validator.Add, jmatch.WithContainers())
+			r.Close()
+		}
+		if err != nil {
+			out.Flush()
+			fmt.Fprintf(stderr, "jmatch: %s: %v\n", name, err)
+			return exitError
+		}
+	}
+	return status
+}
+
+func readSchema(file string) (*schema.Schema, error) {
+	f, err := os.Open(file)
+
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return schema.Read(f)
+}
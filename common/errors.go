@@ -1,19 +1,283 @@
 package common
 
-import "fmt"
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
 
-type UnexpectedEndOfInputErr struct{}
+// Sentinel values for use with errors.Is.
+var (
+	ErrUnexpectedEndOfInput = errors.New("unexpected end of JSON input")
+	ErrUnexpectedToken      = errors.New("unexpected token")
+	ErrDuplicateKey         = errors.New("duplicate key")
+	ErrNumberOutOfRange     = errors.New("number out of range")
+)
+
+type UnexpectedEndOfInputErr struct {
+	// Line and Column of the last valid token, zero if there was none.
+	Line   int
+	Column int
+	// Path of the innermost container left open, if any.
+	Path string
+}
 
 func (e UnexpectedEndOfInputErr) Error() string {
-	return "invalid JSON. Unexpected end of JSON input"
+	var msg strings.Builder
+
+	msg.WriteString("invalid JSON. Unexpected end of JSON input")
+
+	if e.Line > 0 {
+		fmt.Fprintf(&msg, " after line %d column %d", e.Line, e.Column)
+	}
+	if e.Path != "" {
+		fmt.Fprintf(&msg, ", container at %s is not closed", shortPath(e.Path))
+	}
+	return msg.String()
+}
+
+func (e UnexpectedEndOfInputErr) Is(target error) bool {
+	return target == ErrUnexpectedEndOfInput
 }
 
 type UnexpectedTokenErr struct {
 	Token  string
 	Line   int
 	Column int
+	// Path at which the token was found, empty if unknown.
+	Path string
+	// Expected lists what could have been there instead, like ":" or "string".
+	Expected []string
+	// Excerpt is the offending line with a caret under the token, empty if
+	// that part of the input is no longer available.
+	Excerpt string
 }
 
 func (e UnexpectedTokenErr) Error() string {
-	return fmt.Sprintf("invalid JSON. unexpected token %s at line %d column %d", e.Token, e.Line, e.Column)
+	var msg strings.Builder
+
+	fmt.Fprintf(&msg, "invalid JSON. unexpected token %s at line %d column %d", shorten(e.Token, maxTokenLength), e.Line, e.Column)
+
+	if e.Path != "" {
+		fmt.Fprintf(&msg, " in %s", shortPath(e.Path))
+	}
+	if len(e.Expected) > 0 {
+		fmt.Fprintf(&msg, ", expected %s", formatExpected(e.Expected))
+	}
+	return msg.String()
+}
+
+func (e UnexpectedTokenErr) Is(target error) bool {
+	return target == ErrUnexpectedToken
+}
+
+// formatExpected joins token kinds into "':', ',' or '}'", quoting
+// punctuation so it can't be confused with the sentence around it.
+func formatExpected(expected []string) string {
+	quoted := make([]string, len(expected))
+
+	for i, kind := range expected {
+		if len(kind) == 1 {
+			quoted[i] = "'" + kind + "'"
+		} else {
+			quoted[i] = kind
+		}
+	}
+
+	last := len(quoted) - 1
+
+	if last == 0 {
+		return quoted[0]
+	}
+	return strings.Join(quoted[:last], ", ") + " or " + quoted[last]
+}
+
+// The lengths in bytes beyond which paths and tokens are shortened in error
+// messages, deeply nested input making paths of any length and a string or
+// a run of letters tokens of any length.
+const (
+	maxPathLength  = 200
+	maxTokenLength = 40
+)
+
+// shortPath cuts the middle out of a path too long to be read, keeping
+// its start and its end, where the error is.
+func shortPath(path string) string {
+	return shorten(path, maxPathLength)
+}
+
+// shorten cuts the middle out of the text if it is longer than max bytes,
+// keeping its start and its end.
+func shorten(text string, max int) string {
+	if len(text) <= max {
+		return text
+	}
+
+	head, tail := max/2, len(text)-max/2
+
+	for head > 0 && !utf8.RuneStart(text[head]) {
+		head--
+	}
+	for tail < len(text) && !utf8.RuneStart(text[tail]) {
+		tail++
+	}
+	return text[:head] + "..." + text[tail:]
+}
+
+// DuplicateKeyErr reports a key found twice in the same object, with the
+// positions of both occurrences.
+type DuplicateKeyErr struct {
+	Key         string
+	Path        string // of the object
+	Line        int
+	Column      int
+	FirstLine   int
+	FirstColumn int
+}
+
+func (e DuplicateKeyErr) Error() string {
+	return fmt.Sprintf("invalid JSON. duplicate key %q in %s at line %d column %d, first seen at line %d column %d",
+		e.Key, shortPath(e.Path), e.Line, e.Column, e.FirstLine, e.FirstColumn)
+}
+
+func (e DuplicateKeyErr) Is(target error) bool {
+	return target == ErrDuplicateKey
+}
+
+// NumberRangeErr reports a number that a float64 cannot hold, where the
+// number has to be read as one.
+type NumberRangeErr struct {
+	Number string
+	Line   int
+	Column int
+}
+
+func (e NumberRangeErr) Error() string {
+	return fmt.Sprintf("invalid number %s at line %d column %d, out of the range of a float64",
+		shorten(e.Number, maxTokenLength), e.Line, e.Column)
+}
+
+func (e NumberRangeErr) Is(target error) bool {
+	return target == ErrNumberOutOfRange
+}
+
+// ErrLimitExceeded matches every error reporting that the input went over
+// one of the configured resource limits.
+var ErrLimitExceeded = errors.New("limit exceeded")
+
+type DepthLimitErr struct {
+	Limit  int
+	Line   int
+	Column int
+}
+
+func (e DepthLimitErr) Error() string {
+	return fmt.Sprintf("limit exceeded. nesting deeper than %d levels at line %d column %d", e.Limit, e.Line, e.Column)
+}
+
+func (e DepthLimitErr) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+type StringLengthLimitErr struct {
+	Limit  int
+	Line   int
+	Column int
+}
+
+func (e StringLengthLimitErr) Error() string {
+	return fmt.Sprintf("limit exceeded. string longer than %d bytes at line %d column %d", e.Limit, e.Line, e.Column)
+}
+
+func (e StringLengthLimitErr) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+type NumberLengthLimitErr struct {
+	Limit  int
+	Line   int
+	Column int
+}
+
+func (e NumberLengthLimitErr) Error() string {
+	return fmt.Sprintf("limit exceeded. number longer than %d characters at line %d column %d", e.Limit, e.Line, e.Column)
+}
+
+func (e NumberLengthLimitErr) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+type TokenCountLimitErr struct {
+	Limit  int
+	Line   int
+	Column int
+}
+
+func (e TokenCountLimitErr) Error() string {
+	return fmt.Sprintf("limit exceeded. more than %d tokens at line %d column %d", e.Limit, e.Line, e.Column)
+}
+
+func (e TokenCountLimitErr) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+type InputSizeLimitErr struct {
+	Limit int64
+}
+
+func (e InputSizeLimitErr) Error() string {
+	return fmt.Sprintf("limit exceeded. input larger than %d bytes", e.Limit)
+}
+
+func (e InputSizeLimitErr) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+type KeyCountLimitErr struct {
+	Limit  int
+	Path   string // of the object
+	Line   int
+	Column int
+}
+
+func (e KeyCountLimitErr) Error() string {
+	return fmt.Sprintf("limit exceeded. object at %s has more than %d keys at line %d column %d", shortPath(e.Path), e.Limit, e.Line, e.Column)
+}
+
+func (e KeyCountLimitErr) Is(target error) bool {
+	return target == ErrLimitExceeded
+}
+
+// RecordErr is an error found in a record of NDJSON input. Positions in
+// Err are counted from the start of the record.
+type RecordErr struct {
+	Line int // of the record in the input
+	Err  error
+}
+
+func (e RecordErr) Error() string {
+	return fmt.Sprintf("record at line %d: %v", e.Line, e.Err)
+}
+
+func (e RecordErr) Unwrap() error {
+	return e.Err
+}
+
+// ErrorList holds every error found while parsing in recovery mode, in the
+// order they were found.
+type ErrorList []error
+
+func (e ErrorList) Error() string {
+	messages := make([]string, len(e))
+
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+
+	return strings.Join(messages, "\n")
+}
+
+func (e ErrorList) Unwrap() []error {
+	return e
 }
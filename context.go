@@ -0,0 +1,147 @@
+package jmatch
+
+import (
+	"io"
+
+	p "github.com/rodic/jmatch/parser"
+	"github.com/rodic/jmatch/paths"
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+// Value is a value passed to a matcher along with its path.
+type Value struct {
+	Path  string
+	Token Token
+}
+
+// Context is an object or array holding matched values.
+type Context struct {
+	Path string
+	// Tokens make up the container, from the opening paren to the closing
+	// one, nested containers included.
+	Tokens []Token
+	// Matches are the values of the container let through by the filter,
+	// in the order they were found.
+	Matches []Value
+}
+
+// Filter tells whether a value is of interest.
+type Filter func(path string, token Token) bool
+
+type ContextMatcher func(context Context)
+
+// frame is a container being read by MatchContext.
+type frame struct {
+	path    string
+	open    Token
+	matches []Value
+	// segments of the matches, kept for a pruned root only
+	segments [][]paths.Segment
+}
+
+// MatchContext is grep -C for JSON. It passes to the matcher the
+// containers holding values let through by the filter, the way grep shows
+// the lines around the matching ones. The container of a value is the
+// object or array levels up from it, 1 being the one the value is in, or
+// the root if there are not as many. A container is passed once, after it
+// is closed, with all the values it was picked for. Containers are
+// passed in the order they are closed, so nested ones come first.
+//
+// The filter is called for values only, never for objects and arrays. A
+// root value that is not a container is its own context. The root
+// container is kept whole, so memory grows with the input, unless
+// WithPrunedRoot is given.
+func MatchContext(reader io.Reader, filter Filter, levels int, matcher ContextMatcher, opts ...Option) error {
+	config := newConfig(opts)
+	config.parser.EmitContainers = true
+	config.parser.BufferContainers = true
+	config.parser.LazyPaths = true
+
+	levels = max(levels, 1)
+	pruned := config.parser.UnbufferedRoot
+
+	var frames []*frame
+
+	return match(reader, func(result p.ParsingResult) {
+		token := result.Token
+		path := result.Lazy.String()
+
+		switch {
+		case token.IsLeftBrace() || token.IsLeftBracket():
+			frames = append(frames, &frame{path: path, open: token})
+		case token.IsRightBrace() || token.IsRightBracket():
+			closed := frames[len(frames)-1]
+			frames = frames[:len(frames)-1]
+
+			switch {
+			case len(closed.matches) == 0:
+			case len(frames) == 0 && pruned:
+				tokens := prunedRoot(closed.open, token, closed.segments, closed.matches)
+				matcher(Context{Path: closed.path, Tokens: tokens, Matches: closed.matches})
+			default:
+				matcher(Context{Path: closed.path, Tokens: result.Container, Matches: closed.matches})
+			}
+		case !filter(path, token):
+		case len(frames) == 0:
+			matcher(Context{Path: path, Tokens: []Token{token}, Matches: []Value{{Path: path, Token: token}}})
+		default:
+			f := frames[max(len(frames)-levels, 0)]
+			f.matches = append(f.matches, Value{Path: path, Token: token})
+
+			if pruned && f == frames[0] {
+				f.segments = append(f.segments, result.Lazy.Segments())
+			}
+		}
+	}, config)
+}
+
+// prunedRoot makes the tokens of a root holding only the given values,
+// found at the given segments, and the objects and arrays leading to them.
+// Objects keep the keys of the values, arrays their order but not their
+// indexes.
+func prunedRoot(open Token, closing Token, segments [][]paths.Segment, values []Value) []Token {
+	tokens := []Token{open}
+
+	var opened []paths.Segment // below the root, leading to the last value
+	var closers []Token
+
+	for i, value := range values {
+		path := segments[i]
+		common := 0
+
+		for common < len(opened) && common < len(path)-1 && opened[common] == path[common] {
+			common++
+		}
+		for len(opened) > common {
+			tokens = append(tokens, closers[len(closers)-1])
+			opened, closers = opened[:len(opened)-1], closers[:len(closers)-1]
+		}
+
+		if last := tokens[len(tokens)-1]; !last.IsLeftBrace() && !last.IsLeftBracket() {
+			tokens = append(tokens, t.NewCommaToken(0, 0))
+		}
+
+		for depth, segment := range path[common:] {
+			if segment.IsKey() {
+				tokens = append(tokens, t.NewStringToken(segment.Key, 0, 0), t.NewColonToken(0, 0))
+			}
+			if common+depth == len(path)-1 {
+				break
+			}
+			if path[common+depth+1].IsKey() {
+				tokens = append(tokens, t.NewLeftBraceToken(0, 0))
+				closers = append(closers, t.NewRightBraceToken(0, 0))
+			} else {
+				tokens = append(tokens, t.NewLeftBracketToken(0, 0))
+				closers = append(closers, t.NewRightBracketToken(0, 0))
+			}
+			opened = append(opened, segment)
+		}
+		tokens = append(tokens, value.Token)
+	}
+
+	for i := len(closers) - 1; i >= 0; i-- {
+		tokens = append(tokens, closers[i])
+	}
+	return append(tokens, closing)
+}
@@ -0,0 +1,369 @@
+// Package diff compares two JSON documents as they stream through the
+// parser, reporting the values added, removed and changed by their path:
+//
+//	changes, err := diff.Diff(before, after, diff.Config{})
+//	err = diff.WriteText(os.Stdout, changes)
+//
+// The documents are read side by side and the values found in both are
+// compared and let go at once, so memory grows with the differences found
+// rather than with the documents. Documents holding the same keys in the
+// same order are compared in constant memory.
+package diff
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"sort"
+	"strings"
+
+	"github.com/rodic/jmatch/format"
+	"github.com/rodic/jmatch/paths"
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+// Config controls how documents are compared.
+type Config struct {
+	// ArrayKeys maps patterns of array elements, such as .items[], to the
+	// path of their key in the element, such as .id. The elements of those
+	// arrays are matched by key rather than by position, so that an
+	// element inserted does not show every one after it as changed. Moved
+	// elements are not reported. Elements with no key, or with the key of
+	// one before them, are matched by position.
+	ArrayKeys map[string]string
+}
+
+type arrayKey struct {
+	elements paths.Pattern
+	key      []paths.Segment
+}
+
+// Kind tells how a value differs.
+type Kind int
+
+const (
+	Added Kind = iota
+	Removed
+	Changed
+)
+
+func (k Kind) String() string {
+	switch k {
+	case Added:
+		return "added"
+	case Removed:
+		return "removed"
+	default:
+		return "changed"
+	}
+}
+
+// Change is a value that differs between the documents. Objects and arrays
+// added or removed are reported as a whole, as are values replaced by one
+// of another type.
+type Change struct {
+	Kind Kind
+	// Path is the jq path of the value in the left document, or in the
+	// right one for added values.
+	Path string
+	// Old and New are the values in the left and right documents as
+	// compact JSON, Old being empty for added values and New for removed
+	// ones.
+	Old string
+	New string
+
+	// the path in the left document, that of the parent for added values
+	// followed by the last segment of the path in the right one
+	pointer []paths.Segment
+	seq     int
+}
+
+// Diff reads both documents and returns their differences in the order
+// they are found in, reading a value of each document in turn.
+func Diff(left io.Reader, right io.Reader, config Config) ([]Change, error) {
+	patterns := make([]string, 0, len(config.ArrayKeys))
+
+	for elements := range config.ArrayKeys {
+		patterns = append(patterns, elements)
+	}
+	sort.Strings(patterns)
+
+	keys := make([]arrayKey, 0, len(patterns))
+
+	for _, elements := range patterns {
+		key := config.ArrayKeys[elements]
+		pattern, err := paths.Compile(elements)
+
+		if err != nil {
+			return nil, err
+		}
+
+		segments, err := paths.Split(key)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid key of %s: %w", elements, err)
+		}
+		keys = append(keys, arrayKey{elements: pattern, key: segments})
+	}
+
+	done := make(chan struct{})
+	defer close(done)
+
+	walkers := [2]*walker{}
+
+	for side, r := range []io.Reader{left, right} {
+		walkers[side] = &walker{side: side, keys: keys, events: make(chan *event, 64), done: done}
+		go walkers[side].run(r)
+	}
+
+	d := &differ{
+		pending:     [2]map[string]*event{{}, {}},
+		replaced:    map[string]*replacement{},
+		leftIndexes: map[string]int{},
+	}
+
+	open := [2]bool{true, true}
+
+	for open[0] || open[1] {
+		for side, w := range walkers {
+			if !open[side] {
+				continue
+			}
+
+			e, ok := <-w.events
+
+			if !ok {
+				open[side] = false
+				continue
+			}
+			d.add(e)
+		}
+	}
+
+	for side, name := range []string{"left", "right"} {
+		if err := walkers[side].err; err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+
+	return d.finish(), nil
+}
+
+// replacement is a value replaced by one of another type, made of the
+// values found in each document.
+type replacement struct {
+	change *Change
+	nodes  [2]*node
+}
+
+// node is a value found in a document only, or a part of it.
+type node struct {
+	event    *event
+	children []*node
+}
+
+type differ struct {
+	seq      int
+	pending  [2]map[string]*event // found in one document so far
+	changes  []*Change
+	replaced map[string]*replacement
+
+	// the positions in the left document of the elements of arrays matched
+	// by key found at another in the right one
+	leftIndexes map[string]int
+}
+
+func (d *differ) add(e *event) {
+	e.seq = d.seq
+	d.seq++
+
+	other := d.pending[1-e.side]
+
+	if found, ok := other[e.id]; ok {
+		delete(other, e.id)
+
+		if e.side == 0 {
+			d.compare(e, found)
+		} else {
+			d.compare(found, e)
+		}
+		return
+	}
+
+	d.pending[e.side][e.id] = e
+}
+
+func (d *differ) compare(left *event, right *event) {
+	if left.frame != nil && right.frame != nil {
+		if left.segment.IsIndex() && left.segment.Index != right.segment.Index {
+			d.leftIndexes[left.id] = left.segment.Index
+		}
+		if left.frame.object == right.frame.object {
+			return
+		}
+	}
+
+	if left.frame == nil && right.frame == nil && equal(left.token, right.token) {
+		return
+	}
+
+	change := &Change{Kind: Changed, Path: paths.Join(left.path), pointer: left.path, seq: min(left.seq, right.seq)}
+	d.changes = append(d.changes, change)
+
+	if left.frame == nil && right.frame == nil {
+		change.Old = format.Literal(left.token)
+		change.New = format.Literal(right.token)
+		return
+	}
+
+	d.replaced[left.id] = &replacement{change: change, nodes: [2]*node{{event: left}, {event: right}}}
+}
+
+// finish turns the values found in a document only into changes, the ones
+// in objects and arrays found in a document only making up their values.
+func (d *differ) finish() []Change {
+	var rest []*event
+
+	for _, pending := range d.pending {
+		for _, e := range pending {
+			rest = append(rest, e)
+		}
+	}
+
+	sort.Slice(rest, func(i, j int) bool {
+		return rest[i].seq < rest[j].seq
+	})
+
+	nodes := [2]map[string]*node{{}, {}}
+
+	for id, r := range d.replaced {
+		nodes[0][id] = r.nodes[0]
+		nodes[1][id] = r.nodes[1]
+	}
+
+	type whole struct {
+		change *Change
+		node   *node
+	}
+	var wholes []whole
+
+	for _, e := range rest {
+		n := &node{event: e}
+
+		if e.frame != nil {
+			nodes[e.side][e.id] = n
+		}
+
+		if e.parent != nil {
+			if parent, ok := nodes[e.side][e.parent.identity()]; ok {
+				parent.children = append(parent.children, n)
+				continue
+			}
+		}
+
+		change := &Change{Kind: Removed, Path: paths.Join(e.path), pointer: e.path, seq: e.seq}
+
+		if e.side == 1 {
+			change.Kind = Added
+			change.pointer = d.leftPath(e)
+		}
+
+		d.changes = append(d.changes, change)
+		wholes = append(wholes, whole{change: change, node: n})
+	}
+
+	for _, w := range wholes {
+		if w.change.Kind == Added {
+			w.change.New = encode(w.node)
+		} else {
+			w.change.Old = encode(w.node)
+		}
+	}
+
+	for _, r := range d.replaced {
+		r.change.Old = encode(r.nodes[0])
+		r.change.New = encode(r.nodes[1])
+	}
+
+	sort.Slice(d.changes, func(i, j int) bool {
+		return d.changes[i].seq < d.changes[j].seq
+	})
+
+	changes := make([]Change, len(d.changes))
+
+	for i, c := range d.changes {
+		changes[i] = *c
+	}
+	return changes
+}
+
+// leftPath returns the path an added value takes in the left document,
+// its parent being found in both.
+func (d *differ) leftPath(e *event) []paths.Segment {
+	var frames []*frame
+
+	for f := e.parent; f != nil && f.depth > 0; f = f.parent {
+		frames = append(frames, f)
+	}
+
+	path := make([]paths.Segment, 0, len(frames)+1)
+
+	for i := len(frames) - 1; i >= 0; i-- {
+		segment := frames[i].segment
+
+		if index, ok := d.leftIndexes[frames[i].identity()]; ok {
+			segment = paths.IndexSegment(index)
+		}
+		path = append(path, segment)
+	}
+	return append(path, e.segment)
+}
+
+// equal compares values, numbers by value so that 1.0 equals 1.
+func equal(a t.Token, b t.Token) bool {
+	if a.IsNumber() && b.IsNumber() {
+		x, okX := new(big.Rat).SetString(a.Value)
+		y, okY := new(big.Rat).SetString(b.Value)
+
+		if okX && okY {
+			return x.Cmp(y) == 0
+		}
+	}
+	return format.Literal(a) == format.Literal(b)
+}
+
+// encode writes the value as compact JSON.
+func encode(n *node) string {
+	var json strings.Builder
+	n.encode(&json)
+	return json.String()
+}
+
+func (n *node) encode(json *strings.Builder) {
+	if n.event.frame == nil {
+		json.WriteString(format.Literal(n.event.token))
+		return
+	}
+
+	closing := "]"
+
+	if n.event.frame.object {
+		json.WriteRune('{')
+		closing = "}"
+	} else {
+		json.WriteRune('[')
+	}
+
+	for i, child := range n.children {
+		if i > 0 {
+			json.WriteRune(',')
+		}
+		if n.event.frame.object {
+			json.WriteString(paths.Quote(child.event.segment.Key))
+			json.WriteRune(':')
+		}
+		child.encode(json)
+	}
+
+	json.WriteString(closing)
+}
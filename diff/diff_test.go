@@ -0,0 +1,172 @@
+package diff
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDiff(t *testing.T) {
+	items := `{"items": [{"id": 1, "v": "a"}, {"id": 2, "v": "b"}, {"id": 3, "v": "c"}]}`
+	moved := `{"items": [{"id": 0, "v": "z"}, {"v": "c2", "id": 3}, {"id": 1, "v": "a"}]}`
+
+	testCases := []struct {
+		name      string
+		left      string
+		right     string
+		arrayKeys map[string]string
+		expected  string
+	}{
+		{name: "same",
+			left:     `{"a": [1, {"b": null}], "c": "x"}`,
+			right:    `{"a": [1, {"b": null}], "c": "x"}`,
+			expected: ""},
+		{name: "keyOrder",
+			left:     `{"a": 1, "b": {"c": true, "d": false}}`,
+			right:    `{"b": {"d": false, "c": true}, "a": 1}`,
+			expected: ""},
+		{name: "numbers",
+			left:     `[1.0, 100, 2e-1]`,
+			right:    `[1, 1e2, 0.2]`,
+			expected: ""},
+		{name: "changed",
+			left:     `{"a": 1, "b": "x", "c": true, "d": "1"}`,
+			right:    `{"a": 2, "b": "y", "c": null, "d": 1}`,
+			expected: "~ .a: 1 -> 2\n~ .b: \"x\" -> \"y\"\n~ .c: true -> null\n~ .d: \"1\" -> 1\n"},
+		{name: "addedAndRemoved",
+			left:     `{"a": 1, "b": {"c": [1, 2]}, "d": [1, 2, 3]}`,
+			right:    `{"a": 1, "d": [1], "e": {"f": [true, {}]}}`,
+			expected: "- .b: {\"c\":[1,2]}\n+ .e: {\"f\":[true,{}]}\n- .d[1]: 2\n- .d[2]: 3\n"},
+		{name: "typeChanged",
+			left:     `{"a": {"x": 1}, "b": [1], "c": 1}`,
+			right:    `{"a": [1, 2], "b": "s", "c": {"y": []}}`,
+			expected: "~ .a: {\"x\":1} -> [1,2]\n~ .b: [1] -> \"s\"\n~ .c: 1 -> {\"y\":[]}\n"},
+		{name: "root",
+			left:     `[1]`,
+			right:    `"x"`,
+			expected: "~ .: [1] -> \"x\"\n"},
+		{name: "byPosition",
+			left:     items,
+			right:    moved,
+			expected: "~ .items[0].id: 1 -> 0\n~ .items[0].v: \"a\" -> \"z\"\n~ .items[1].id: 2 -> 3\n~ .items[1].v: \"b\" -> \"c2\"\n~ .items[2].id: 3 -> 1\n~ .items[2].v: \"c\" -> \"a\"\n"},
+		{name: "byKey",
+			left:      items,
+			right:     moved,
+			arrayKeys: map[string]string{".items[]": ".id"},
+			expected:  "+ .items[0]: {\"id\":0,\"v\":\"z\"}\n- .items[1]: {\"id\":2,\"v\":\"b\"}\n~ .items[2].v: \"c\" -> \"c2\"\n"},
+		{name: "byNestedKey",
+			left:      `[{"meta": {"id": "a"}, "n": 1}, {"meta": {"id": "b"}, "n": 2}]`,
+			right:     `[{"meta": {"id": "b"}, "n": 3}]`,
+			arrayKeys: map[string]string{".[]": ".meta.id"},
+			expected:  "- .[0]: {\"meta\":{\"id\":\"a\"},\"n\":1}\n~ .[1].n: 2 -> 3\n"},
+		{name: "missingAndDuplicateKeys",
+			left:      `[{"id": 1}, {"id": 1, "n": 1}, {"n": 2}]`,
+			right:     `[{"id": 1}, {"id": 1, "n": 1}, {"n": 3}]`,
+			arrayKeys: map[string]string{".[]": ".id"},
+			expected:  "~ .[2].n: 2 -> 3\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			changes, err := Diff(strings.NewReader(tc.left), strings.NewReader(tc.right), Config{ArrayKeys: tc.arrayKeys})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var text strings.Builder
+
+			if err := WriteText(&text, changes); err != nil {
+				t.Fatal(err)
+			}
+			if text.String() != tc.expected {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, text.String())
+			}
+		})
+	}
+}
+
+func TestWritePatch(t *testing.T) {
+	testCases := []struct {
+		name      string
+		left      string
+		right     string
+		arrayKeys map[string]string
+		expected  string
+	}{
+		{name: "empty",
+			left:     `{"a": 1}`,
+			right:    `{"a": 1}`,
+			expected: "[]\n"},
+		{name: "objects",
+			left:  `{"a": 1, "b/c": {"x": 1}, "d": "s"}`,
+			right: `{"a": 2, "b/c": [1], "e~": {"f": [true]}}`,
+			expected: "[\n" +
+				"  {\"op\":\"replace\",\"path\":\"/a\",\"value\":2},\n" +
+				"  {\"op\":\"replace\",\"path\":\"/b~1c\",\"value\":[1]},\n" +
+				"  {\"op\":\"remove\",\"path\":\"/d\"},\n" +
+				"  {\"op\":\"add\",\"path\":\"/e~0\",\"value\":{\"f\":[true]}}\n" +
+				"]\n"},
+		{name: "arrays",
+			left:  `[[1, 2, 3], [1], 3, 4]`,
+			right: `[[1], [1, 2], 3]`,
+			expected: "[\n" +
+				"  {\"op\":\"remove\",\"path\":\"/3\"},\n" +
+				"  {\"op\":\"remove\",\"path\":\"/0/2\"},\n" +
+				"  {\"op\":\"remove\",\"path\":\"/0/1\"},\n" +
+				"  {\"op\":\"add\",\"path\":\"/1/1\",\"value\":2}\n" +
+				"]\n"},
+		{name: "byKey",
+			left:      `[{"id": 1, "v": "a"}, {"id": 2, "v": "b"}, {"id": 3, "v": [1]}]`,
+			right:     `[{"id": 0}, {"id": 3, "v": [1, 2]}, {"id": 1, "v": "a"}]`,
+			arrayKeys: map[string]string{".[]": ".id"},
+			expected: "[\n" +
+				"  {\"op\":\"remove\",\"path\":\"/1\"},\n" +
+				"  {\"op\":\"add\",\"path\":\"/1/v/1\",\"value\":2},\n" +
+				"  {\"op\":\"add\",\"path\":\"/0\",\"value\":{\"id\":0}}\n" +
+				"]\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			changes, err := Diff(strings.NewReader(tc.left), strings.NewReader(tc.right), Config{ArrayKeys: tc.arrayKeys})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var patch strings.Builder
+
+			if err := WritePatch(&patch, changes); err != nil {
+				t.Fatal(err)
+			}
+			if patch.String() != tc.expected {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, patch.String())
+			}
+		})
+	}
+}
+
+func TestDiffErrors(t *testing.T) {
+	testCases := []struct {
+		name      string
+		left      string
+		right     string
+		arrayKeys map[string]string
+		expected  string
+	}{
+		{name: "left", left: `{"a": }`, right: `{}`, expected: "left: "},
+		{name: "right", left: `[]`, right: `[1,`, expected: "right: "},
+		{name: "pattern", left: `[]`, right: `[]`, arrayKeys: map[string]string{".a[": ".id"}, expected: "invalid path"},
+		{name: "key", left: `[]`, right: `[]`, arrayKeys: map[string]string{".[]": "id"}, expected: "invalid key"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Diff(strings.NewReader(tc.left), strings.NewReader(tc.right), Config{ArrayKeys: tc.arrayKeys})
+
+			if err == nil || !strings.HasPrefix(err.Error(), tc.expected) {
+				t.Errorf("Expected an error starting with '%v', got '%v' instead\n", tc.expected, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package diff
+
+import (
+	"io"
+
+	"github.com/rodic/jmatch"
+	"github.com/rodic/jmatch/format"
+	"github.com/rodic/jmatch/paths"
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+// frame is an object or array of a document.
+type frame struct {
+	parent  *frame
+	segment paths.Segment // in the parent
+	depth   int           // of the path
+
+	// elements of arrays matched by key; the key is the literal of the
+	// value found at keyPath, the position being used if there is none
+	keyPath  []paths.Segment
+	key      string
+	resolved bool
+
+	id     string
+	hasID  bool
+	keys   map[string]bool // of the elements, for arrays matched by key
+	object bool
+}
+
+// identity returns what the frame is known by in both documents: its path
+// with the elements of arrays matched by key written as [=key].
+func (f *frame) identity() string {
+	if f == nil || f.depth == 0 {
+		return ""
+	}
+	if !f.hasID {
+		f.id = f.parent.identity() + segmentID(f.segment, f.key)
+		f.hasID = true
+	}
+	return f.id
+}
+
+func segmentID(s paths.Segment, key string) string {
+	switch {
+	case s.IsKey():
+		return paths.Key(s.Key)
+	case key != "":
+		return "[=" + key + "]"
+	default:
+		return paths.Index(s.Index)
+	}
+}
+
+// event is a value, or the opening of an object or array, read from one of
+// the documents.
+type event struct {
+	side    int
+	seq     int
+	id      string
+	parent  *frame
+	frame   *frame // of objects and arrays
+	segment paths.Segment
+	path    []paths.Segment
+	token   t.Token
+}
+
+// walker reads a document, sending its events once their identity is
+// known. The events of the elements of arrays matched by key are held
+// until their key is found.
+type walker struct {
+	side   int
+	keys   []arrayKey
+	events chan *event
+	done   <-chan struct{}
+	err    error
+
+	stack      []*frame
+	buffer     []*event
+	unresolved int // frames with no key yet
+}
+
+func (w *walker) run(r io.Reader) {
+	defer close(w.events)
+
+	w.err = jmatch.Match(r, w.visit, jmatch.WithContainers())
+}
+
+func (w *walker) visit(path string, token t.Token) {
+	if w.err != nil {
+		return
+	}
+
+	if token.IsRightBrace() || token.IsRightBracket() {
+		closed := w.stack[len(w.stack)-1]
+		w.stack = w.stack[:len(w.stack)-1]
+
+		if !closed.resolved {
+			w.resolve(closed, "")
+		}
+		w.flush()
+		return
+	}
+
+	segments, err := paths.Split(path)
+
+	if err != nil {
+		w.err = err
+		return
+	}
+
+	var parent *frame
+
+	if len(w.stack) > 0 {
+		parent = w.stack[len(w.stack)-1]
+	}
+
+	e := &event{side: w.side, parent: parent, path: segments, token: token}
+
+	if len(segments) > 0 {
+		e.segment = segments[len(segments)-1]
+	}
+
+	if token.IsLeftBrace() || token.IsLeftBracket() {
+		f := &frame{parent: parent, segment: e.segment, depth: len(segments), resolved: true, object: token.IsLeftBrace()}
+
+		if parent != nil && !parent.object {
+			for _, k := range w.keys {
+				if k.elements.MatchSegments(segments) {
+					f.keyPath = k.key
+					f.resolved = false
+					w.unresolved++
+					break
+				}
+			}
+		}
+
+		e.frame = f
+		w.stack = append(w.stack, f)
+	} else {
+		for _, f := range w.stack {
+			if !f.resolved && len(segments) == f.depth+len(f.keyPath) && sameSegments(segments[f.depth:], f.keyPath) {
+				w.resolve(f, format.Literal(token))
+			}
+		}
+	}
+
+	w.buffer = append(w.buffer, e)
+	w.flush()
+}
+
+// resolve sets the key of an element. Elements with no key, or with the
+// key of another, are known by their position.
+func (w *walker) resolve(f *frame, key string) {
+	array := f.parent
+
+	if array.keys == nil {
+		array.keys = map[string]bool{}
+	}
+	if key != "" && !array.keys[key] {
+		array.keys[key] = true
+		f.key = key
+	}
+
+	f.resolved = true
+	w.unresolved--
+}
+
+func (w *walker) flush() {
+	if w.unresolved > 0 {
+		return
+	}
+
+	for _, e := range w.buffer {
+		if e.frame != nil {
+			e.id = e.frame.identity()
+		} else if len(e.path) > 0 {
+			e.id = e.parent.identity() + segmentID(e.segment, "")
+		}
+
+		select {
+		case w.events <- e:
+		case <-w.done:
+		}
+	}
+
+	clear(w.buffer)
+	w.buffer = w.buffer[:0]
+}
+
+func sameSegments(a []paths.Segment, b []paths.Segment) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].IsKey() != b[i].IsKey() || a[i].Key != b[i].Key || a[i].Index != b[i].Index {
+			return false
+		}
+	}
+	return true
+}
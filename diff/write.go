@@ -0,0 +1,136 @@
+package diff
+
+import (
+	"bufio"
+	"io"
+	"sort"
+
+	"github.com/rodic/jmatch/paths"
+)
+
+// WriteText writes a line per change, in the order given:
+//
+//	~ .a.b: 1 -> 2
+//	- .c: "x"
+//	+ .d: {"e":true}
+func WriteText(w io.Writer, changes []Change) error {
+	out := bufio.NewWriter(w)
+
+	for _, c := range changes {
+		switch c.Kind {
+		case Added:
+			out.WriteString("+ " + c.Path + ": " + c.New + "\n")
+		case Removed:
+			out.WriteString("- " + c.Path + ": " + c.Old + "\n")
+		default:
+			out.WriteString("~ " + c.Path + ": " + c.Old + " -> " + c.New + "\n")
+		}
+	}
+	return out.Flush()
+}
+
+// WritePatch writes the changes as an RFC 6902 JSON Patch turning the
+// left document into the right one, an operation per line. Operations
+// are ordered so that the positions in arrays hold as they are applied:
+// values are replaced and object keys added and removed first, then
+// elements are removed from arrays, the last ones first, and added
+// last. Elements of arrays matched by key keep the order of the left
+// document.
+func WritePatch(w io.Writer, changes []Change) error {
+	var replaced, removed, added []Change
+
+	for _, c := range changes {
+		last := len(c.pointer) - 1
+
+		switch {
+		case c.Kind == Changed || last < 0 || c.pointer[last].IsKey():
+			replaced = append(replaced, c)
+		case c.Kind == Removed:
+			removed = append(removed, c)
+		default:
+			added = append(added, c)
+		}
+	}
+
+	// the elements removed from each array, by the pointer of the array
+	removals := map[string][]int{}
+
+	for i := len(removed) - 1; i >= 0; i-- {
+		last := len(removed[i].pointer) - 1
+		array := paths.Pointer(removed[i].pointer[:last])
+		removals[array] = append(removals[array], removed[i].pointer[last].Index)
+	}
+
+	// the elements added are placed from the deepest up, so that the ones
+	// around them don't move
+	sort.SliceStable(added, func(i, j int) bool {
+		return len(added[i].pointer) > len(added[j].pointer)
+	})
+
+	out := bufio.NewWriter(w)
+	out.WriteRune('[')
+
+	first := true
+
+	operation := func(op string, pointer []paths.Segment, value string) {
+		if !first {
+			out.WriteRune(',')
+		}
+		first = false
+
+		out.WriteString("\n  {\"op\":\"" + op + "\",\"path\":" + paths.Quote(paths.Pointer(pointer)))
+
+		if value != "" {
+			out.WriteString(",\"value\":" + value)
+		}
+		out.WriteRune('}')
+	}
+
+	for _, c := range replaced {
+		switch c.Kind {
+		case Changed:
+			operation("replace", c.pointer, c.New)
+		case Removed:
+			operation("remove", c.pointer, "")
+		default:
+			operation("add", c.pointer, c.New)
+		}
+	}
+
+	for i := len(removed) - 1; i >= 0; i-- {
+		operation("remove", removed[i].pointer, "")
+	}
+
+	for _, c := range added {
+		operation("add", shift(c.pointer, removals), c.New)
+	}
+
+	if !first {
+		out.WriteRune('\n')
+	}
+	out.WriteString("]\n")
+	return out.Flush()
+}
+
+// shift moves the positions in the path back by the number of elements
+// removed before them, the last segment aside.
+func shift(pointer []paths.Segment, removals map[string][]int) []paths.Segment {
+	shifted := make([]paths.Segment, len(pointer))
+	copy(shifted, pointer)
+
+	for i := 0; i < len(pointer)-1; i++ {
+		if !pointer[i].IsIndex() {
+			continue
+		}
+
+		before := 0
+
+		for _, index := range removals[paths.Pointer(pointer[:i])] {
+			if index < pointer[i].Index {
+				before++
+			}
+		}
+		shifted[i] = paths.IndexSegment(pointer[i].Index - before)
+	}
+	return shifted
+}
@@ -0,0 +1,103 @@
+package format
+
+import (
+	"fmt"
+	"hash"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	c "github.com/rodic/jmatch/common"
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+// Canonical writes the JSON value read in the RFC 8785 JSON
+// Canonicalization Scheme: no whitespace, the members of objects sorted by
+// the UTF-16 code units of their keys, numbers written the way JavaScript
+// does and strings escaped as little as possible. Two texts holding the
+// same value come out as the same bytes, which makes them fit for signing
+// and hashing.
+//
+// The input must hold a single value of strict JSON, as read with
+// jmatch.WithStrict, with unique keys and numbers in the range of a
+// float64. Nothing follows the value in the output, not even a new line. Every object is held in memory until it is closed, with all
+// that is nested in it, to sort its members; only the elements of arrays
+// outside of any object are written as they are read, so on error what
+// was written so far is partial and not valid JSON.
+func Canonical(r io.Reader, w io.Writer) error {
+	return reformat(r, w, style{compact: true, canonical: true}, nil)
+}
+
+// CanonicalHash writes the canonical form of the JSON value read, as
+// Canonical does, to the hash and returns its sum:
+//
+//	sum, err := format.CanonicalHash(r, sha256.New())
+func CanonicalHash(r io.Reader, h hash.Hash) ([]byte, error) {
+	if err := Canonical(r, h); err != nil {
+		return nil, err
+	}
+	return h.Sum(nil), nil
+}
+
+// lessUTF16 compares keys by their UTF-16 code units, as JavaScript sorts
+// strings. It differs from comparing bytes for the characters from U+E000
+// to U+FFFF, which come after those encoded as surrogate pairs.
+func lessUTF16(a string, b string) bool {
+	ua, ub := utf16.Encode([]rune(a)), utf16.Encode([]rune(b))
+
+	for i := 0; i < len(ua) && i < len(ub); i++ {
+		if ua[i] != ub[i] {
+			return ua[i] < ub[i]
+		}
+	}
+	return len(ua) < len(ub)
+}
+
+// canonicalQuote escapes only quotes, backslashes and control
+// characters, the last ones with the short escapes when there are.
+func canonicalQuote(s string) string {
+	var quoted strings.Builder
+
+	quoted.WriteRune('"')
+
+	for _, r := range s {
+		switch r {
+		case '"':
+			quoted.WriteString(`\"`)
+		case '\\':
+			quoted.WriteString(`\\`)
+		case '\n':
+			quoted.WriteString(`\n`)
+		case '\r':
+			quoted.WriteString(`\r`)
+		case '\t':
+			quoted.WriteString(`\t`)
+		case '\b':
+			quoted.WriteString(`\b`)
+		case '\f':
+			quoted.WriteString(`\f`)
+		default:
+			if r < 0x20 {
+				fmt.Fprintf(&quoted, `\u%04x`, r)
+			} else {
+				quoted.WriteRune(r)
+			}
+		}
+	}
+
+	quoted.WriteRune('"')
+	return quoted.String()
+}
+
+// canonicalNumber writes the number the way JavaScript does, failing for
+// the numbers a float64 cannot hold.
+func canonicalNumber(token t.Token) (string, error) {
+	n, err := strconv.ParseFloat(token.Value, 64)
+
+	if err != nil || math.IsInf(n, 0) {
+		return "", c.NumberRangeErr{Number: token.Value, Line: token.Line, Column: token.Column}
+	}
+	return shortestNumber(token.Value), nil
+}
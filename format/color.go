@@ -0,0 +1,178 @@
+package format
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/rodic/jmatch/paths"
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+// Colors holds the ANSI escape sequences the parts of the output are
+// written in. Parts with no sequence are written as they are.
+type Colors struct {
+	File    string
+	Key     string
+	Index   string
+	String  string
+	Number  string
+	Boolean string
+	Null    string
+	// Match is the color of the parts of values reported by a Highlighter.
+	Match string
+}
+
+// DefaultColors are the colors of the jmatch command, close to those of
+// jq for values and of grep for file names and matches.
+var DefaultColors = Colors{
+	File:    "\x1b[35m",
+	Key:     "\x1b[1;34m",
+	Index:   "\x1b[34m",
+	String:  "\x1b[32m",
+	Number:  "\x1b[33m",
+	Boolean: "\x1b[36m",
+	Null:    "\x1b[1;30m",
+	Match:   "\x1b[1;31m",
+}
+
+const reset = "\x1b[0m"
+
+// Highlighter returns the start and end offsets of the parts of the value
+// to highlight, the way regexp.Regexp.FindAllStringIndex does.
+type Highlighter func(value string) [][]int
+
+// ColorWriter is implemented by the writers able to color their output.
+// Machine readable formats, such as json and csv, are never colored.
+type ColorWriter interface {
+	Writer
+	// SetColors colors what is written next. Parts of values reported by
+	// highlight, unless it is nil, are written in the Match color.
+	SetColors(colors Colors, highlight Highlighter)
+}
+
+// painter colors the output of the writers embedding it. The zero painter
+// writes everything as it is.
+type painter struct {
+	colors    Colors
+	highlight Highlighter
+}
+
+func (p *painter) SetColors(colors Colors, highlight Highlighter) {
+	p.colors = colors
+	p.highlight = highlight
+}
+
+func (p *painter) paint(color string, text string) string {
+	if color == "" || text == "" {
+		return text
+	}
+	return color + text + reset
+}
+
+// prefix writes the file name and a colon, if the name is set.
+func (p *painter) prefix(file string) string {
+	if file == "" {
+		return ""
+	}
+	return p.paint(p.colors.File, file) + ":"
+}
+
+// path colors the keys and indexes of a jq path. Paths that can't be read
+// are written as they are.
+func (p *painter) path(path string) string {
+	if p.colors.Key == "" && p.colors.Index == "" {
+		return path
+	}
+
+	segments, err := paths.Split(path)
+
+	if err != nil || len(segments) == 0 {
+		return path
+	}
+
+	var painted strings.Builder
+
+	if segments[0].IsIndex() {
+		painted.WriteRune('.')
+	}
+
+	for _, s := range segments {
+		if s.IsIndex() {
+			painted.WriteRune('[')
+			painted.WriteString(p.paint(p.colors.Index, strconv.Itoa(s.Index)))
+			painted.WriteRune(']')
+		} else {
+			painted.WriteRune('.')
+			painted.WriteString(p.paint(p.colors.Key, strings.TrimPrefix(paths.Key(s.Key), ".")))
+		}
+	}
+	return painted.String()
+}
+
+// value writes the value in the color of its type, as a JSON literal if
+// quote is set and as it is otherwise, with the highlighted parts in the
+// Match color.
+func (p *painter) value(token t.Token, quote bool) string {
+	color := p.typeColor(token)
+	quote = quote && token.IsString()
+
+	var parts [][]int
+
+	if p.highlight != nil {
+		parts = p.highlight(token.Value)
+	}
+
+	if len(parts) == 0 {
+		if quote {
+			return p.paint(color, Literal(token))
+		}
+		return p.paint(color, token.Value)
+	}
+
+	// the parts are quoted one by one, escapes don't span them
+	escape := func(s string) string {
+		if !quote {
+			return s
+		}
+		quoted := paths.Quote(s)
+		return quoted[1 : len(quoted)-1]
+	}
+
+	var painted strings.Builder
+
+	if quote {
+		painted.WriteString(p.paint(color, `"`))
+	}
+
+	start := 0
+
+	for _, part := range parts {
+		if part[0] == part[1] {
+			continue
+		}
+		painted.WriteString(p.paint(color, escape(token.Value[start:part[0]])))
+		painted.WriteString(p.paint(p.colors.Match, escape(token.Value[part[0]:part[1]])))
+		start = part[1]
+	}
+
+	painted.WriteString(p.paint(color, escape(token.Value[start:])))
+
+	if quote {
+		painted.WriteString(p.paint(color, `"`))
+	}
+	return painted.String()
+}
+
+func (p *painter) typeColor(token t.Token) string {
+	switch {
+	case token.IsString():
+		return p.colors.String
+	case token.IsNumber():
+		return p.colors.Number
+	case token.IsBoolean():
+		return p.colors.Boolean
+	case token.IsNull():
+		return p.colors.Null
+	}
+	return ""
+}
@@ -0,0 +1,108 @@
+package format
+
+import (
+	"bufio"
+	"io"
+	"strings"
+
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+// ContextWriter writes the containers passed to a jmatch.ContextMatcher
+// as indented JSON, each below a line with its path:
+//
+//	.orders[1]
+//	{
+//	  "id": 2,
+//	  "status": "failed"
+//	}
+//
+// The path line starts with the file name and a colon once it is set.
+type ContextWriter struct {
+	painter
+	w    *bufio.Writer
+	file string
+}
+
+func NewContext(w io.Writer) *ContextWriter {
+	return &ContextWriter{w: bufio.NewWriter(w)}
+}
+
+// Write writes the container made of the tokens. The matched values,
+// found by their position, are written in the Match color, or with the
+// highlighted parts in it if there is a Highlighter.
+func (f *ContextWriter) Write(path string, tokens []t.Token, matches []t.Token) error {
+	matched := make(map[[2]int]bool, len(matches))
+
+	for _, m := range matches {
+		matched[[2]int{m.Line, m.Column}] = true
+	}
+
+	f.w.WriteString(f.prefix(f.file))
+	f.w.WriteString(f.path(path))
+	f.w.WriteRune('\n')
+
+	depth := 0
+
+	newLine := func() {
+		f.w.WriteRune('\n')
+		f.w.WriteString(strings.Repeat("  ", depth))
+	}
+
+	for i := 0; i < len(tokens); i++ {
+		token := tokens[i]
+		next := t.Token{}
+
+		if i+1 < len(tokens) {
+			next = tokens[i+1]
+		}
+
+		switch {
+		case token.IsLeftBrace() || token.IsLeftBracket():
+			f.w.WriteString(token.Value)
+
+			if next.IsRightBrace() || next.IsRightBracket() {
+				f.w.WriteString(next.Value)
+				i++
+			} else {
+				depth++
+				newLine()
+			}
+		case token.IsRightBrace() || token.IsRightBracket():
+			depth--
+			newLine()
+			f.w.WriteString(token.Value)
+		case token.IsComma():
+			f.w.WriteRune(',')
+			newLine()
+		case token.IsColon():
+			f.w.WriteString(": ")
+		case token.IsString() && next.IsColon():
+			f.w.WriteString(f.paint(f.colors.Key, Literal(token)))
+		default:
+			f.w.WriteString(f.literal(token, matched[[2]int{token.Line, token.Column}]))
+		}
+	}
+
+	f.w.WriteRune('\n')
+	return nil
+}
+
+func (f *ContextWriter) literal(token t.Token, matched bool) string {
+	switch {
+	case !matched:
+		return f.paint(f.typeColor(token), Literal(token))
+	case f.highlight != nil:
+		return f.value(token, true)
+	default:
+		return f.paint(f.colors.Match, Literal(token))
+	}
+}
+
+func (f *ContextWriter) SetFile(name string) {
+	f.file = name
+}
+
+func (f *ContextWriter) Flush() error {
+	return f.w.Flush()
+}
@@ -0,0 +1,107 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/rodic/jmatch/paths"
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+type counter struct {
+	painter
+	w     io.Writer
+	file  string
+	named bool
+	count int
+	err   error
+}
+
+// NewCount writes the number of values, the way grep -c does. Containers
+// are not counted. Once the file name is set, a count is written per
+// file, after its name and a colon, when the next name is set and on
+// Flush.
+func NewCount(w io.Writer) Writer {
+	return &counter{w: w}
+}
+
+func (f *counter) Write(path string, token t.Token) error {
+	if !token.IsContainer() {
+		f.count++
+	}
+	return f.err
+}
+
+func (f *counter) SetFile(name string) {
+	if f.named {
+		f.write()
+	}
+	f.file = name
+	f.named = true
+	f.count = 0
+}
+
+func (f *counter) write() {
+	if f.err == nil {
+		_, f.err = fmt.Fprintf(f.w, "%s%d\n", f.prefix(f.file), f.count)
+	}
+}
+
+func (f *counter) Flush() error {
+	f.write()
+	return f.err
+}
+
+type pathCounter struct {
+	w      io.Writer
+	counts map[string]int
+	paths  []string // in the order they were first seen
+}
+
+// NewPathCount writes how many values there are per path, the indexes of
+// paths left out so that the elements of arrays are counted together:
+//
+//	.users[].name	2
+//
+// Paths are written on Flush, the most frequent first. Containers are not
+// counted.
+func NewPathCount(w io.Writer) Writer {
+	return &pathCounter{w: w, counts: map[string]int{}}
+}
+
+func (f *pathCounter) Write(path string, token t.Token) error {
+	if token.IsContainer() {
+		return nil
+	}
+
+	path = collapse(path)
+
+	if _, ok := f.counts[path]; !ok {
+		f.paths = append(f.paths, path)
+	}
+	f.counts[path]++
+	return nil
+}
+
+func (f *pathCounter) Flush() error {
+	sort.SliceStable(f.paths, func(i, j int) bool {
+		return f.counts[f.paths[i]] > f.counts[f.paths[j]]
+	})
+
+	for _, path := range f.paths {
+		if _, err := fmt.Fprintf(f.w, "%s\t%d\n", path, f.counts[path]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// collapse leaves the indexes out of a jq path. Paths in other notations
+// are kept as they are.
+func collapse(path string) string {
+	if collapsed, err := paths.Collapse(path); err == nil {
+		return collapsed
+	}
+	return path
+}
@@ -0,0 +1,65 @@
+package format
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+type csvWriter struct {
+	w        *csv.Writer
+	header   bool
+	file     string
+	withFile bool // whether records start with the file column
+}
+
+// NewCSV writes a record per value with the path, type, value and position
+// of the value, below a header naming the columns. Strings are written
+// unquoted. Containers are skipped. A leading file column is added if the
+// file name is set before the first value.
+func NewCSV(w io.Writer) Writer {
+	return &csvWriter{w: csv.NewWriter(w)}
+}
+
+func (f *csvWriter) Write(path string, token t.Token) error {
+	if token.IsContainer() {
+		return nil
+	}
+
+	if !f.header {
+		f.header = true
+
+		if err := f.w.Write(f.record("file", "path", "type", "value", "line", "col")); err != nil {
+			return err
+		}
+	}
+
+	return f.w.Write(f.record(
+		f.file,
+		path,
+		TypeName(token),
+		token.Value,
+		strconv.Itoa(token.Line),
+		strconv.Itoa(token.Column),
+	))
+}
+
+// record drops the file column unless the file name was set in time.
+func (f *csvWriter) record(file string, columns ...string) []string {
+	if !f.withFile {
+		return columns
+	}
+	return append([]string{file}, columns...)
+}
+
+func (f *csvWriter) SetFile(name string) {
+	f.file = name
+	f.withFile = f.withFile || !f.header
+}
+
+func (f *csvWriter) Flush() error {
+	f.w.Flush()
+	return f.w.Error()
+}
@@ -0,0 +1,347 @@
+package format
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/rodic/jmatch/paths"
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+type nodeKind int
+
+const (
+	unset nodeKind = iota
+	object
+	array
+	scalar
+)
+
+// node is a part of the document being rebuilt.
+type node struct {
+	kind     nodeKind
+	token    t.Token // of scalars
+	keys     []string
+	children map[string]*node
+	elems    []*node // nil for the elements missing from the input
+}
+
+// maxMissingElements is the number of elements an index may skip past the
+// end of its array, the elements between being written as null.
+const maxMissingElements = 1 << 20
+
+// become turns the node into one of the given kind. Only unset nodes can
+// change kind, a value written twice as two different kinds being an error.
+func (n *node) become(kind nodeKind, path []paths.Segment) error {
+	if n.kind == kind {
+		return nil
+	}
+	if n.kind != unset {
+		return fmt.Errorf("conflicting types at %s", paths.Join(path))
+	}
+
+	*n = node{kind: kind}
+
+	if kind == object {
+		n.children = map[string]*node{}
+	}
+	return nil
+}
+
+func (n *node) child(segment paths.Segment, path []paths.Segment) (*node, error) {
+	if segment.IsIndex() {
+		if missing := segment.Index - len(n.elems); missing > maxMissingElements {
+			return nil, fmt.Errorf("index %d at %s leaves more than %d elements missing",
+				segment.Index, paths.Join(path), maxMissingElements)
+		}
+		for len(n.elems) <= segment.Index {
+			n.elems = append(n.elems, nil)
+		}
+		if n.elems[segment.Index] == nil {
+			n.elems[segment.Index] = &node{}
+		}
+		return n.elems[segment.Index], nil
+	}
+
+	child, ok := n.children[segment.Key]
+
+	if !ok {
+		child = &node{}
+		n.children[segment.Key] = child
+		n.keys = append(n.keys, segment.Key)
+	}
+	return child, nil
+}
+
+type document struct {
+	w    *bufio.Writer
+	root *node
+}
+
+// NewDocument rebuilds the JSON document the values come from and writes
+// it, indented, on Flush. Keys keep the order they are first seen in and
+// array elements missing from the input are written as null, an index
+// skipping more than a million of them being an error. Paths are expected
+// in jq notation.
+func NewDocument(w io.Writer) Writer {
+	return &document{w: bufio.NewWriter(w), root: &node{}}
+}
+
+func (d *document) Write(path string, token t.Token) error {
+	var kind nodeKind
+
+	switch {
+	case token.IsLeftBrace():
+		kind = object
+	case token.IsLeftBracket():
+		kind = array
+	case token.IsContainer():
+		return nil
+	default:
+		kind = scalar
+	}
+
+	segments, err := paths.Split(path)
+
+	if err != nil {
+		return err
+	}
+
+	current := d.root
+
+	for i, segment := range segments {
+		parentKind := object
+
+		if segment.IsIndex() {
+			parentKind = array
+		}
+
+		if err := current.become(parentKind, segments[:i]); err != nil {
+			return err
+		}
+		if current, err = current.child(segment, segments[:i]); err != nil {
+			return err
+		}
+	}
+
+	if err := current.become(kind, segments); err != nil {
+		return err
+	}
+	if kind == scalar {
+		current.token = token
+	}
+	return nil
+}
+
+func (d *document) Flush() error {
+	if d.root.kind == unset {
+		return nil
+	}
+
+	d.write(d.root, "")
+	d.w.WriteRune('\n')
+
+	return d.w.Flush()
+}
+
+func (d *document) write(n *node, indent string) {
+	inner := indent + "  "
+
+	switch n.kind {
+	case object:
+		if len(n.keys) == 0 {
+			d.w.WriteString("{}")
+			return
+		}
+
+		d.w.WriteString("{\n")
+
+		for i, key := range n.keys {
+			d.w.WriteString(inner)
+			d.w.WriteString(paths.Quote(key))
+			d.w.WriteString(": ")
+			d.write(n.children[key], inner)
+
+			if i < len(n.keys)-1 {
+				d.w.WriteRune(',')
+			}
+			d.w.WriteRune('\n')
+		}
+
+		d.w.WriteString(indent)
+		d.w.WriteRune('}')
+	case array:
+		if len(n.elems) == 0 {
+			d.w.WriteString("[]")
+			return
+		}
+
+		d.w.WriteString("[\n")
+
+		for i, elem := range n.elems {
+			d.w.WriteString(inner)
+
+			if elem == nil {
+				d.w.WriteString("null")
+			} else {
+				d.write(elem, inner)
+			}
+
+			if i < len(n.elems)-1 {
+				d.w.WriteRune(',')
+			}
+			d.w.WriteRune('\n')
+		}
+
+		d.w.WriteString(indent)
+		d.w.WriteRune(']')
+	case scalar:
+		d.w.WriteString(Literal(n.token))
+	default:
+		d.w.WriteString("null")
+	}
+}
+
+// Ungron reads the lines written by the tsv and gron writers and writes
+// the document they describe. Empty lines are skipped.
+func Ungron(r io.Reader, w io.Writer) error {
+	doc := NewDocument(w)
+	lines := bufio.NewScanner(r)
+	lines.Buffer(make([]byte, 0, 64*1024), 1<<30)
+
+	for number := 1; lines.Scan(); number++ {
+		line := strings.TrimSpace(lines.Text())
+
+		if line == "" {
+			continue
+		}
+
+		path, token, err := ParseLine(line)
+
+		if err == nil {
+			err = doc.Write(path, token)
+		}
+		if err != nil {
+			return fmt.Errorf("line %d: %w", number, err)
+		}
+	}
+
+	if err := lines.Err(); err != nil {
+		return err
+	}
+	return doc.Flush()
+}
+
+// ParseLine reads a line written by the tsv or gron writer back into the
+// path and the value.
+func ParseLine(line string) (string, t.Token, error) {
+	var path, literal string
+
+	if strings.HasPrefix(line, "json") {
+		statement, ok := strings.CutSuffix(line, ";")
+		if !ok {
+			return "", t.Token{}, fmt.Errorf("invalid statement %q, expected ';' at the end", line)
+		}
+
+		segments, rest, err := splitGronPath(statement)
+		if err != nil {
+			return "", t.Token{}, err
+		}
+
+		value, ok := strings.CutPrefix(rest, " = ")
+		if !ok {
+			return "", t.Token{}, fmt.Errorf("invalid statement %q, expected ' = ' after the path", line)
+		}
+		path, literal = paths.Join(segments), value
+	} else {
+		var ok bool
+
+		if path, literal, ok = strings.Cut(line, "\t"); !ok {
+			return "", t.Token{}, fmt.Errorf("invalid line %q, expected a tab after the path", line)
+		}
+	}
+
+	token, err := parseLiteral(literal)
+	return path, token, err
+}
+
+// parseLiteral reads a JSON scalar, {} or [].
+func parseLiteral(literal string) (t.Token, error) {
+	scanner := t.NewScanner(strings.NewReader(literal), t.Config{BufferSize: len(literal) + 1})
+
+	var tokens []t.Token
+
+	for {
+		token, err := scanner.Scan()
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return t.Token{}, err
+		}
+		tokens = append(tokens, token.Copy())
+	}
+
+	switch {
+	case len(tokens) == 1 && !tokens[0].IsContainer() && !tokens[0].IsColon() && !tokens[0].IsComma():
+		return tokens[0], nil
+	case len(tokens) == 2 && tokens[0].IsLeftBrace() && tokens[1].IsRightBrace():
+		return tokens[0], nil
+	case len(tokens) == 2 && tokens[0].IsLeftBracket() && tokens[1].IsRightBracket():
+		return tokens[0], nil
+	}
+	return t.Token{}, fmt.Errorf("invalid value %q, expected a JSON scalar, {} or []", literal)
+}
+
+// splitGronPath reads the path written by GronPath at the start of the
+// statement and returns what follows it.
+func splitGronPath(statement string) ([]paths.Segment, string, error) {
+	rest, ok := strings.CutPrefix(statement, "json")
+
+	if !ok {
+		return nil, "", fmt.Errorf("invalid statement %q, expected json at the start", statement)
+	}
+
+	segments := []paths.Segment{}
+
+	for rest != "" && rest[0] != ' ' {
+		switch {
+		case strings.HasPrefix(rest, `["`):
+			key, n, err := paths.Unquote(rest[1:])
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid statement %q: %w", statement, err)
+			}
+			if !strings.HasPrefix(rest[1+n:], "]") {
+				return nil, "", fmt.Errorf("invalid statement %q, expected ] after %s", statement, rest[1:1+n])
+			}
+			segments = append(segments, paths.KeySegment(key))
+			rest = rest[n+2:]
+		case strings.HasPrefix(rest, "["):
+			end := strings.IndexByte(rest, ']')
+			index, err := strconv.Atoi(rest[1:max(end, 1)])
+			if end < 0 || err != nil || index < 0 {
+				return nil, "", fmt.Errorf("invalid statement %q, expected an index in %s", statement, rest)
+			}
+			segments = append(segments, paths.IndexSegment(index))
+			rest = rest[end+1:]
+		case strings.HasPrefix(rest, "."):
+			end := strings.IndexAny(rest[1:], ".[ ")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			key := rest[1 : end+1]
+			if !paths.IsIdentifier(key) {
+				return nil, "", fmt.Errorf("invalid statement %q, invalid key %q", statement, key)
+			}
+			segments = append(segments, paths.KeySegment(key))
+			rest = rest[end+1:]
+		default:
+			return nil, "", fmt.Errorf("invalid statement %q, unexpected %s", statement, rest)
+		}
+	}
+	return segments, rest, nil
+}
@@ -0,0 +1,92 @@
+// Package format writes the values passed to a jmatch matcher in the
+// formats of the jmatch command. Paths are expected in jq notation, the
+// default of jmatch.Match:
+//
+//	w := format.NewGron(os.Stdout)
+//	err := jmatch.Match(r, func(path string, token jmatch.Token) {
+//		w.Write(path, token)
+//	})
+//	w.Flush()
+package format
+
+import (
+	"fmt"
+	"io"
+	"sort"
+
+	"github.com/rodic/jmatch/paths"
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+// Writer writes values one at a time.
+type Writer interface {
+	Write(path string, token t.Token) error
+	// Flush writes out whatever the writer holds back.
+	Flush() error
+}
+
+// FileWriter is implemented by the writers able to tell which file the
+// values come from, the way grep -H does.
+type FileWriter interface {
+	Writer
+	// SetFile names the file of the values written next.
+	SetFile(name string)
+}
+
+var writers = map[string]func(io.Writer) Writer{
+	"tsv":      NewTSV,
+	"gron":     NewGron,
+	"json":     NewNDJSON,
+	"csv":      NewCSV,
+	"values":   NewValues,
+	"document": NewDocument,
+}
+
+// Names lists the formats known to New.
+func Names() []string {
+	names := make([]string, 0, len(writers))
+
+	for name := range writers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// New returns the writer of the format with the given name.
+func New(name string, w io.Writer) (Writer, error) {
+	newWriter, ok := writers[name]
+
+	if !ok {
+		return nil, fmt.Errorf("unknown format %q", name)
+	}
+	return newWriter(w), nil
+}
+
+// Literal writes the value as a JSON literal.
+func Literal(token t.Token) string {
+	if token.IsString() {
+		return paths.Quote(token.Value)
+	}
+	return token.Value
+}
+
+// TypeName returns the JSON type of the value, or of the container the
+// token opens or closes.
+func TypeName(token t.Token) string {
+	switch {
+	case token.IsString():
+		return "string"
+	case token.IsNumber():
+		return "number"
+	case token.IsBoolean():
+		return "boolean"
+	case token.IsNull():
+		return "null"
+	case token.IsLeftBrace(), token.IsRightBrace():
+		return "object"
+	case token.IsLeftBracket(), token.IsRightBracket():
+		return "array"
+	}
+	return ""
+}
@@ -0,0 +1,625 @@
+package format
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	c "github.com/rodic/jmatch/common"
+	z "github.com/rodic/jmatch/tokenizer"
+)
+
+type value struct {
+	path  string
+	token z.Token
+}
+
+// {"a": {"b c": ["x\"y", 1.5, true, null]}}
+var input = []value{
+	{path: ".", token: z.NewLeftBraceToken(1, 1)},
+	{path: ".a", token: z.NewLeftBraceToken(1, 7)},
+	{path: `.a."b c"`, token: z.NewLeftBracketToken(1, 15)},
+	{path: `.a."b c"[0]`, token: z.NewStringToken(`x"y`, 1, 16)},
+	{path: `.a."b c"[1]`, token: z.NewNumberToken("1.5", 1, 24)},
+	{path: `.a."b c"[2]`, token: z.NewBooleanToken("true", 1, 29)},
+	{path: `.a."b c"[3]`, token: z.NewNullToken(1, 35)},
+	{path: `.a."b c"`, token: z.NewRightBracketToken(1, 39)},
+	{path: ".a", token: z.NewRightBraceToken(1, 40)},
+	{path: ".", token: z.NewRightBraceToken(1, 41)},
+}
+
+func TestWriters(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{name: "tsv",
+			expected: `.a."b c"[0]	"x\"y"
+.a."b c"[1]	1.5
+.a."b c"[2]	true
+.a."b c"[3]	null
+`},
+		{name: "gron",
+			expected: `json = {};
+json.a = {};
+json.a["b c"] = [];
+json.a["b c"][0] = "x\"y";
+json.a["b c"][1] = 1.5;
+json.a["b c"][2] = true;
+json.a["b c"][3] = null;
+`},
+		{name: "json",
+			expected: `{"path":".a.\"b c\"[0]","type":"string","value":"x\"y","line":1,"col":16}
+{"path":".a.\"b c\"[1]","type":"number","value":1.5,"line":1,"col":24}
+{"path":".a.\"b c\"[2]","type":"boolean","value":true,"line":1,"col":29}
+{"path":".a.\"b c\"[3]","type":"null","value":null,"line":1,"col":35}
+`},
+		{name: "csv",
+			expected: `path,type,value,line,col
+".a.""b c""[0]",string,"x""y",1,16
+".a.""b c""[1]",number,1.5,1,24
+".a.""b c""[2]",boolean,true,1,29
+".a.""b c""[3]",null,null,1,35
+`},
+		{name: "values",
+			expected: `x"y
+1.5
+true
+null
+`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			w, err := New(tc.name, &out)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			for _, v := range input {
+				if err := w.Write(v.path, v.token); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			if out.String() != tc.expected {
+				t.Errorf("Expected '%s', got '%s' instead\n", tc.expected, out.String())
+			}
+		})
+	}
+
+	if _, err := New("xml", &bytes.Buffer{}); err == nil {
+		t.Error("Expected an error for an unknown format")
+	}
+}
+
+func TestSetFile(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected string
+	}{
+		{name: "tsv",
+			expected: "a.json:.a.\"b c\"[1]\t1.5\nb.json:.a.\"b c\"[3]\tnull\n"},
+		{name: "values",
+			expected: "a.json:1.5\nb.json:null\n"},
+		{name: "json",
+			expected: `{"file":"a.json","path":".a.\"b c\"[1]","type":"number","value":1.5,"line":1,"col":24}
+{"file":"b.json","path":".a.\"b c\"[3]","type":"null","value":null,"line":1,"col":35}
+`},
+		{name: "csv",
+			expected: `file,path,type,value,line,col
+a.json,".a.""b c""[1]",number,1.5,1,24
+b.json,".a.""b c""[3]",null,null,1,35
+`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			w, err := New(tc.name, &out)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			fw := w.(FileWriter)
+
+			fw.SetFile("a.json")
+			w.Write(input[4].path, input[4].token)
+			fw.SetFile("b.json")
+			w.Write(input[6].path, input[6].token)
+
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			if out.String() != tc.expected {
+				t.Errorf("Expected '%s', got '%s' instead\n", tc.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestColors(t *testing.T) {
+	colors := Colors{File: "<f>", Key: "<k>", Index: "<i>", String: "<s>", Number: "<n>", Boolean: "<b>", Null: "<0>", Match: "<m>"}
+	r := "\x1b[0m"
+
+	highlight := func(value string) [][]int {
+		if i := strings.Index(value, `"`); i >= 0 {
+			return [][]int{{i, i + 1}}
+		}
+		return nil
+	}
+
+	testCases := []struct {
+		name      string
+		highlight Highlighter
+		expected  string
+	}{
+		{name: "tsv",
+			expected: "<f>a.json" + r + ":.<k>a" + r + ".<k>\"b c\"" + r + "[<i>0" + r + "]\t<s>\"x\\\"y\"" + r + "\n" +
+				"<f>a.json" + r + ":.<k>a" + r + ".<k>\"b c\"" + r + "[<i>3" + r + "]\t<0>null" + r + "\n"},
+		{name: "tsv",
+			highlight: highlight,
+			expected: "<f>a.json" + r + ":.<k>a" + r + ".<k>\"b c\"" + r + "[<i>0" + r + "]\t<s>\"" + r + "<s>x" + r + "<m>\\\"" + r + "<s>y" + r + "<s>\"" + r + "\n" +
+				"<f>a.json" + r + ":.<k>a" + r + ".<k>\"b c\"" + r + "[<i>3" + r + "]\t<0>null" + r + "\n"},
+		{name: "gron",
+			expected: "<f>a.json" + r + ":json.<k>a" + r + "[<k>\"b c\"" + r + "][<i>0" + r + "] = <s>\"x\\\"y\"" + r + ";\n" +
+				"<f>a.json" + r + ":json.<k>a" + r + "[<k>\"b c\"" + r + "][<i>3" + r + "] = <0>null" + r + ";\n"},
+		{name: "values",
+			highlight: highlight,
+			expected:  "<f>a.json" + r + ":<s>x" + r + "<m>\"" + r + "<s>y" + r + "\n<f>a.json" + r + ":<0>null" + r + "\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			w, err := New(tc.name, &out)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			w.(FileWriter).SetFile("a.json")
+			w.(ColorWriter).SetColors(colors, tc.highlight)
+			w.Write(input[3].path, input[3].token)
+			w.Write(input[6].path, input[6].token)
+
+			if out.String() != tc.expected {
+				t.Errorf("Expected %q, got %q instead\n", tc.expected, out.String())
+			}
+		})
+	}
+
+	for _, name := range []string{"json", "csv", "document"} {
+		w, _ := New(name, &bytes.Buffer{})
+
+		if _, ok := w.(ColorWriter); ok {
+			t.Errorf("Expected the %s writer not to color its output\n", name)
+		}
+	}
+}
+
+func TestContextWriter(t *testing.T) {
+	tokens := []z.Token{
+		z.NewLeftBraceToken(1, 1),
+		z.NewStringToken("id", 1, 2),
+		z.NewColonToken(1, 6),
+		z.NewNumberToken("2", 1, 8),
+		z.NewCommaToken(1, 9),
+		z.NewStringToken("tags", 1, 11),
+		z.NewColonToken(1, 17),
+		z.NewLeftBracketToken(1, 19),
+		z.NewStringToken("x", 1, 20),
+		z.NewRightBracketToken(1, 23),
+		z.NewCommaToken(1, 24),
+		z.NewStringToken("meta", 1, 26),
+		z.NewColonToken(1, 32),
+		z.NewLeftBraceToken(1, 34),
+		z.NewRightBraceToken(1, 35),
+		z.NewRightBraceToken(1, 36),
+	}
+
+	var out bytes.Buffer
+
+	w := NewContext(&out)
+	w.SetFile("a.json")
+	w.Write(".orders[1]", tokens, []z.Token{tokens[8]})
+	w.Flush()
+
+	expected := `a.json:.orders[1]
+{
+  "id": 2,
+  "tags": [
+    "x"
+  ],
+  "meta": {}
+}
+`
+
+	if out.String() != expected {
+		t.Errorf("Expected '%s', got '%s' instead\n", expected, out.String())
+	}
+
+	out.Reset()
+
+	w = NewContext(&out)
+	w.SetColors(Colors{Key: "<k>", Number: "<n>", String: "<s>", Match: "<m>"}, nil)
+	w.Write(".", tokens[7:10], []z.Token{tokens[8]})
+	w.Flush()
+
+	expected = ".\n[\n  <m>\"x\"\x1b[0m\n]\n"
+
+	if out.String() != expected {
+		t.Errorf("Expected %q, got %q instead\n", expected, out.String())
+	}
+}
+
+func TestSummaries(t *testing.T) {
+	// [{"a": "xy", "b": 3}, {"a": "xyz", "b": -1.5}, {"a": null}]
+	values := []value{
+		{path: ".", token: z.NewLeftBracketToken(1, 1)},
+		{path: ".[0].a", token: z.NewStringToken("xy", 1, 8)},
+		{path: ".[0].b", token: z.NewNumberToken("3", 1, 19)},
+		{path: ".[1].a", token: z.NewStringToken("xyz", 1, 30)},
+		{path: ".[1].b", token: z.NewNumberToken("-1.5", 1, 42)},
+		{path: ".[2].a", token: z.NewNullToken(1, 56)},
+		{path: ".", token: z.NewRightBracketToken(1, 61)},
+	}
+
+	testCases := []struct {
+		name     string
+		writer   func(io.Writer) Writer
+		expected string
+	}{
+		{name: "count",
+			writer:   NewCount,
+			expected: "5\n"},
+		{name: "countByPath",
+			writer:   NewPathCount,
+			expected: ".[].a\t3\n.[].b\t2\n"},
+		{name: "stats",
+			writer: NewStats,
+			expected: `{"path":".[].a","count":3,"types":{"string":2,"null":1},"null_ratio":0.3333,"length":{"min":2,"p50":2,"p90":3,"p99":3,"max":3}}
+{"path":".[].b","count":2,"types":{"number":2},"null_ratio":0,"min":-1.5,"max":3}
+`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			w := tc.writer(&out)
+
+			for _, v := range values {
+				if err := w.Write(v.path, v.token); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if err := w.Flush(); err != nil {
+				t.Fatal(err)
+			}
+
+			if out.String() != tc.expected {
+				t.Errorf("Expected '%s', got '%s' instead\n", tc.expected, out.String())
+			}
+		})
+	}
+
+	var out bytes.Buffer
+
+	w := NewCount(&out)
+	w.(FileWriter).SetFile("a.json")
+	w.Write(values[1].path, values[1].token)
+	w.(FileWriter).SetFile("b.json")
+	w.(FileWriter).SetFile("c.json")
+	w.Write(values[1].path, values[1].token)
+	w.Write(values[2].path, values[2].token)
+	w.Flush()
+
+	if expected := "a.json:1\nb.json:0\nc.json:2\n"; out.String() != expected {
+		t.Errorf("Expected '%s', got '%s' instead\n", expected, out.String())
+	}
+
+	// lengths past 64 are counted in buckets, percentiles rounded up to
+	// the end of theirs: 500 to 503, 900 to 911 and 990 to 991
+	out.Reset()
+	w = NewStats(&out)
+
+	for n := 1000; n > 0; n-- {
+		w.Write(".[0]", z.NewStringToken(strings.Repeat("x", n), 1, 1))
+	}
+	w.Flush()
+
+	expected := `{"path":".[]","count":1000,"types":{"string":1000},"null_ratio":0,"length":{"min":1,"p50":503,"p90":911,"p99":991,"max":1000}}` + "\n"
+
+	if out.String() != expected {
+		t.Errorf("Expected '%s', got '%s' instead\n", expected, out.String())
+	}
+}
+
+func TestGronPath(t *testing.T) {
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{path: ".", expected: "json"},
+		{path: ".[0]", expected: "json[0]"},
+		{path: `.a."b-c"[1]._d`, expected: `json.a["b-c"][1]._d`},
+	}
+
+	for _, tc := range testCases {
+		gronPath, err := GronPath(tc.path)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		if gronPath != tc.expected {
+			t.Errorf("Expected '%s', got '%s' instead\n", tc.expected, gronPath)
+		}
+	}
+
+	if _, err := GronPath("/a/0"); err == nil {
+		t.Error("Expected an error for a path not in jq notation")
+	}
+}
+
+func TestDocument(t *testing.T) {
+	var out bytes.Buffer
+
+	w := NewDocument(&out)
+
+	for _, v := range input {
+		if err := w.Write(v.path, v.token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// holes in arrays are filled with null
+	if err := w.Write(".b[2]", z.NewNumberToken("1", 1, 1)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{
+  "a": {
+    "b c": [
+      "x\"y",
+      1.5,
+      true,
+      null
+    ]
+  },
+  "b": [
+    null,
+    null,
+    1
+  ]
+}
+`
+
+	if out.String() != expected {
+		t.Errorf("Expected '%s', got '%s' instead\n", expected, out.String())
+	}
+
+	w = NewDocument(&bytes.Buffer{})
+	w.Write(".a[0]", z.NewNumberToken("1", 1, 1))
+
+	if err := w.Write(".a.b", z.NewNumberToken("1", 1, 1)); err == nil || err.Error() != "conflicting types at .a" {
+		t.Errorf("Expected conflicting types at .a, got '%v' instead\n", err)
+	}
+
+	expectedErr := "index 100000000 at .a leaves more than 1048576 elements missing"
+
+	if err := w.Write(".a[100000000]", z.NewNumberToken("1", 1, 1)); err == nil || err.Error() != expectedErr {
+		t.Errorf("Expected '%s', got '%v' instead\n", expectedErr, err)
+	}
+
+	w = NewDocument(&bytes.Buffer{})
+	w.Write(".a", z.NewNumberToken("1", 1, 1))
+
+	if err := w.Write(".a.b", z.NewNumberToken("2", 1, 1)); err == nil || err.Error() != "conflicting types at .a" {
+		t.Errorf("Expected conflicting types at .a, got '%v' instead\n", err)
+	}
+	if err := w.Write(".a", z.NewLeftBraceToken(1, 1)); err == nil || err.Error() != "conflicting types at .a" {
+		t.Errorf("Expected conflicting types at .a, got '%v' instead\n", err)
+	}
+}
+
+func TestUngron(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "tsv",
+			input:    ".a[1].\"b c\"\t\"x\"\n\n.a[0]\tnull\n.d\t-1e3\n",
+			expected: "{\n  \"a\": [\n    null,\n    {\n      \"b c\": \"x\"\n    }\n  ],\n  \"d\": -1e3\n}\n"},
+		{name: "gron",
+			input:    "json = {};\njson.a = [];\njson[\"b = c\"] = {};\njson.d[\"e\\\"f\"] = \"\\u00fc\";\n",
+			expected: "{\n  \"a\": [],\n  \"b = c\": {},\n  \"d\": {\n    \"e\\\"f\": \"ü\"\n  }\n}\n"},
+		{name: "rootScalar",
+			input:    ".\ttrue\n",
+			expected: "true\n"},
+		{name: "rootArray",
+			input:    "json = [];\njson[1] = 2;\n",
+			expected: "[\n  null,\n  2\n]\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			if err := Ungron(strings.NewReader(tc.input), &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tc.expected {
+				t.Errorf("Expected '%s', got '%s' instead\n", tc.expected, out.String())
+			}
+		})
+	}
+
+	for _, input := range []string{".a 1", ".a\t{\"b\": 1}", "json.a = 1", "json.a-b = 1;", ".a[0]\t1\n.a.b\t1", "json[x] = 1;", ".[100000000]\t1"} {
+		if err := Ungron(strings.NewReader(input), &bytes.Buffer{}); err == nil {
+			t.Errorf("Expected an error for '%s'", input)
+		}
+	}
+}
+
+func TestIndent(t *testing.T) {
+	input := `{"b": [1, 2.50, 1E3, -0, 1e-7, 1e21, 12345678901234567890, {}], "a": {"z": "é😀\n", "y": []}, "a": null}
+[true]`
+
+	testCases := []struct {
+		name     string
+		indent   string
+		compact  bool
+		opts     []Option
+		expected string
+	}{
+		{name: "indent",
+			indent: "  ",
+			expected: `{
+  "b": [
+    1,
+    2.50,
+    1E3,
+    -0,
+    1e-7,
+    1e21,
+    12345678901234567890,
+    {}
+  ],
+  "a": {
+    "z": "é😀\n",
+    "y": []
+  },
+  "a": null
+}
+[
+  true
+]
+`},
+		{name: "compact",
+			compact:  true,
+			expected: "{\"b\":[1,2.50,1E3,-0,1e-7,1e21,12345678901234567890,{}],\"a\":{\"z\":\"é😀\\n\",\"y\":[]},\"a\":null}\n[true]\n"},
+		{name: "options",
+			compact:  true,
+			opts:     []Option{SortKeys(), ASCII(), ShortestNumbers()},
+			expected: "{\"a\":{\"y\":[],\"z\":\"\\u00e9\\ud83d\\ude00\\n\"},\"a\":null,\"b\":[1,2.5,1000,0,1e-7,1e+21,12345678901234567000,{}]}\n[true]\n"},
+		{name: "sortedIndent",
+			indent: "\t",
+			opts:   []Option{SortKeys()},
+			expected: "{\n\t\"a\": {\n\t\t\"y\": [],\n\t\t\"z\": \"é😀\\n\"\n\t},\n\t\"a\": null,\n\t\"b\": [\n\t\t1,\n\t\t2.50,\n\t\t1E3,\n\t\t-0,\n" +
+				"\t\t1e-7,\n\t\t1e21,\n\t\t12345678901234567890,\n\t\t{}\n\t]\n}\n[\n\ttrue\n]\n"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+			var err error
+
+			if tc.compact {
+				err = Compact(strings.NewReader(input), &out, tc.opts...)
+			} else {
+				err = Indent(strings.NewReader(input), &out, tc.indent, tc.opts...)
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tc.expected {
+				t.Errorf("Expected %q, got %q instead\n", tc.expected, out.String())
+			}
+		})
+	}
+
+	for _, invalid := range []string{`{"a" 1}`, `[1 2]`, `{1: 2}`, `[1,`, `{"a": }`, `]`, `"abc`} {
+		if err := Compact(strings.NewReader(invalid), &bytes.Buffer{}); err == nil {
+			t.Errorf("Expected an error for %s\n", invalid)
+		}
+	}
+
+	// deep enough to overflow the stack of a recursive formatter
+	deep := strings.Repeat(`[{"a":`, 1000000) + "1" + strings.Repeat("}]", 1000000)
+	var out bytes.Buffer
+
+	if err := Compact(strings.NewReader(deep), &out); err != nil || out.String() != deep+"\n" {
+		t.Errorf("Expected the deep input back, got %d bytes, error: %v\n", out.Len(), err)
+	}
+}
+
+func TestCanonical(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		// the examples of RFC 8785
+		{name: "values",
+			input:    `{"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001], "string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/", "literals": [null, true, false]}`,
+			expected: `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`},
+		{name: "sorting",
+			input:    `{"\u20ac": "Euro Sign", "\r": "Carriage Return", "\ufb33": "Hebrew Letter Dalet With Dagesh", "1": "One", "\ud83d\ude00": "Emoji: Grinning Face", "\u0080": "Control", "\u00f6": "Latin Small Letter O With Diaeresis"}`,
+			expected: "{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"ö\":\"Latin Small Letter O With Diaeresis\",\"€\":\"Euro Sign\",\"😀\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}"},
+		{name: "nested",
+			input:    " [ {\"b\": {\"d\": -0, \"c\": 1e21}, \"a\": \"\u2028\"}, [] ] \n",
+			expected: "[{\"a\":\"\u2028\",\"b\":{\"c\":1e+21,\"d\":0}},[]]"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out bytes.Buffer
+
+			if err := Canonical(strings.NewReader(tc.input), &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tc.expected {
+				t.Errorf("Expected %q, got %q instead\n", tc.expected, out.String())
+			}
+		})
+	}
+
+	sum, err := CanonicalHash(strings.NewReader(`{"b": 2.0, "a": 1}`), sha256.New())
+	expected := sha256.Sum256([]byte(`{"a":1,"b":2}`))
+
+	if err != nil || !bytes.Equal(sum, expected[:]) {
+		t.Errorf("Expected '%x', got '%x' instead, error: %v\n", expected, sum, err)
+	}
+
+	for _, invalid := range []string{`{"a": 1, "b": {}, "a": 2}`, `[1e400]`, `1 2`, `[1,`, `"\ud800"`, `"a\qb"`, `{"a": 01}`} {
+		if err := Canonical(strings.NewReader(invalid), &bytes.Buffer{}); err == nil {
+			t.Errorf("Expected an error for %s\n", invalid)
+		}
+	}
+
+	err = Canonical(strings.NewReader(`{"x": {"a": 1, "a": 2}}`), &bytes.Buffer{})
+	var duplicate c.DuplicateKeyErr
+
+	if !errors.As(err, &duplicate) || duplicate.Path != ".x" || duplicate.Column != 16 || duplicate.FirstColumn != 8 {
+		t.Errorf("Expected a duplicate key error in .x, got '%v' instead\n", err)
+	}
+
+	err = Canonical(strings.NewReader(`[1, 1e400]`), &bytes.Buffer{})
+	var outOfRange c.NumberRangeErr
+
+	if !errors.As(err, &outOfRange) || !errors.Is(err, c.ErrNumberOutOfRange) || outOfRange.Column != 5 {
+		t.Errorf("Expected 1e400 out of range at column 5, got '%v' instead\n", err)
+	}
+}
@@ -0,0 +1,95 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/rodic/jmatch/paths"
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+type gron struct {
+	painter
+	w    io.Writer
+	file string
+}
+
+// NewGron writes an assignment statement per value, the way gron does:
+//
+//	json.a["b c"][0] = "x";
+//
+// Opening tokens of objects and arrays, passed along with
+// jmatch.WithContainers, are written as assignments of {} and []. Closing
+// ones are skipped. Lines start with the file name and a colon once it is
+// set.
+func NewGron(w io.Writer) Writer {
+	return &gron{w: w}
+}
+
+func (f *gron) Write(path string, token t.Token) error {
+	var value string
+
+	switch {
+	case token.IsLeftBrace():
+		value = "{}"
+	case token.IsLeftBracket():
+		value = "[]"
+	case token.IsContainer():
+		return nil
+	default:
+		value = f.value(token, true)
+	}
+
+	statement, err := f.gronPath(path)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(f.w, "%s%s = %s;\n", f.prefix(f.file), statement, value)
+	return err
+}
+
+func (f *gron) SetFile(name string) {
+	f.file = name
+}
+
+func (f *gron) Flush() error {
+	return nil
+}
+
+// GronPath rewrites a jq path the way gron writes it: json.a["b c"][0].
+func GronPath(path string) (string, error) {
+	var p painter
+	return p.gronPath(path)
+}
+
+func (p *painter) gronPath(path string) (string, error) {
+	segments, err := paths.Split(path)
+
+	if err != nil {
+		return "", err
+	}
+
+	var gronPath strings.Builder
+
+	gronPath.WriteString("json")
+
+	for _, s := range segments {
+		switch {
+		case s.IsIndex():
+			gronPath.WriteRune('[')
+			gronPath.WriteString(p.paint(p.colors.Index, strconv.Itoa(s.Index)))
+			gronPath.WriteRune(']')
+		case paths.IsIdentifier(s.Key):
+			gronPath.WriteRune('.')
+			gronPath.WriteString(p.paint(p.colors.Key, s.Key))
+		default:
+			gronPath.WriteRune('[')
+			gronPath.WriteString(p.paint(p.colors.Key, paths.Quote(s.Key)))
+			gronPath.WriteRune(']')
+		}
+	}
+	return gronPath.String(), nil
+}
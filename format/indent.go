@@ -0,0 +1,509 @@
+package format
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	c "github.com/rodic/jmatch/common"
+	"github.com/rodic/jmatch/paths"
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+// Option changes how Indent and Compact write JSON.
+type Option func(*style)
+
+type style struct {
+	indent          string
+	compact         bool
+	sortKeys        bool
+	ascii           bool
+	shortestNumbers bool
+	canonical       bool // RFC 8785
+}
+
+// SortKeys writes the members of every object sorted by key, byte by byte.
+// Members with the same key keep their order.
+func SortKeys() Option {
+	return func(s *style) {
+		s.sortKeys = true
+	}
+}
+
+// ASCII escapes the characters of strings and keys outside of ASCII, as
+// é, so that the output is pure ASCII.
+func ASCII() Option {
+	return func(s *style) {
+		s.ascii = true
+	}
+}
+
+// ShortestNumbers writes numbers the way JavaScript does, 1.50 as 1.5 and
+// 1E3 as 1000, rather than as they are in the input. The digits of numbers
+// too precise for a float64 are lost.
+func ShortestNumbers() Option {
+	return func(s *style) {
+		s.shortestNumbers = true
+	}
+}
+
+// Indent writes the JSON read with every member and element on a line of
+// its own, preceded by indent once per level of nesting:
+//
+//	{
+//	  "a": [
+//	    1,
+//	    2
+//	  ],
+//	  "b": {}
+//	}
+//
+// Input made of many JSON values, such as NDJSON, is written value by
+// value, each followed by a new line. Only objects with their keys
+// sorted are held in memory. On error, what was written so far is not
+// valid JSON.
+func Indent(r io.Reader, w io.Writer, indent string, opts ...Option) error {
+	s := style{indent: indent}
+	return reformat(r, w, s, opts)
+}
+
+// Compact writes the JSON read with no whitespace between tokens, each
+// JSON value of the input on a line of its own.
+func Compact(r io.Reader, w io.Writer, opts ...Option) error {
+	s := style{compact: true}
+	return reformat(r, w, s, opts)
+}
+
+func reformat(r io.Reader, w io.Writer, s style, opts []Option) error {
+	for _, opt := range opts {
+		opt(&s)
+	}
+
+	// a canonical text is the same for all that read the same value
+	f := &formatter{scanner: t.NewScanner(r, t.Config{Strict: s.canonical}), style: s}
+	out := bufio.NewWriter(w)
+
+	for i := 0; ; i++ {
+		token, err := f.next()
+
+		if err == io.EOF {
+			break
+		}
+		if err == nil && s.canonical && i > 0 {
+			err = unexpected(token, "end of input") // a canonical text is one value
+		}
+		if err == nil {
+			err = f.value(out, token)
+		}
+		if err != nil {
+			out.Flush()
+			return f.withExcerpt(err)
+		}
+		if !s.canonical {
+			out.WriteRune('\n')
+		}
+	}
+	return out.Flush()
+}
+
+// sink is where formatted JSON goes, the output or, when keys are sorted,
+// the text of an object member.
+type sink interface {
+	WriteString(s string) (int, error)
+}
+
+type formatter struct {
+	scanner *t.Scanner
+	style   style
+	last    t.Token // read, for errors at the end of input
+	path    []paths.Segment
+
+	// the objects and arrays being written, innermost last, the sorted
+	// objects among them kept apart with their members
+	out    sink
+	stack  []container
+	sorted []*sortedObject
+}
+
+// container is an object or array being written.
+type container struct {
+	object bool
+	count  int // of the members read so far
+}
+
+func (c container) closedBy(token t.Token) bool {
+	return c.object && token.IsRightBrace() || !c.object && token.IsRightBracket()
+}
+
+func (c container) brackets() (string, string) {
+	if c.object {
+		return "{", "}"
+	}
+	return "[", "]"
+}
+
+// sortedObject is an object written only once all of its members are
+// read, to sort them.
+type sortedObject struct {
+	out     sink // where the object goes
+	members []member
+	text    *strings.Builder // of the member being read
+	key     t.Token          // of the member being read
+}
+
+// member is an object member written out.
+type member struct {
+	key   string
+	token t.Token // of the key
+	text  string
+}
+
+// next returns the next token, io.EOF at the end of input.
+func (f *formatter) next() (t.Token, error) {
+	token, err := f.scanner.Scan()
+
+	if err != nil {
+		return t.Token{}, err
+	}
+
+	f.last = token.Copy()
+	return f.last, nil
+}
+
+// expect returns the next token, which must be there.
+func (f *formatter) expect() (t.Token, error) {
+	token, err := f.next()
+
+	if err == io.EOF {
+		return token, c.UnexpectedEndOfInputErr{Line: f.last.Line, Column: f.last.Column}
+	}
+	return token, err
+}
+
+// withExcerpt adds the part of the input where the error is to it.
+func (f *formatter) withExcerpt(err error) error {
+	if e, ok := err.(c.UnexpectedTokenErr); ok && e.Excerpt == "" {
+		e.Excerpt = f.scanner.Excerpt(e.Line, e.Column)
+		return e
+	}
+	return err
+}
+
+func unexpected(token t.Token, expected ...string) error {
+	err := token.AsUnexpectedTokenErr()
+	err.Expected = expected
+	return err
+}
+
+// value writes the value starting with the token. The objects and arrays
+// it is made of are kept on a stack of the formatter rather than on the
+// goroutine's, so that no depth of nesting overflows it.
+func (f *formatter) value(out sink, token t.Token) error {
+	f.out, f.stack, f.sorted = out, f.stack[:0], f.sorted[:0]
+
+	for {
+		var err error
+
+		if token.IsLeftBrace() || token.IsLeftBracket() {
+			var empty bool
+
+			if token, empty, err = f.open(container{object: token.IsLeftBrace()}); err != nil {
+				return err
+			}
+			if !empty {
+				continue // the first member is started, token begins its value
+			}
+		} else if err := f.scalar(token); err != nil {
+			return err
+		}
+
+		// the value is written, on to what follows it in the containers
+		// around it, closing them until one goes on with a comma
+		for {
+			if len(f.stack) == 0 {
+				return nil
+			}
+			f.endMember()
+
+			if token, err = f.expect(); err != nil {
+				return err
+			}
+			if !token.IsComma() {
+				if err := f.close(token); err != nil {
+					return err
+				}
+				continue
+			}
+			if token, err = f.expect(); err != nil {
+				return err
+			}
+			if token, err = f.member(token); err != nil {
+				return err
+			}
+			break
+		}
+	}
+}
+
+// target is where the value being read goes: the text of the member read
+// in the innermost sorted object, the output if there is none.
+func (f *formatter) target() sink {
+	if n := len(f.sorted); n > 0 {
+		return f.sorted[n-1].text
+	}
+	return f.out
+}
+
+func (f *formatter) isSorted(c container) bool {
+	return c.object && (f.style.sortKeys || f.style.canonical)
+}
+
+// open reads what follows the opening of the container. An empty one is
+// written at once; otherwise the container is pushed and the first token
+// of the value of its first member returned.
+func (f *formatter) open(c container) (token t.Token, empty bool, err error) {
+	if token, err = f.expect(); err != nil {
+		return token, false, err
+	}
+	if c.closedBy(token) {
+		opening, closing := c.brackets()
+		f.target().WriteString(opening + closing)
+		return token, true, nil
+	}
+
+	if f.isSorted(c) {
+		f.sorted = append(f.sorted, &sortedObject{out: f.target()})
+	}
+	f.stack = append(f.stack, c)
+
+	token, err = f.member(token)
+	return token, false, err
+}
+
+// member starts a member of the innermost container with its first token,
+// the key in objects, and returns the first token of its value.
+func (f *formatter) member(token t.Token) (t.Token, error) {
+	depth := len(f.stack)
+	c := &f.stack[depth-1]
+
+	if f.isSorted(*c) {
+		s := f.sorted[len(f.sorted)-1]
+		s.text, s.key = &strings.Builder{}, token
+	} else if c.count == 0 {
+		opening, _ := c.brackets()
+		f.target().WriteString(opening)
+	} else {
+		f.target().WriteString(",")
+	}
+
+	target := f.target()
+	f.newLine(target, depth)
+
+	segment := paths.IndexSegment(c.count)
+	c.count++
+
+	if c.object {
+		if !token.IsString() {
+			return token, unexpected(token, "string")
+		}
+		segment = paths.KeySegment(token.Value)
+		target.WriteString(f.quote(token.Value))
+
+		var err error
+
+		if token, err = f.expect(); err != nil {
+			return token, err
+		}
+		if !token.IsColon() {
+			return token, unexpected(token, ":")
+		}
+		target.WriteString(":")
+
+		if !f.style.compact {
+			target.WriteString(" ")
+		}
+
+		if token, err = f.expect(); err != nil {
+			return token, err
+		}
+	}
+
+	f.path = append(f.path, segment)
+	return token, nil
+}
+
+// endMember is called once the value of a member is written.
+func (f *formatter) endMember() {
+	f.path = f.path[:len(f.path)-1]
+
+	if f.isSorted(f.stack[len(f.stack)-1]) {
+		s := f.sorted[len(f.sorted)-1]
+		s.members = append(s.members, member{key: s.key.Value, token: s.key, text: s.text.String()})
+	}
+}
+
+// close closes the innermost container with the token, writing out its
+// members first when they are sorted.
+func (f *formatter) close(token t.Token) error {
+	c := f.stack[len(f.stack)-1]
+	opening, closing := c.brackets()
+
+	if !c.closedBy(token) {
+		return unexpected(token, ",", closing)
+	}
+
+	f.stack = f.stack[:len(f.stack)-1]
+
+	if f.isSorted(c) {
+		s := f.sorted[len(f.sorted)-1]
+		f.sorted = f.sorted[:len(f.sorted)-1]
+
+		if err := f.sort(s.members); err != nil {
+			return err
+		}
+
+		s.out.WriteString(opening)
+
+		for i, m := range s.members {
+			if i > 0 {
+				s.out.WriteString(",")
+			}
+			s.out.WriteString(m.text)
+		}
+	}
+
+	out := f.target()
+	f.newLine(out, len(f.stack))
+	out.WriteString(closing)
+	return nil
+}
+
+// scalar writes the value of the token, which is not an object or array.
+func (f *formatter) scalar(token t.Token) error {
+	out := f.target()
+
+	switch {
+	case token.IsString():
+		out.WriteString(f.quote(token.Value))
+	case token.IsNumber():
+		number, err := f.number(token)
+
+		if err != nil {
+			return err
+		}
+		out.WriteString(number)
+	case token.IsBoolean() || token.IsNull():
+		out.WriteString(token.Value)
+	default:
+		return unexpected(token, "value")
+	}
+	return nil
+}
+
+// sort sorts the members of an object by key, as RFC 8785 has it when
+// the output is canonical, where keys must be unique.
+func (f *formatter) sort(members []member) error {
+	if !f.style.canonical {
+		sort.SliceStable(members, func(i, j int) bool {
+			return members[i].key < members[j].key
+		})
+		return nil
+	}
+
+	sort.SliceStable(members, func(i, j int) bool {
+		return lessUTF16(members[i].key, members[j].key)
+	})
+
+	for i := 1; i < len(members); i++ {
+		// the sort being stable, the first occurrence comes first
+		if first, second := members[i-1].token, members[i].token; first.Value == second.Value {
+			return c.DuplicateKeyErr{
+				Key:         first.Value,
+				Path:        paths.Join(f.path),
+				Line:        second.Line,
+				Column:      second.Column,
+				FirstLine:   first.Line,
+				FirstColumn: first.Column,
+			}
+		}
+	}
+	return nil
+}
+
+func (f *formatter) newLine(out sink, depth int) {
+	if !f.style.compact {
+		out.WriteString("\n" + strings.Repeat(f.style.indent, depth))
+	}
+}
+
+func (f *formatter) quote(s string) string {
+	if f.style.canonical {
+		return canonicalQuote(s)
+	}
+
+	quoted := paths.Quote(s)
+
+	if !f.style.ascii {
+		return quoted
+	}
+
+	var ascii strings.Builder
+
+	for _, r := range quoted {
+		switch {
+		case r < 0x80:
+			ascii.WriteRune(r)
+		case r > 0xffff:
+			high, low := utf16.EncodeRune(r)
+			fmt.Fprintf(&ascii, `\u%04x\u%04x`, high, low)
+		default:
+			fmt.Fprintf(&ascii, `\u%04x`, r)
+		}
+	}
+	return ascii.String()
+}
+
+func (f *formatter) number(token t.Token) (string, error) {
+	switch {
+	case f.style.canonical:
+		return canonicalNumber(token)
+	case f.style.shortestNumbers:
+		return shortestNumber(token.Value), nil
+	}
+	return token.Value, nil
+}
+
+// shortestNumber writes the number the way JavaScript's Number.toString
+// does, as JSON.stringify and RFC 8785 do. Numbers out of the range of a
+// float64 are left as they are.
+func shortestNumber(lexeme string) string {
+	n, err := strconv.ParseFloat(lexeme, 64)
+
+	if err != nil || math.IsInf(n, 0) {
+		return lexeme
+	}
+	if n == 0 {
+		return "0" // -0 included
+	}
+
+	format := byte('f')
+
+	if abs := math.Abs(n); abs < 1e-6 || abs >= 1e21 {
+		format = 'e'
+	}
+
+	s := strconv.FormatFloat(n, format, -1, 64)
+
+	if format == 'e' {
+		// 1e-07 as 1e-7
+		mantissa, exponent, _ := strings.Cut(s, "e")
+		sign, digits := exponent[:1], strings.TrimLeft(exponent[1:], "0")
+		s = mantissa + "e" + sign + digits
+	}
+	return s
+}
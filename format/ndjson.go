@@ -0,0 +1,48 @@
+package format
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/rodic/jmatch/paths"
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+type ndjson struct {
+	w    io.Writer
+	file string
+}
+
+// NewNDJSON writes an object per line holding the path, type, value and
+// position of the value:
+//
+//	{"path":".a","type":"string","value":"x","line":1,"col":7}
+//
+// Containers are skipped. Objects start with a "file" field once the file
+// name is set.
+func NewNDJSON(w io.Writer) Writer {
+	return &ndjson{w: w}
+}
+
+func (f *ndjson) Write(path string, token t.Token) error {
+	if token.IsContainer() {
+		return nil
+	}
+	file := ""
+
+	if f.file != "" {
+		file = "\"file\":" + paths.Quote(f.file) + ","
+	}
+
+	_, err := fmt.Fprintf(f.w, "{%s\"path\":%s,\"type\":\"%s\",\"value\":%s,\"line\":%d,\"col\":%d}\n",
+		file, paths.Quote(path), TypeName(token), Literal(token), token.Line, token.Column)
+	return err
+}
+
+func (f *ndjson) SetFile(name string) {
+	f.file = name
+}
+
+func (f *ndjson) Flush() error {
+	return nil
+}
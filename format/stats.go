@@ -0,0 +1,195 @@
+package format
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/rodic/jmatch/paths"
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+// pathStats sums up the values found on a path.
+type pathStats struct {
+	count    int
+	types    map[string]int
+	numbers  int
+	min, max t.Token
+	minValue float64
+	maxValue float64
+	lengths  histogram // of strings, in runes
+}
+
+func (s *pathStats) add(token t.Token) {
+	s.count++
+	s.types[TypeName(token)]++
+
+	switch {
+	case token.IsNumber():
+		value, err := strconv.ParseFloat(token.Value, 64)
+
+		if err != nil {
+			return // out of the range of float64
+		}
+		if s.numbers == 0 || value < s.minValue {
+			s.min, s.minValue = token, value
+		}
+		if s.numbers == 0 || value > s.maxValue {
+			s.max, s.maxValue = token, value
+		}
+		s.numbers++
+	case token.IsString():
+		s.lengths.add(utf8.RuneCountInString(token.Value))
+	}
+}
+
+type stats struct {
+	w     io.Writer
+	stats map[string]*pathStats
+	paths []string // in the order they were first seen
+}
+
+// NewStats sums up the values found per path, the indexes of paths left
+// out as NewPathCount does. On Flush, it writes an object per path with
+// the number of values, the number of values of each type, the share of
+// nulls, the smallest and largest numbers and percentiles of the lengths
+// of strings, exact up to 64 runes and rounded up by 3% at most beyond:
+//
+//	{"path":".users[].name","count":2,"types":{"string":2},"null_ratio":0,"length":{"min":3,"p50":3,"p90":3,"p99":3,"max":3}}
+//
+// Paths are written in the order they were first seen. Containers are
+// skipped.
+func NewStats(w io.Writer) Writer {
+	return &stats{w: w, stats: map[string]*pathStats{}}
+}
+
+func (f *stats) Write(path string, token t.Token) error {
+	if token.IsContainer() {
+		return nil
+	}
+
+	path = collapse(path)
+	s, ok := f.stats[path]
+
+	if !ok {
+		s = &pathStats{types: map[string]int{}}
+		f.stats[path] = s
+		f.paths = append(f.paths, path)
+	}
+	s.add(token)
+	return nil
+}
+
+func (f *stats) Flush() error {
+	for _, path := range f.paths {
+		if _, err := fmt.Fprintln(f.w, f.line(path, f.stats[path])); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *stats) line(path string, s *pathStats) string {
+	var line strings.Builder
+
+	fmt.Fprintf(&line, `{"path":%s,"count":%d,"types":{`, paths.Quote(path), s.count)
+
+	first := true
+
+	for _, name := range []string{"string", "number", "boolean", "null"} {
+		if s.types[name] == 0 {
+			continue
+		}
+		if !first {
+			line.WriteRune(',')
+		}
+		first = false
+		fmt.Fprintf(&line, `"%s":%d`, name, s.types[name])
+	}
+
+	ratio := math.Round(float64(s.types["null"])/float64(s.count)*1e4) / 1e4
+	fmt.Fprintf(&line, `},"null_ratio":%s`, strconv.FormatFloat(ratio, 'f', -1, 64))
+
+	if s.numbers > 0 {
+		fmt.Fprintf(&line, `,"min":%s,"max":%s`, s.min.Value, s.max.Value)
+	}
+
+	if l := &s.lengths; l.count > 0 {
+		fmt.Fprintf(&line, `,"length":{"min":%d,"p50":%d,"p90":%d,"p99":%d,"max":%d}`,
+			l.min, l.percentile(50), l.percentile(90), l.percentile(99), l.max)
+	}
+
+	line.WriteRune('}')
+	return line.String()
+}
+
+// subBuckets is the number of buckets every power of two is split into
+// past the lengths counted one by one, 2*subBuckets of them.
+const subBuckets = 32
+
+// histogram counts lengths in buckets of their own up to 2*subBuckets and
+// of 1/subBuckets of a power of two beyond, so that it takes a couple of
+// thousand counters at most however many lengths are added. Percentiles
+// are exact up to there and at most 1/subBuckets over beyond.
+type histogram struct {
+	buckets  []int
+	count    int
+	min, max int
+}
+
+func (h *histogram) add(length int) {
+	b := bucket(length)
+
+	for len(h.buckets) <= b {
+		h.buckets = append(h.buckets, 0)
+	}
+	h.buckets[b]++
+
+	if h.count == 0 || length < h.min {
+		h.min = length
+	}
+	if h.count == 0 || length > h.max {
+		h.max = length
+	}
+	h.count++
+}
+
+// percentile returns the nearest rank percentile, rounded up to the end
+// of its bucket but never past the largest length added.
+func (h *histogram) percentile(p int) int {
+	rank := (p*h.count + 99) / 100
+	seen := 0
+
+	for b, n := range h.buckets {
+		if seen += n; seen >= rank {
+			return max(min(bucketStart(b+1)-1, h.max), h.min)
+		}
+	}
+	return h.max
+}
+
+func bucket(length int) int {
+	if length < 2*subBuckets {
+		return length
+	}
+
+	exponent := bits.Len(uint(length)) - 1 // length is in [2^exponent, 2^(exponent+1))
+	shift := exponent - bits.Len(subBuckets) + 1
+	first := 2*subBuckets + (shift-1)*subBuckets // of the power of two
+
+	return first + length>>shift - subBuckets
+}
+
+// bucketStart returns the smallest length counted in the bucket.
+func bucketStart(b int) int {
+	if b < 2*subBuckets {
+		return b
+	}
+
+	shift := (b-2*subBuckets)/subBuckets + 1
+	return (subBuckets + (b-2*subBuckets)%subBuckets) << shift
+}
@@ -0,0 +1,37 @@
+package format
+
+import (
+	"fmt"
+	"io"
+
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+type tsv struct {
+	painter
+	w    io.Writer
+	file string
+}
+
+// NewTSV writes the path and the value, as a JSON literal, separated by a
+// tab. Containers are skipped. Lines start with the file name and a colon
+// once it is set.
+func NewTSV(w io.Writer) Writer {
+	return &tsv{w: w}
+}
+
+func (f *tsv) Write(path string, token t.Token) error {
+	if token.IsContainer() {
+		return nil
+	}
+	_, err := fmt.Fprintf(f.w, "%s%s\t%s\n", f.prefix(f.file), f.path(path), f.value(token, true))
+	return err
+}
+
+func (f *tsv) SetFile(name string) {
+	f.file = name
+}
+
+func (f *tsv) Flush() error {
+	return nil
+}
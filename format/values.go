@@ -0,0 +1,37 @@
+package format
+
+import (
+	"fmt"
+	"io"
+
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+type values struct {
+	painter
+	w    io.Writer
+	file string
+}
+
+// NewValues writes the values alone, strings unquoted as jq -r does.
+// Containers are skipped. Lines start with the file name and a colon once
+// it is set.
+func NewValues(w io.Writer) Writer {
+	return &values{w: w}
+}
+
+func (f *values) Write(path string, token t.Token) error {
+	if token.IsContainer() {
+		return nil
+	}
+	_, err := fmt.Fprintf(f.w, "%s%s\n", f.prefix(f.file), f.value(token, false))
+	return err
+}
+
+func (f *values) SetFile(name string) {
+	f.file = name
+}
+
+func (f *values) Flush() error {
+	return nil
+}
@@ -0,0 +1,74 @@
+package jmatch
+
+import (
+	"io"
+	"sync"
+
+	p "github.com/rodic/jmatch/parser"
+)
+
+// MatchAll parses the input once and passes every value to all matchers,
+// in the order they are given, so that many consumers share a single pass
+// over a large input:
+//
+//	err := jmatch.MatchAll(r, []jmatch.Matcher{metrics, validate, index})
+//
+// By default each value is passed to one matcher after the other on the
+// goroutine parsing. With WithConcurrentMatchers, every matcher runs on a
+// goroutine of its own and receives the values in the order they are
+// found; MatchAll returns once all of them are done with the values sent
+// their way.
+func MatchAll(reader io.Reader, matchers []Matcher, opts ...Option) error {
+	config := newConfig(opts)
+
+	if config.fanOut == nil {
+		return match(reader, func(result p.ParsingResult) {
+			for _, matcher := range matchers {
+				matcher(result.Path, result.Token)
+			}
+		}, config)
+	}
+
+	fanOut := config.fanOut
+	queues := make([]chan p.ParsingResult, len(matchers))
+
+	var wg sync.WaitGroup
+
+	for i, matcher := range matchers {
+		queue := make(chan p.ParsingResult, fanOut.buffer)
+		queues[i] = queue
+
+		wg.Add(1)
+		go func(matcher Matcher) {
+			defer wg.Done()
+
+			for result := range queue {
+				matcher(result.Path, result.Token)
+			}
+		}(matcher)
+	}
+
+	err := match(reader, func(result p.ParsingResult) {
+		for i, queue := range queues {
+			if fanOut.policy == WaitForSlowMatchers {
+				queue <- result
+				continue
+			}
+
+			select {
+			case queue <- result:
+			default:
+				if fanOut.dropped != nil {
+					fanOut.dropped(i, result.Path, result.Token)
+				}
+			}
+		}
+	}, config)
+
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+
+	return err
+}
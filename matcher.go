@@ -3,6 +3,7 @@ package jmatch
 import (
 	"io"
 
+	c "github.com/rodic/jmatch/common"
 	p "github.com/rodic/jmatch/parser"
 	t "github.com/rodic/jmatch/tokenizer"
 )
@@ -12,28 +13,89 @@ type Token = t.Token
 type Matcher func(path string, token t.Token)
 
 // tokenizer -> parser -> matcher
-func Match(reader io.Reader, matcher Matcher) error {
+func Match(reader io.Reader, matcher Matcher, opts ...Option) error {
+	return match(reader, func(result p.ParsingResult) {
+		matcher(result.Path, result.Token)
+	}, newConfig(opts))
+}
+
+// Path is the path of a value as its segments, written out only when asked
+// for by String or AppendTo.
+type Path = p.Path
+
+// LazyMatcher is a matcher taking paths as Path.
+type LazyMatcher func(path Path, token t.Token)
+
+// MatchLazy matches the way Match does, without writing out the paths of
+// the values passed to the matcher. Paths point to the containers they go
+// through, shared by all their values, so a matcher that looks at few of
+// the paths, or only at their segments, doesn't pay for the rest:
+//
+//	err := jmatch.MatchLazy(r, func(path jmatch.Path, token jmatch.Token) {
+//		if token.IsString() && token.Value == "error" {
+//			log.Println(path)
+//		}
+//	})
+func MatchLazy(reader io.Reader, matcher LazyMatcher, opts ...Option) error {
+	config := newConfig(opts)
+	config.parser.LazyPaths = true
 
-	tokenizer := t.NewTokenizer(reader)
+	return match(reader, func(result p.ParsingResult) {
+		matcher(result.Lazy, result.Token)
+	}, config)
+}
+
+// match passes the values found by the parser to the receiver, errors
+// aside.
+func match(reader io.Reader, receive func(p.ParsingResult), config config) error {
 
-	go tokenizer.Tokenize()
+	scanner := t.NewScanner(reader, config.tokenizer)
 
-	parser, err := p.NewParser(tokenizer.GetTokenReadStream())
+	// the input is scanned on the parsing goroutine, stopped on every
+	// return so that nothing is left reading it once match returns
+	parser, err := p.NewScannerParser(scanner, config.parser)
 
 	if err != nil {
-		return err
+		return withExcerpt(err, scanner)
 	}
 
 	go parser.Parse()
+	defer parser.Stop()
+
+	var errors c.ErrorList
 
 	for parsingResult := range parser.GetResultReadStream() {
 
 		if parsingResult.Error != nil {
-			return parsingResult.Error
+			err := withExcerpt(parsingResult.Error, scanner)
+
+			if !config.parser.Recover {
+				return err
+			}
+			errors = append(errors, err)
+			continue
 		}
 
-		matcher(parsingResult.Path, parsingResult.Token)
+		receive(parsingResult)
+	}
+
+	if len(errors) > 0 {
+		return errors
 	}
 
 	return nil
 }
+
+// excerpter is implemented by the scanner, which keeps the recently read
+// input around.
+type excerpter interface {
+	Excerpt(line int, column int) string
+}
+
+func withExcerpt(err error, source excerpter) error {
+	if e, ok := err.(c.UnexpectedTokenErr); ok && e.Excerpt == "" {
+		e.Excerpt = source.Excerpt(e.Line, e.Column)
+		return e
+	}
+	return err
+}
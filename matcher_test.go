@@ -1,10 +1,19 @@
 package jmatch
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"os"
 	"reflect"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
 
+	c "github.com/rodic/jmatch/common"
 	z "github.com/rodic/jmatch/tokenizer"
 )
 
@@ -163,16 +172,1114 @@ func TestMatcherValid(t *testing.T) {
 	}
 }
 
-func BenchmarkMatch(b *testing.B) {
-	file, err := os.Open("testdata/bench/users_100k.json")
+func TestMatchRecovery(t *testing.T) {
+	input := "{\"a\": 1, \"b\": truef, \"c\": [1, 2,], \"d\": {\"e\" 1}, \"f\": 4}"
+
+	expectedMatches := map[string]z.Token{
+		".a":    z.NewNumberToken("1", 1, 7),
+		".c[0]": z.NewNumberToken("1", 1, 28),
+		".c[1]": z.NewNumberToken("2", 1, 31),
+		".f":    z.NewNumberToken("4", 1, 55),
+	}
+
+	expectedErrors := []string{
+		"invalid JSON. unexpected token truef at line 1 column 15 in .b, expected value",
+		"invalid JSON. unexpected token ] at line 1 column 33 in .c[2], expected value",
+		"invalid JSON. unexpected token 1 at line 1 column 46 in .d.e, expected ':'",
+	}
+
+	collector := CollectorMatcher{
+		matches: make(map[string]z.Token),
+	}
+
+	err := Match(strings.NewReader(input), collector.Match, WithRecovery())
+
+	if !reflect.DeepEqual(collector.matches, expectedMatches) {
+		t.Errorf("Expected '%v', got '%v' instead\n", expectedMatches, collector.matches)
+	}
+
+	errorList, ok := err.(c.ErrorList)
+
+	if !ok {
+		t.Fatalf("Expected error list, got '%v' instead\n", err)
+	}
+
+	messages := make([]string, len(errorList))
+
+	for i, e := range errorList {
+		messages[i] = e.Error()
+	}
+
+	if !reflect.DeepEqual(messages, expectedErrors) {
+		t.Errorf("Expected '%v', got '%v' instead\n", expectedErrors, messages)
+	}
+}
+
+func TestMatchRecoveryCascade(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected []string
+		errors   []string
+	}{
+		{input: `{"a":1.e5,"b":2}`,
+			expected: []string{".b=2"},
+			errors:   []string{"invalid JSON. unexpected token e at line 1 column 8 in .a, expected digit"}},
+		{input: `{"a":01,"b":2}`,
+			expected: []string{".b=2"},
+			errors:   []string{"invalid JSON. unexpected token 1 at line 1 column 7 in .a, expected '.', 'e' or end of number"}},
+		{input: `[1,2]x[3]`,
+			expected: []string{".[0]=1", ".[1]=2"},
+			errors:   []string{"invalid JSON. unexpected token x at line 1 column 6 in ., expected value"}},
+		{input: `[1,2] [3]`,
+			expected: []string{".[0]=1", ".[1]=2"},
+			errors:   []string{"invalid JSON. unexpected token [ at line 1 column 7 in ., expected end of input"}},
+		{input: `[[1,], x, {"a": 2 "b"}, 3]`,
+			expected: []string{".[0][0]=1", ".[2].a=2", ".[3]=3"},
+			errors: []string{
+				"invalid JSON. unexpected token ] at line 1 column 5 in .[0][1], expected value",
+				"invalid JSON. unexpected token x at line 1 column 8 in .[1], expected value",
+				"invalid JSON. unexpected token b at line 1 column 19 in .[2], expected ',' or '}'",
+			}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			var values []string
+
+			err := Match(strings.NewReader(tc.input), func(path string, token z.Token) {
+				values = append(values, path+"="+token.Value)
+			}, WithRecovery())
+
+			if !reflect.DeepEqual(values, tc.expected) {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, values)
+			}
+
+			var messages []string
+
+			for _, e := range err.(c.ErrorList) {
+				messages = append(messages, e.Error())
+			}
+
+			if !reflect.DeepEqual(messages, tc.errors) {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.errors, messages)
+			}
+		})
+	}
+}
+
+func TestMatchErrorDiagnostics(t *testing.T) {
+	input := "{\n  \"a\": [1, 2],\n  \"b\" 3\n}"
+
+	err := Match(strings.NewReader(input), func(path string, token z.Token) {})
+
+	if !errors.Is(err, c.ErrUnexpectedToken) {
+		t.Fatalf("Expected unexpected token error, got '%v' instead\n", err)
+	}
+
+	var tokenErr c.UnexpectedTokenErr
+
+	errors.As(err, &tokenErr)
+
+	expected := c.UnexpectedTokenErr{
+		Token:    "3",
+		Line:     3,
+		Column:   7,
+		Path:     ".b",
+		Expected: []string{":"},
+		Excerpt:  "3 |   \"b\" 3\n  |       ^",
+	}
+
+	if !reflect.DeepEqual(tokenErr, expected) {
+		t.Errorf("Expected '%#v', got '%#v' instead\n", expected, tokenErr)
+	}
+
+	err = Match(strings.NewReader("{\"a\": [1, 2]"), func(path string, token z.Token) {})
+
+	if !errors.Is(err, c.ErrUnexpectedEndOfInput) {
+		t.Fatalf("Expected unexpected end of input error, got '%v' instead\n", err)
+	}
+
+	expectedEnd := c.UnexpectedEndOfInputErr{Line: 1, Column: 12, Path: "."}
+
+	if !reflect.DeepEqual(err, expectedEnd) {
+		t.Errorf("Expected '%#v', got '%#v' instead\n", expectedEnd, err)
+	}
+
+	err = Match(strings.NewReader(" \n"), func(path string, token z.Token) {})
+
+	if expected := "invalid JSON. Unexpected end of JSON input"; err == nil || err.Error() != expected {
+		t.Errorf("Expected '%s', got '%v' instead\n", expected, err)
+	}
+
+	deep := strings.Repeat("[", 10000) + "{\"a\" 1}"
+	err = Match(strings.NewReader(deep), func(path string, token z.Token) {})
+
+	if !errors.As(err, &tokenErr) || tokenErr.Path != "."+strings.Repeat("[0]", 10000)+".a" {
+		t.Fatalf("Expected the whole path in the error, got '%#v' instead\n", err)
+	}
+	if message := err.Error(); len(message) > 400 || !strings.Contains(message, "[0]...") ||
+		!strings.HasSuffix(message, "[0].a, expected ':'") {
+		t.Errorf("Expected the path shortened, got '%s' instead\n", message)
+	}
+
+	long := `{"a" "` + strings.Repeat("x", 100000) + `"}`
+	err = Match(strings.NewReader(long), func(path string, token z.Token) {})
+
+	if !errors.As(err, &tokenErr) || len(tokenErr.Token) != 100000 {
+		t.Fatalf("Expected the whole token in the error, got '%#v' instead\n", err)
+	}
+	if message := err.Error(); len(message) > 200 || !strings.Contains(message, "xxx...xxx") {
+		t.Errorf("Expected the token shortened, got '%s' instead\n", message)
+	}
+}
+
+func TestMatchTokenizerErrorPaths(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{input: `tru`, expected: "."},
+		{input: `[tru]`, expected: ".[0]"},
+		{input: `{"a":tru}`, expected: ".a"},
+		{input: `{"a": [1, tru]}`, expected: ".a[1]"},
+		{input: `{"a": [1 tru]}`, expected: ".a"},
+		{input: `{"a": {"b": [1] x}}`, expected: ".a"},
+		{input: `[{"a": 1}] x`, expected: "."},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			var tokenErr c.UnexpectedTokenErr
+
+			err := Match(strings.NewReader(tc.input), func(path string, token z.Token) {})
+
+			if !errors.As(err, &tokenErr) || tokenErr.Path != tc.expected {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, err)
+			}
+		})
+	}
+}
+
+func TestMatchWithLimits(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		limits   Limits
+		expected error
+	}{
+		{name: "depth",
+			input:    strings.Repeat("[", 100) + strings.Repeat("]", 100),
+			limits:   Limits{MaxDepth: 64},
+			expected: c.DepthLimitErr{Limit: 64, Line: 1, Column: 65}},
+		{name: "stringLength",
+			input:    "{\"a\": \"" + strings.Repeat("x", 100) + "\"}",
+			limits:   Limits{MaxStringLength: 64},
+			expected: c.StringLengthLimitErr{Limit: 64, Line: 1, Column: 7}},
+		{name: "numberLength",
+			input:    "{\"a\": " + strings.Repeat("1", 100) + "}",
+			limits:   Limits{MaxNumberLength: 64},
+			expected: c.NumberLengthLimitErr{Limit: 64, Line: 1, Column: 7}},
+		{name: "tokens",
+			input:    "[1, 2, 3, 4]",
+			limits:   Limits{MaxTokens: 5},
+			expected: c.TokenCountLimitErr{Limit: 5, Line: 1, Column: 8}},
+		{name: "keysPerObject",
+			input:    "{\"a\": 1, \"b\": 2, \"c\": 3}",
+			limits:   Limits{MaxKeysPerObject: 2},
+			expected: c.KeyCountLimitErr{Limit: 2, Path: ".", Line: 1, Column: 18}},
+		{name: "inputBytes",
+			input:    "{\"a\": 1, \"b\": 2, \"c\": 3}",
+			limits:   Limits{MaxInputBytes: 16},
+			expected: c.InputSizeLimitErr{Limit: 16}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := Match(strings.NewReader(tc.input), func(path string, token z.Token) {}, WithLimits(tc.limits))
+
+			if !errors.Is(err, c.ErrLimitExceeded) {
+				t.Fatalf("Expected limit error, got '%v' instead\n", err)
+			}
+			if !reflect.DeepEqual(err, tc.expected) {
+				t.Errorf("Expected '%#v', got '%#v' instead\n", tc.expected, err)
+			}
+		})
+	}
+
+	err := Match(strings.NewReader("{\"a\": [1, 2]}"), func(path string, token z.Token) {}, WithLimits(Limits{MaxDepth: 2, MaxKeysPerObject: 1}))
+
+	if err != nil {
+		t.Errorf("Expected no error within the limits, got '%v' instead\n", err)
+	}
+}
+
+func TestMatchStopsOnError(t *testing.T) {
+	// an error found early, with plenty of input left to read
+	input := "[1 2" + strings.Repeat(", [3]", 10000) + "]"
+	deep := strings.Repeat("[", 100) + strings.Repeat(", 3", 10000)
+
+	testCases := []struct {
+		name string
+		run  func() error
+	}{
+		{name: "match", run: func() error {
+			return Match(strings.NewReader(input), func(path string, token z.Token) {})
+		}},
+		{name: "limits", run: func() error {
+			return Match(strings.NewReader(deep), func(path string, token z.Token) {}, WithLimits(Limits{MaxDepth: 10}))
+		}},
+		{name: "matchAll", run: func() error {
+			return MatchAll(strings.NewReader(input), []Matcher{func(path string, token z.Token) {}})
+		}},
+		{name: "matchContext", run: func() error {
+			return MatchContext(strings.NewReader(input), func(path string, token z.Token) bool { return true }, 1, func(Context) {})
+		}},
+		{name: "matchNDJSON", run: func() error {
+			return MatchNDJSON(strings.NewReader(input+"\n"+input), func(path string, token z.Token) {})
+		}},
+		{name: "rewrite", run: func() error {
+			return Rewrite(strings.NewReader(input), io.Discard, func(path string, token z.Token) Action { return Keep })
+		}},
+		{name: "project", run: func() error {
+			return Project(strings.NewReader(input), io.Discard, ".[0]")
+		}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			before := runtime.NumGoroutine()
+
+			for i := 0; i < 20; i++ {
+				if err := tc.run(); err == nil {
+					t.Fatal("Expected an error, got none")
+				}
+			}
+
+			// goroutines stopped may take a moment to be gone
+			after := runtime.NumGoroutine()
+
+			for i := 0; i < 100 && after > before; i++ {
+				time.Sleep(10 * time.Millisecond)
+				after = runtime.NumGoroutine()
+			}
+			if after > before {
+				t.Errorf("Expected '%v' goroutines, got '%v' instead\n", before, after)
+			}
+		})
+	}
+}
+
+func TestMatchWithDuplicateKeys(t *testing.T) {
+	input := "[{\"a\": 1, \"a\": [2]}, {\"b\": {\"a\": 3, \"a\": 4}, \"b\": 5}]"
+
+	var warnings []c.DuplicateKeyErr
+
+	collector := CollectorMatcher{
+		matches: make(map[string]z.Token),
+	}
+
+	err := Match(strings.NewReader(input), collector.Match, WithDuplicateKeys(WarnDuplicateKeys, func(err c.DuplicateKeyErr) {
+		warnings = append(warnings, err)
+	}))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expectedWarnings := []c.DuplicateKeyErr{
+		{Key: "a", Path: ".[0]", Line: 1, Column: 11, FirstLine: 1, FirstColumn: 3},
+		{Key: "a", Path: ".[1].b", Line: 1, Column: 37, FirstLine: 1, FirstColumn: 29},
+		{Key: "b", Path: ".[1]", Line: 1, Column: 46, FirstLine: 1, FirstColumn: 23},
+	}
+
+	if !reflect.DeepEqual(warnings, expectedWarnings) {
+		t.Errorf("Expected '%v', got '%v' instead\n", expectedWarnings, warnings)
+	}
+
+	var values []string
+
+	err = Match(strings.NewReader(input), func(path string, token z.Token) {
+		values = append(values, path+"="+token.Value)
+	}, WithDuplicateKeys(LastKeyWins, nil))
 
 	if err != nil {
-		b.Fatal(err)
+		t.Fatal(err)
+	}
+
+	expectedValues := []string{".[0].a[0]=2", ".[1].b=5"}
+
+	if !reflect.DeepEqual(values, expectedValues) {
+		t.Errorf("Expected '%v', got '%v' instead\n", expectedValues, values)
+	}
+
+	err = Match(strings.NewReader(input), func(path string, token z.Token) {}, WithDuplicateKeys(RejectDuplicateKeys, nil))
+
+	if !errors.Is(err, c.ErrDuplicateKey) {
+		t.Errorf("Expected duplicate key error, got '%v' instead\n", err)
+	}
+}
+
+func TestMatchOptions(t *testing.T) {
+	input := "{\"a\": [1, {\"b\": 2}], \"c\": 3}"
+
+	testCases := []struct {
+		name     string
+		opts     []Option
+		expected []string
+	}{
+		{name: "default",
+			opts:     nil,
+			expected: []string{".a[0]=1", ".a[1].b=2", ".c=3"}},
+		{name: "jsonPointer",
+			opts:     []Option{WithPathFormat(JSONPointer)},
+			expected: []string{"/a/0=1", "/a/1/b=2", "/c=3"}},
+		{name: "jsonPath",
+			opts:     []Option{WithPathFormat(JSONPath), WithBufferSize(16)},
+			expected: []string{"$.a[0]=1", "$.a[1].b=2", "$.c=3"}},
+		{name: "containers",
+			opts:     []Option{WithContainers()},
+			expected: []string{".={", ".a=[", ".a[0]=1", ".a[1]={", ".a[1].b=2", ".a[1]=}", ".a=]", ".c=3", ".=}"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var values []string
+
+			err := Match(strings.NewReader(input), func(path string, token z.Token) {
+				values = append(values, path+"="+token.Value)
+			}, tc.opts...)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(values, tc.expected) {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, values)
+			}
+		})
+	}
+
+	err := Match(strings.NewReader("[01]"), func(path string, token z.Token) {}, WithStrict())
+
+	if !errors.Is(err, c.ErrUnexpectedToken) {
+		t.Errorf("Expected unexpected token error, got '%v' instead\n", err)
+	}
+}
+
+func TestMatchLazy(t *testing.T) {
+	testCases := []struct {
+		name  string
+		input string
+		opts  []Option
+	}{
+		{name: "value", input: "1"},
+		{name: "default", input: "{\"a\": [1, {\"b c\": 2}], \"d\": [[3]]}"},
+		{name: "jsonPointer",
+			input: "{\"a\": [1, {\"b/c\": 2}], \"~\": 3}",
+			opts:  []Option{WithPathFormat(JSONPointer)}},
+		{name: "jsonPath",
+			input: "{\"a\": [1, {\"b'c\": 2}], \"d\": 3}",
+			opts:  []Option{WithPathFormat(JSONPath)}},
+		{name: "containers",
+			input: "[{\"a\": [1, {}]}, []]",
+			opts:  []Option{WithContainers()}},
+		{name: "lastKeyWins",
+			input: "{\"a\": {\"b\": 1}, \"a\": {\"c\": [2]}}",
+			opts:  []Option{WithDuplicateKeys(LastKeyWins, nil)}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var expected, values, appended []string
+			var buffer []byte
+
+			err := Match(strings.NewReader(tc.input), func(path string, token z.Token) {
+				expected = append(expected, path+"="+token.Value)
+			}, tc.opts...)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = MatchLazy(strings.NewReader(tc.input), func(path Path, token z.Token) {
+				values = append(values, path.String()+"="+token.Value)
+
+				buffer = path.AppendTo(buffer[:0])
+				appended = append(appended, string(buffer)+"="+token.Value)
+			}, tc.opts...)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(values, expected) {
+				t.Errorf("Expected '%v', got '%v' instead\n", expected, values)
+			}
+			if !reflect.DeepEqual(appended, expected) {
+				t.Errorf("Expected '%v', got '%v' instead\n", expected, appended)
+			}
+		})
+	}
+}
+
+func TestMatchContext(t *testing.T) {
+	input := `{"orders": [{"id": 1, "status": "failed", "items": ["a", "b"]}, {"id": 2, "status": "ok"}]}`
+
+	// the tokens of a context joined, with the paths of its matches
+	describe := func(context Context) string {
+		var values []string
+
+		for _, token := range context.Tokens {
+			values = append(values, token.Value)
+		}
+		for _, m := range context.Matches {
+			values = append(values, m.Path)
+		}
+		return context.Path + " " + strings.Join(values, "")
 	}
-	defer file.Close()
+
+	testCases := []struct {
+		name     string
+		input    string
+		filter   Filter
+		levels   int
+		opts     []Option
+		expected []string
+	}{
+		{name: "enclosingObject",
+			input: input,
+			filter: func(path string, token Token) bool {
+				return token.Value == "failed"
+			},
+			levels:   1,
+			expected: []string{".orders[0] {id:1,status:failed,items:[a,b]}.orders[0].status"}},
+		{name: "matchesMerged",
+			input: input,
+			filter: func(path string, token Token) bool {
+				return token.IsNumber()
+			},
+			levels: 0,
+			expected: []string{
+				".orders[0] {id:1,status:failed,items:[a,b]}.orders[0].id",
+				".orders[1] {id:2,status:ok}.orders[1].id",
+			}},
+		{name: "twoLevels",
+			input: input,
+			filter: func(path string, token Token) bool {
+				return token.Value == "b"
+			},
+			levels:   2,
+			expected: []string{".orders[0] {id:1,status:failed,items:[a,b]}.orders[0].items[1]"}},
+		{name: "pastTheRoot",
+			input: `{"a": [1]}`,
+			filter: func(path string, token Token) bool {
+				return true
+			},
+			levels:   5,
+			expected: []string{". {a:[1]}.a[0]"}},
+		{name: "nestedFirst",
+			input: `{"a": {"b": 1}, "c": 2}`,
+			filter: func(path string, token Token) bool {
+				return true
+			},
+			levels:   1,
+			expected: []string{".a {b:1}.a.b", ". {a:{b:1},c:2}.c"}},
+		{name: "shallowRoot",
+			input: `{"a": {"b": 1}, "c": 2}`,
+			filter: func(path string, token Token) bool {
+				return true
+			},
+			levels:   2,
+			expected: []string{". {a:{b:1},c:2}.a.b.c"}},
+		{name: "rootValue",
+			input: `"x"`,
+			filter: func(path string, token Token) bool {
+				return true
+			},
+			levels:   1,
+			expected: []string{". x."}},
+		{name: "deepSibling",
+			input: `{"x": "failed", "y": {"z": {"w": 1}}}`,
+			filter: func(path string, token Token) bool {
+				return token.Value == "failed"
+			},
+			levels:   1,
+			expected: []string{". {x:failed,y:{z:{w:1}}}.x"}},
+		{name: "prunedRoot",
+			input: `{"x": "failed", "y": {"z": {"w": 1}}}`,
+			filter: func(path string, token Token) bool {
+				return token.Value == "failed"
+			},
+			levels:   1,
+			opts:     []Option{WithPrunedRoot()},
+			expected: []string{". {x:failed}.x"}},
+		{name: "prunedRootNested",
+			input: `{"a": {"b": [1, {"c": 2}], "d": 3, "f": "x"}, "e": [4]}`,
+			filter: func(path string, token Token) bool {
+				return token.IsNumber()
+			},
+			levels: 3,
+			opts:   []Option{WithPrunedRoot()},
+			expected: []string{
+				".a {b:[1,{c:2}],d:3,f:x}.a.b[1].c",
+				". {a:{b:[1],d:3},e:[4]}.a.b[0].a.d.e[0]",
+			}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var contexts []string
+
+			err := MatchContext(strings.NewReader(tc.input), tc.filter, tc.levels, func(context Context) {
+				contexts = append(contexts, describe(context))
+			}, tc.opts...)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(contexts, tc.expected) {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, contexts)
+			}
+		})
+	}
+}
+
+// benchmarkInput is a document of strings, numbers and literals, the one
+// the tokenizer is benchmarked on.
+func benchmarkInput() string {
+	var input strings.Builder
+
+	input.WriteString("[")
+
+	for i := 0; i < 2000; i++ {
+		if i > 0 {
+			input.WriteString(",\n")
+		}
+		fmt.Fprintf(&input, `{"id": %d, "name": "user %d", "email": "user%d@example.com", "score": %d.%d, `+
+			`"active": %t, "manager": null, "bio": "café \"quoted\"\nnaïve", "tags": ["a", "b", "c"]}`,
+			i, i, i, i*7, i%100, i%2 == 0)
+	}
+
+	input.WriteString("]")
+	return input.String()
+}
+
+func BenchmarkMatch(b *testing.B) {
+	input := benchmarkInput()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
 
 	for i := 0; i < b.N; i++ {
+		if err := Match(strings.NewReader(input), func(path string, token z.Token) {}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMatchNDJSON(t *testing.T) {
+	input := "{\"a\": 1}\n\n  \r\n[true, \"x\"]\n\"y\"\n{\"a\": [}\n{\"a\": 2}"
+
+	testCases := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected []string
+		errLines []int
+	}{
+		{name: "valid",
+			input:    "{\"a\": 1}\n\n  \r\n[true, \"x\"]\r\n\"y\"\n",
+			expected: []string{"1 .a=1", "4 .[0]=true", "4 .[1]=x", "5 .=y"}},
+		{name: "stopsAtError",
+			input:    input,
+			expected: []string{"1 .a=1", "4 .[0]=true", "4 .[1]=x", "5 .=y"},
+			errLines: []int{6}},
+		{name: "recovery",
+			input:    input,
+			opts:     []Option{WithRecovery()},
+			expected: []string{"1 .a=1", "4 .[0]=true", "4 .[1]=x", "5 .=y", "7 .a=2"},
+			errLines: []int{6, 6}},
+		{name: "empty",
+			input:    "\n\n",
+			expected: nil},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var values []string
+
+			err := MatchNDJSON(strings.NewReader(tc.input), func(path string, token z.Token) {
+				values = append(values, strconv.Itoa(token.Line)+" "+path+"="+token.Value)
+			}, tc.opts...)
+
+			if !reflect.DeepEqual(values, tc.expected) {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, values)
+			}
+
+			var lines []int
+			var list c.ErrorList
+
+			if errors.As(err, &list) {
+				for _, e := range list {
+					lines = append(lines, e.(c.RecordErr).Line)
+				}
+			} else if e, ok := err.(c.RecordErr); ok {
+				lines = append(lines, e.Line)
+			} else if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(lines, tc.errLines) {
+				t.Errorf("Expected errors at lines '%v', got '%v' instead\n", tc.errLines, err)
+			}
+		})
+	}
+}
+
+func TestRewrite(t *testing.T) {
+	redact := func(path string, token z.Token) Action {
+		switch {
+		case strings.HasSuffix(path, "password"):
+			return ReplaceString("***")
+		case strings.Contains(path, "token"), token.IsNull():
+			return Remove
+		case token.IsNumber():
+			return Replace("0")
+		}
+		return Keep
+	}
+
+	pretty := "{\n  \"user\": \"ana\",\n  \"password\": \"s3\\\"cr\\\\et\",\n  \"token\": \"x\",\n  \"age\": 31\n}\n"
+
+	testCases := []struct {
+		name     string
+		input    string
+		opts     []Option
+		expected string
+	}{
+		{name: "keep",
+			input:    `{"a": "b", "c": [true, "d"]}`,
+			expected: `{"a": "b", "c": [true, "d"]}`},
+		{name: "replace",
+			input:    `{"password": "secret", "n": [1, 2.5e3]}`,
+			expected: `{"password": "***", "n": [0, 0]}`},
+		{name: "removeFirst",
+			input:    `{"token": "x", "a": "b"}`,
+			expected: `{"a": "b"}`},
+		{name: "removeMiddle",
+			input:    `["a", null, "b"]`,
+			expected: `["a", "b"]`},
+		{name: "removeLast",
+			input:    `{"a": "b", "token": "x"}`,
+			expected: `{"a": "b"}`},
+		{name: "removeAll",
+			input:    `{"token": "x", "b": [null, null], "c": null}`,
+			expected: `{"b": []}`},
+		{name: "removeNested",
+			input:    `[{"token": "x"}, [null], "a"]`,
+			expected: `[{}, [], "a"]`},
+		{name: "pretty",
+			input:    pretty,
+			expected: "{\n  \"user\": \"ana\",\n  \"password\": \"***\",\n  \"age\": 0\n}\n"},
+		{name: "compact",
+			input:    pretty,
+			opts:     []Option{WithCompactOutput()},
+			expected: `{"user":"ana","password":"***","age":0}`},
+		{name: "smallBuffer",
+			input:    pretty,
+			opts:     []Option{WithBufferSize(3)},
+			expected: "{\n  \"user\": \"ana\",\n  \"password\": \"***\",\n  \"age\": 0\n}\n"},
+		{name: "escapedKey",
+			input:    `{"a": "b", "x\"token": "y"}`,
+			expected: `{"a": "b"}`},
+		{name: "unicode",
+			input:    "{\"ключ\": \"значение\",\n \"n\": 1}",
+			expected: "{\"ключ\": \"значение\",\n \"n\": 0}"},
+		{name: "rootScalar",
+			input:    " 42 ",
+			expected: " 0 "},
+		{name: "rootRemoved",
+			input:    "null",
+			expected: "null"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out strings.Builder
+
+			if err := Rewrite(strings.NewReader(tc.input), &out, redact, tc.opts...); err != nil {
+				t.Fatal(err)
+			}
+
+			if out.String() != tc.expected {
+				t.Errorf("Expected %q, got %q instead\n", tc.expected, out.String())
+			}
+		})
+	}
+
+	t.Run("error", func(t *testing.T) {
+		var out strings.Builder
+
+		if err := Rewrite(strings.NewReader(`{"a": }`), &out, redact); err == nil {
+			t.Errorf("Expected an error, got '%v' instead\n", out.String())
+		}
+	})
+
+	t.Run("large", func(t *testing.T) {
+		var input, expected strings.Builder
+
+		input.WriteString("[")
+		expected.WriteString("[")
+
+		for i := 0; i < 50000; i++ {
+			if i > 0 {
+				input.WriteString(", ")
+				expected.WriteString(", ")
+			}
+			input.WriteString(`{"id": "x", "token": "y"}`)
+			expected.WriteString(`{"id": "x"}`)
+		}
 
-		Match(file, func(path string, token z.Token) {})
+		input.WriteString("]")
+		expected.WriteString("]")
+
+		var out strings.Builder
+
+		if err := Rewrite(strings.NewReader(input.String()), &out, redact); err != nil {
+			t.Fatal(err)
+		}
+
+		if out.String() != expected.String() {
+			t.Errorf("Expected %d bytes, got %d instead\n", expected.Len(), out.Len())
+		}
+	})
+
+	t.Run("streamsKeptValues", func(t *testing.T) {
+		input := "[" + strings.Repeat(`{"id": "x", "n": 1}, `, 100000) + "1]"
+		reader := &countingReader{r: strings.NewReader(input)}
+		out := &firstWriteRecorder{read: &reader.n}
+
+		if err := Rewrite(reader, out, func(path string, token z.Token) Action { return Keep }); err != nil {
+			t.Fatal(err)
+		}
+
+		if string(out.out) != input {
+			t.Errorf("Expected %d bytes, got %d instead\n", len(input), len(out.out))
+		}
+		if out.readAtFirstWrite > 256*1024 {
+			t.Errorf("Expected output before the input is read, got none before %d bytes\n", out.readAtFirstWrite)
+		}
+	})
+}
+
+type countingReader struct {
+	r io.Reader
+	n int
+}
+
+func (c *countingReader) Read(b []byte) (int, error) {
+	n, err := c.r.Read(b)
+	c.n += n
+	return n, err
+}
+
+// firstWriteRecorder tells how much of the input was read when the output
+// started to be written.
+type firstWriteRecorder struct {
+	out              []byte
+	read             *int
+	readAtFirstWrite int
+}
+
+func (f *firstWriteRecorder) Write(b []byte) (int, error) {
+	if len(f.out) == 0 {
+		f.readAtFirstWrite = *f.read
+	}
+	f.out = append(f.out, b...)
+	return len(b), nil
+}
+
+func TestProject(t *testing.T) {
+	input := `{"name": "x", "age": 3, "tags": ["a", "b", "c"],
+		"address": {"city": "y", "zip": 1, "geo": {"lat": 1.5, "lon": 2}},
+		"orders": [{"id": 1, "items": []}, {"id": 2, "note": null}], "empty": {}}`
+
+	testCases := []struct {
+		name     string
+		input    string
+		patterns []string
+		expected string
+	}{
+		{name: "keys",
+			input:    input,
+			patterns: []string{".name", ".address.city"},
+			expected: `{"name":"x","address":{"city":"y"}}`},
+		{name: "containers",
+			input:    input,
+			patterns: []string{".address.geo", ".empty"},
+			expected: `{"address":{"geo":{"lat":1.5,"lon":2}},"empty":{}}`},
+		{name: "compactedArrays",
+			input:    input,
+			patterns: []string{".tags[2]", ".orders[1].id"},
+			expected: `{"tags":["c"],"orders":[{"id":2}]}`},
+		{name: "wildcards",
+			input:    input,
+			patterns: []string{".orders[*].id", "..lon"},
+			expected: `{"address":{"geo":{"lon":2}},"orders":[{"id":1},{"id":2}]}`},
+		{name: "emptyContainerInside",
+			input:    input,
+			patterns: []string{".orders[].items"},
+			expected: `{"orders":[{"items":[]}]}`},
+		{name: "nothing",
+			input:    input,
+			patterns: []string{".missing"},
+			expected: `{}`},
+		{name: "root",
+			input:    `[1, {"a": "\"q\""}]`,
+			patterns: []string{"."},
+			expected: `[1,{"a":"\"q\""}]`},
+		{name: "rootScalar",
+			input:    `"x"`,
+			patterns: []string{"."},
+			expected: `"x"`},
+		{name: "rootScalarNotMatched",
+			input:    `"x"`,
+			patterns: []string{".a"},
+			expected: `null`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var out strings.Builder
+
+			if err := Project(strings.NewReader(tc.input), &out, tc.patterns...); err != nil {
+				t.Fatal(err)
+			}
+
+			if out.String() != tc.expected+"\n" {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, out.String())
+			}
+		})
+	}
+
+	t.Run("invalidPattern", func(t *testing.T) {
+		if err := Project(strings.NewReader(input), io.Discard, ".a["); err == nil {
+			t.Errorf("Expected an error, got '%v' instead\n", err)
+		}
+	})
+}
+
+func TestRouter(t *testing.T) {
+	input := `{"user": {"id": 7, "name": "x"}, "events": [{"type": "a", "id": 1}, {"type": "b"}]}`
+
+	var calls []string
+
+	handler := func(name string) Matcher {
+		return func(path string, token z.Token) {
+			calls = append(calls, name+" "+path+" "+token.Value)
+		}
+	}
+
+	router := NewRouter()
+	router.Handle(".user.id", handler("user"))
+	router.Handle(".events[*].type", handler("type"))
+	router.Handle("..id", handler("id"))
+	router.Handle(".events", handler("events"))
+
+	if err := Match(strings.NewReader(input), router.Dispatch, WithContainers()); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []string{
+		"user .user.id 7",
+		"id .user.id 7",
+		"events .events [",
+		"type .events[0].type a",
+		"id .events[0].id 1",
+		"type .events[1].type b",
+		"events .events ]",
+	}
+
+	if !reflect.DeepEqual(calls, expected) {
+		t.Errorf("Expected '%v', got '%v' instead\n", expected, calls)
+	}
+
+	t.Run("invalidPattern", func(t *testing.T) {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("Expected a panic\n")
+			}
+		}()
+		router.Handle(".a[", handler("invalid"))
+	})
+}
+
+func TestMatchAll(t *testing.T) {
+	input := `{"a": 1, "b": [true, null], "c": "x"}`
+	expected := []string{".a 1", ".b[0] true", ".b[1] null", ".c x"}
+
+	collect := func(values *[]string) Matcher {
+		return func(path string, token z.Token) {
+			*values = append(*values, path+" "+token.Value)
+		}
+	}
+
+	t.Run("inTurn", func(t *testing.T) {
+		var first, second []string
+
+		if err := MatchAll(strings.NewReader(input), []Matcher{collect(&first), collect(&second)}); err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(first, expected) || !reflect.DeepEqual(second, expected) {
+			t.Errorf("Expected '%v', got '%v' and '%v' instead\n", expected, first, second)
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		var first, second []string
+
+		err := MatchAll(strings.NewReader(input), []Matcher{collect(&first), collect(&second)},
+			WithConcurrentMatchers(1, WaitForSlowMatchers, nil))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(first, expected) || !reflect.DeepEqual(second, expected) {
+			t.Errorf("Expected '%v', got '%v' and '%v' instead\n", expected, first, second)
+		}
+	})
+
+	t.Run("drop", func(t *testing.T) {
+		var fast, slow []string
+		dropped := make([][]string, 2)
+
+		release := make(chan struct{})
+
+		slowMatcher := func(path string, token z.Token) {
+			<-release
+			slow = append(slow, path+" "+token.Value)
+		}
+
+		// the fast matcher may fall behind too, its goroutine not being
+		// scheduled in time, but none of its values are lost either
+		err := MatchAll(strings.NewReader(input), []Matcher{collect(&fast), slowMatcher},
+			WithConcurrentMatchers(1, DropForSlowMatchers, func(matcher int, path string, token z.Token) {
+				if matcher == 1 && len(dropped[1]) == 0 {
+					close(release)
+				}
+				dropped[matcher] = append(dropped[matcher], path+" "+token.Value)
+			}))
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(dropped[1]) == 0 {
+			t.Errorf("Expected values dropped for the slow matcher, got none\n")
+		}
+		for i, received := range [][]string{fast, slow} {
+			all := append(append([]string{}, received...), dropped[i]...)
+			sort.Strings(all)
+
+			if !reflect.DeepEqual(all, expected) {
+				t.Errorf("Expected '%v' received or dropped, got '%v' and '%v' instead\n", expected, received, dropped[i])
+			}
+		}
+	})
+
+	t.Run("error", func(t *testing.T) {
+		var values []string
+
+		err := MatchAll(strings.NewReader(`{"a": 1, "b" 2}`), []Matcher{collect(&values)},
+			WithConcurrentMatchers(0, WaitForSlowMatchers, nil))
+
+		if err == nil || !reflect.DeepEqual(values, []string{".a 1"}) {
+			t.Errorf("Expected an error after '.a 1', got '%v' and '%v' instead\n", err, values)
+		}
+	})
+}
+
+func TestMatchNDJSONAt(t *testing.T) {
+	var input strings.Builder
+
+	for i := 0; i < 200; i++ {
+		switch {
+		case i%17 == 0:
+			input.WriteString("\n  \r\n")
+		case i == 150:
+			input.WriteString("{\"a\": [}\n")
+		case i%23 == 0:
+			input.WriteString("{\"long\": \"" + strings.Repeat("x", 100) + "\"}\r\n")
+		default:
+			input.WriteString("{\"i\": " + strconv.Itoa(i) + ", \"b\": [true, null]}\n")
+		}
+	}
+	input.WriteString(`"last"`)
+
+	collect := func(values *[]string) Matcher {
+		return func(path string, token z.Token) {
+			*values = append(*values, strconv.Itoa(token.Line)+" "+path+"="+token.Value)
+		}
+	}
+
+	errLines := func(err error) []int {
+		var lines []int
+		var list c.ErrorList
+
+		if errors.As(err, &list) {
+			for _, e := range list {
+				lines = append(lines, e.(c.RecordErr).Line)
+			}
+		} else if e, ok := err.(c.RecordErr); ok {
+			lines = append(lines, e.Line)
+		}
+		return lines
+	}
+
+	for _, recovery := range []bool{false, true} {
+		var opts []Option
+
+		if recovery {
+			opts = append(opts, WithRecovery())
+		}
+
+		var expected []string
+		expectedErr := MatchNDJSON(strings.NewReader(input.String()), collect(&expected), opts...)
+
+		for _, chunkSize := range []int{1, 16, 100, 1 << 20} {
+			for _, unordered := range []bool{false, true} {
+				name := fmt.Sprintf("recovery=%v chunkSize=%d unordered=%v", recovery, chunkSize, unordered)
+
+				t.Run(name, func(t *testing.T) {
+					chunkOpts := append([]Option{WithWorkers(3), WithChunkSize(chunkSize)}, opts...)
+
+					if unordered {
+						chunkOpts = append(chunkOpts, WithUnorderedRecords())
+					}
+
+					var values []string
+					reader := strings.NewReader(input.String())
+					err := MatchNDJSONAt(reader, reader.Size(), collect(&values), chunkOpts...)
+
+					if unordered && !recovery {
+						if len(errLines(err)) != 1 {
+							t.Errorf("Expected an error, got '%v' instead\n", err)
+						}
+						return
+					}
+
+					expected := append([]string{}, expected...)
+
+					if unordered {
+						sort.Strings(values)
+						sort.Strings(expected)
+					}
+
+					if !reflect.DeepEqual(values, expected) {
+						t.Errorf("Expected '%v', got '%v' instead\n", expected, values)
+					}
+					if !reflect.DeepEqual(errLines(err), errLines(expectedErr)) {
+						t.Errorf("Expected '%v', got '%v' instead\n", expectedErr, err)
+					}
+				})
+			}
+		}
 	}
 }
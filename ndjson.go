@@ -0,0 +1,127 @@
+package jmatch
+
+import (
+	"bufio"
+	"errors"
+	"io"
+
+	c "github.com/rodic/jmatch/common"
+)
+
+// MatchNDJSON matches the records of newline delimited JSON, a document
+// per line, one after the other. Paths start from the root of each record
+// and the line of every token is the line of its record in the input.
+// Blank lines are skipped.
+//
+// Errors are returned as common.RecordErr, telling the line of the record.
+// With WithRecovery, a broken record is skipped and the next ones are
+// matched, all errors found being returned together as a
+// common.ErrorList.
+func MatchNDJSON(reader io.Reader, matcher Matcher, opts ...Option) error {
+	config := newConfig(opts)
+	input := bufio.NewReader(reader)
+	line := 1
+
+	var errs c.ErrorList
+
+	for {
+		skipped, err := skipBlankLines(input)
+		line += skipped
+
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		record := line
+		current := &lineReader{r: input}
+
+		err = Match(current, func(path string, token Token) {
+			token.Line = record
+			matcher(path, token)
+		}, opts...)
+
+		// the rest of a record given up on
+		if _, e := io.Copy(io.Discard, current); e != nil && err == nil {
+			err = e
+		}
+		line++
+
+		if err == nil {
+			continue
+		}
+
+		var list c.ErrorList
+
+		if !errors.As(err, &list) {
+			list = c.ErrorList{err}
+		}
+		for _, e := range list {
+			errs = append(errs, c.RecordErr{Line: record, Err: e})
+		}
+
+		if !config.parser.Recover {
+			return errs[0]
+		}
+	}
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// skipBlankLines reads up to the first character that is not whitespace
+// and returns the number of lines skipped.
+func skipBlankLines(r *bufio.Reader) (int, error) {
+	lines := 0
+
+	for {
+		b, err := r.ReadByte()
+
+		if err != nil {
+			return lines, err
+		}
+
+		switch b {
+		case '\n':
+			lines++
+		case ' ', '\t', '\r':
+		default:
+			return lines, r.UnreadByte()
+		}
+	}
+}
+
+// lineReader reads up to the end of the line, the newline left out, and
+// stops there.
+type lineReader struct {
+	r    *bufio.Reader
+	done bool
+}
+
+func (l *lineReader) Read(p []byte) (int, error) {
+	n := 0
+
+	for n < len(p) && !l.done {
+		b, err := l.r.ReadByte()
+
+		if err == io.EOF || b == '\n' {
+			l.done = true
+			break
+		}
+		if err != nil {
+			return n, err
+		}
+
+		p[n] = b
+		n++
+	}
+
+	if n == 0 && l.done {
+		return 0, io.EOF
+	}
+	return n, nil
+}
@@ -0,0 +1,341 @@
+package jmatch
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"runtime"
+	"sync"
+
+	c "github.com/rodic/jmatch/common"
+)
+
+const defaultChunkSize = 4 << 20
+
+// MatchNDJSONAt matches the records of newline delimited JSON the way
+// MatchNDJSON does, parsing them on many goroutines. The input, size bytes
+// long, is split into chunks at line boundaries, each parsed on its own,
+// which suits large files of independent records:
+//
+//	info, err := file.Stat()
+//	err = jmatch.MatchNDJSONAt(file, info.Size(), matcher, jmatch.WithWorkers(8))
+//
+// The matcher is called on the calling goroutine, one value at a time, the
+// values of a record never mixed with those of another. Records come in
+// the order of the input unless WithUnorderedRecords is given, in which
+// case the records of a chunk come as soon as it is parsed. Lines of
+// tokens and errors are those of the input either way.
+//
+// Without WithRecovery, the first error found stops matching, the chunks
+// being parsed given up on. Chunks parsed and not yet matched are held in
+// memory, at most two per worker.
+func MatchNDJSONAt(reader io.ReaderAt, size int64, matcher Matcher, opts ...Option) error {
+	config := newConfig(opts)
+
+	workers := config.workers
+
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	chunkSize := int64(config.chunkSize)
+
+	if chunkSize <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	chunks := int((size + chunkSize - 1) / chunkSize)
+
+	jobs := make(chan int)
+	results := make(chan chunkResult)
+	slots := make(chan struct{}, 2*workers) // chunks taken and not matched yet
+	done := make(chan struct{})
+
+	go func() {
+		defer close(jobs)
+
+		for i := 0; i < chunks; i++ {
+			select {
+			case slots <- struct{}{}:
+			case <-done:
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var wg sync.WaitGroup
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				parseChunk(reader, size, chunkSize, i, opts, results, done)
+			}
+		}()
+	}
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	m := &chunkMatcher{
+		matcher:   matcher,
+		unordered: config.unordered,
+		starts:    []int{1},
+		counted:   map[int]int{},
+		parsed:    map[int]chunkResult{},
+	}
+
+	err := m.receive(results, slots, config.parser.Recover)
+
+	close(done)
+	for range results {
+	}
+
+	if err != nil {
+		return err
+	}
+	if len(m.errs) > 0 {
+		return m.errs
+	}
+	return nil
+}
+
+// WithWorkers sets the number of goroutines MatchNDJSONAt parses chunks
+// on, as many as Go runs at once by default.
+func WithWorkers(workers int) Option {
+	return func(c *config) {
+		c.workers = workers
+	}
+}
+
+// WithChunkSize sets the size of the chunks MatchNDJSONAt splits its input
+// into, 4 MiB by default. Chunks are made of whole lines, so a line longer
+// than the chunk size makes a chunk of its own.
+func WithChunkSize(size int) Option {
+	return func(c *config) {
+		c.chunkSize = size
+	}
+}
+
+// WithUnorderedRecords makes MatchNDJSONAt pass records to the matcher as
+// soon as they are parsed instead of in the order of the input.
+func WithUnorderedRecords() Option {
+	return func(c *config) {
+		c.unordered = true
+	}
+}
+
+// chunkResult is sent twice for every chunk: once its lines are counted,
+// then once it is parsed.
+type chunkResult struct {
+	index  int
+	parsed bool
+	lines  int
+	values []chunkValue // lines counted from the start of the chunk
+	err    error
+}
+
+type chunkValue struct {
+	path  string
+	token Token
+}
+
+func parseChunk(reader io.ReaderAt, size int64, chunkSize int64, i int, opts []Option, results chan<- chunkResult, done <-chan struct{}) {
+	send := func(result chunkResult) bool {
+		select {
+		case results <- result:
+			return true
+		case <-done:
+			return false
+		}
+	}
+
+	data, err := readChunk(reader, size, chunkSize, i)
+
+	if !send(chunkResult{index: i, lines: bytes.Count(data, []byte{'\n'})}) {
+		return
+	}
+	if err != nil {
+		send(chunkResult{index: i, parsed: true, err: err})
+		return
+	}
+
+	result := chunkResult{index: i, parsed: true}
+
+	result.err = MatchNDJSON(bytes.NewReader(data), func(path string, token Token) {
+		result.values = append(result.values, chunkValue{path: path, token: token})
+	}, opts...)
+
+	send(result)
+}
+
+// readChunk reads the lines starting in the i-th chunkSize bytes of the
+// input.
+func readChunk(reader io.ReaderAt, size int64, chunkSize int64, i int) ([]byte, error) {
+	start, err := lineStart(reader, size, int64(i)*chunkSize)
+
+	if err != nil {
+		return nil, err
+	}
+
+	end, err := lineStart(reader, size, int64(i+1)*chunkSize)
+
+	if err != nil || end <= start {
+		return nil, err
+	}
+
+	data := make([]byte, end-start)
+
+	if n, err := reader.ReadAt(data, start); n < len(data) {
+		return nil, err
+	}
+	return data, nil
+}
+
+// lineStart returns the offset of the first line starting at pos or after
+// it, size if there is none.
+func lineStart(reader io.ReaderAt, size int64, pos int64) (int64, error) {
+	if pos <= 0 {
+		return 0, nil
+	}
+	if pos >= size {
+		return size, nil
+	}
+
+	// from the byte before, a line starting right at pos
+	input := bufio.NewReader(io.NewSectionReader(reader, pos-1, size-pos+1))
+	offset := pos - 1
+
+	for {
+		line, err := input.ReadSlice('\n')
+		offset += int64(len(line))
+
+		switch err {
+		case nil:
+			return offset, nil
+		case io.EOF:
+			return size, nil
+		case bufio.ErrBufferFull:
+			continue
+		default:
+			return 0, err
+		}
+	}
+}
+
+// chunkMatcher passes the values of the chunks parsed to the matcher, once
+// the line each chunk starts at is known.
+type chunkMatcher struct {
+	matcher   Matcher
+	unordered bool
+
+	starts  []int       // lines the chunks start at, known for the first ones
+	counted map[int]int // lines of the chunks counted past those
+	parsed  map[int]chunkResult
+	next    int // chunk to match next, in order
+	errs    c.ErrorList
+}
+
+func (m *chunkMatcher) receive(results <-chan chunkResult, slots <-chan struct{}, recovery bool) error {
+	for result := range results {
+		if !result.parsed {
+			m.counted[result.index] = result.lines
+
+			// the start of the next chunk is known once those before are counted
+			for {
+				last := len(m.starts) - 1
+				lines, ok := m.counted[last]
+
+				if !ok {
+					break
+				}
+				delete(m.counted, last)
+				m.starts = append(m.starts, m.starts[last]+lines)
+			}
+		} else {
+			m.parsed[result.index] = result
+		}
+
+		for _, ready := range m.ready() {
+			<-slots
+
+			if err := m.match(ready, recovery); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// ready returns the chunks parsed that can be matched now.
+func (m *chunkMatcher) ready() []chunkResult {
+	var ready []chunkResult
+
+	if m.unordered {
+		for i, result := range m.parsed {
+			if i < len(m.starts) {
+				ready = append(ready, result)
+				delete(m.parsed, i)
+			}
+		}
+		return ready
+	}
+
+	for {
+		result, ok := m.parsed[m.next]
+
+		if !ok {
+			return ready
+		}
+		ready = append(ready, result)
+		delete(m.parsed, m.next)
+		m.next++
+	}
+}
+
+func (m *chunkMatcher) match(result chunkResult, recovery bool) error {
+	offset := m.starts[result.index] - 1
+
+	for _, v := range result.values {
+		v.token.Line += offset
+		m.matcher(v.path, v.token)
+	}
+
+	if result.err == nil {
+		return nil
+	}
+
+	err := shiftLines(result.err, offset)
+
+	if list, ok := err.(c.ErrorList); ok && recovery {
+		m.errs = append(m.errs, list...)
+		return nil
+	}
+	return err
+}
+
+// shiftLines moves the lines of the records in error by the offset.
+func shiftLines(err error, offset int) error {
+	switch e := err.(type) {
+	case c.RecordErr:
+		e.Line += offset
+		return e
+	case c.ErrorList:
+		shifted := make(c.ErrorList, len(e))
+
+		for i := range e {
+			shifted[i] = shiftLines(e[i], offset)
+		}
+		return shifted
+	}
+	return err
+}
@@ -0,0 +1,178 @@
+package jmatch
+
+import (
+	c "github.com/rodic/jmatch/common"
+	p "github.com/rodic/jmatch/parser"
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+// Option changes how Match reads its input.
+type Option func(*config)
+
+type config struct {
+	tokenizer t.Config
+	parser    p.Config
+	compact   bool    // output of Rewrite
+	fanOut    *fanOut // of MatchAll, nil when matchers are called in turn
+
+	// of MatchNDJSONAt
+	workers   int
+	chunkSize int
+	unordered bool
+}
+
+func newConfig(opts []Option) config {
+	var config config
+
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+// WithRecovery makes Match carry on after a syntax error. Parsing resumes
+// at the next comma or closing paren on the same nesting level and the
+// matcher keeps receiving values. The errors found until then follow from
+// the first one and are dropped; all others are returned together as a
+// common.ErrorList.
+func WithRecovery() Option {
+	return func(c *config) {
+		c.tokenizer.Recover = true
+		c.parser.Recover = true
+	}
+}
+
+// WithStrict rejects input that is not valid RFC 8259 JSON but is let
+// through by default: unknown escapes, escapes of unpaired surrogates and
+// raw control characters in strings. Numbers are always held to RFC 8259.
+func WithStrict() Option {
+	return func(c *config) {
+		c.tokenizer.Strict = true
+	}
+}
+
+// WithCompactOutput makes Rewrite leave out the whitespace between tokens
+// instead of copying the formatting of the input.
+func WithCompactOutput() Option {
+	return func(c *config) {
+		c.compact = true
+	}
+}
+
+type PathFormat = p.PathFormat
+
+const (
+	JQPath      = p.JQPath
+	JSONPointer = p.JSONPointer
+	JSONPath    = p.JSONPath
+)
+
+// WithPathFormat sets the notation of the paths passed to the matcher, jq
+// by default.
+func WithPathFormat(format PathFormat) Option {
+	return func(c *config) {
+		c.parser.PathFormat = format
+	}
+}
+
+// WithContainers passes the tokens opening and closing objects and arrays
+// to the matcher too, along with the path of the container.
+func WithContainers() Option {
+	return func(c *config) {
+		c.parser.EmitContainers = true
+	}
+}
+
+// WithPrunedRoot makes MatchContext keep none of the root container, so
+// that memory does not grow with the input. The values picked for the root
+// are passed in a root holding them only, along with the objects and
+// arrays leading to them, the keys of objects kept but not the indexes of
+// arrays.
+func WithPrunedRoot() Option {
+	return func(c *config) {
+		c.parser.UnbufferedRoot = true
+	}
+}
+
+// WithBufferSize sets the size of the buffer the input is read through.
+func WithBufferSize(size int) Option {
+	return func(c *config) {
+		c.tokenizer.BufferSize = size
+	}
+}
+
+// Limits bound the resources spent on untrusted input. A limit set to zero
+// is not enforced. Going over a limit stops matching with an error matching
+// common.ErrLimitExceeded.
+type Limits struct {
+	MaxDepth         int   // of nested objects and arrays
+	MaxStringLength  int   // in bytes
+	MaxNumberLength  int   // in characters
+	MaxTokens        int   // in the whole input
+	MaxKeysPerObject int   // for every object on its own
+	MaxInputBytes    int64 // read from the reader
+}
+
+// WithLimits makes Match fail as soon as the input goes over one of the
+// given limits.
+func WithLimits(limits Limits) Option {
+	return func(c *config) {
+		c.tokenizer.MaxStringLength = limits.MaxStringLength
+		c.tokenizer.MaxNumberLength = limits.MaxNumberLength
+		c.tokenizer.MaxTokens = limits.MaxTokens
+		c.tokenizer.MaxInputBytes = limits.MaxInputBytes
+		c.parser.MaxDepth = limits.MaxDepth
+		c.parser.MaxKeysPerObject = limits.MaxKeysPerObject
+	}
+}
+
+type DuplicateKeyPolicy = p.DuplicateKeyPolicy
+
+const (
+	AllowDuplicateKeys  = p.AllowDuplicateKeys
+	RejectDuplicateKeys = p.RejectDuplicateKeys
+	WarnDuplicateKeys   = p.WarnDuplicateKeys
+	FirstKeyWins        = p.FirstKeyWins
+	LastKeyWins         = p.LastKeyWins
+)
+
+// WithDuplicateKeys makes Match look for keys found twice in the same
+// object and treat them according to the policy. The warn function is
+// called for every duplicate with the WarnDuplicateKeys policy and may be
+// nil otherwise.
+func WithDuplicateKeys(policy DuplicateKeyPolicy, warn func(c.DuplicateKeyErr)) Option {
+	return func(c *config) {
+		c.parser.DuplicateKeys = policy
+		c.parser.OnDuplicateKey = warn
+	}
+}
+
+// SlowMatcherPolicy tells MatchAll what to do with a value for a matcher
+// running concurrently whose buffer is full.
+type SlowMatcherPolicy int
+
+const (
+	// WaitForSlowMatchers holds parsing until the matcher takes the value,
+	// so that the slowest matcher sets the pace.
+	WaitForSlowMatchers SlowMatcherPolicy = iota
+	// DropForSlowMatchers drops the value for that matcher, the others
+	// still receiving it.
+	DropForSlowMatchers
+)
+
+type fanOut struct {
+	buffer  int
+	policy  SlowMatcherPolicy
+	dropped func(matcher int, path string, token Token)
+}
+
+// WithConcurrentMatchers makes MatchAll run every matcher on a goroutine
+// of its own, with a buffer of the given number of values between it and
+// the parser. The dropped function is called, on the goroutine parsing,
+// for every value dropped with the DropForSlowMatchers policy, along with
+// the position of the matcher; it may be nil.
+func WithConcurrentMatchers(buffer int, policy SlowMatcherPolicy, dropped func(matcher int, path string, token Token)) Option {
+	return func(c *config) {
+		c.fanOut = &fanOut{buffer: buffer, policy: policy, dropped: dropped}
+	}
+}
@@ -1,47 +1,116 @@
 package parser
 
-import (
-	"fmt"
-	"strings"
-)
+import "github.com/rodic/jmatch/paths"
 
 type context interface {
 	getPath() string
+	getContainerPath() string
+	getLocation() location
+	// segment is the key or index of the value that comes next
+	segment() paths.Segment
 	setValue()
 	isObject() bool
 	isArray() bool
 
+	// where the tokens of the container start among the ones kept with
+	// BufferContainers, -1 if they are not kept
+	getStart() int
+	setStart(int)
+
 	// only object use, refactor...
 	setKey(string)
 	isKeySet() bool
+	countKeys() int
+	getKeys() *keyTracker
+}
+
+// location is the path of a container: length bytes of the path buffer
+// and, with lazy paths, a Path the ones of its values point to.
+type location struct {
+	paths  *pathBuffer
+	length int
+	lazy   *Path // nil unless paths are lazy
+}
+
+func (l location) getContainerPath() string {
+	return string(l.paths.path[:l.length])
+}
+
+func (l location) getLocation() location {
+	return l
+}
+
+// pathOf writes the path of the value of the container at the segment.
+func (l location) pathOf(segment paths.Segment) string {
+	return string(l.paths.write(l.length, segment))
+}
+
+// lazyPathOf is the path of the value of the container at the segment.
+func (l location) lazyPathOf(segment paths.Segment) Path {
+	return Path{format: l.paths.format, parent: l.lazy, segment: segment}
+}
+
+// child is the location of the container at the segment.
+func (l location) child(segment paths.Segment) location {
+	child := location{paths: l.paths, length: len(l.paths.write(l.length, segment))}
+
+	if l.lazy != nil {
+		path := l.lazyPathOf(segment)
+		child.lazy = &path
+	}
+	return child
+}
+
+// span is where the tokens of a container start among the ones kept by
+// the parser.
+type span struct {
+	start int
+}
+
+func (s *span) getStart() int {
+	return s.start
+}
+
+func (s *span) setStart(start int) {
+	s.start = start
 }
 
 type objectContext struct {
-	path string
-	key  string
+	location
+	span
+	key       string
+	keySet    bool
+	keysCount int
+	keys      *keyTracker // nil unless duplicate keys are looked for
 }
 
 func (o *objectContext) isKeySet() bool {
-	return o.path != o.key
+	return o.keySet
 }
 
 func (o *objectContext) setKey(key string) {
-	var newKey strings.Builder
-	newKey.WriteString(o.path)
-	newKey.WriteRune('.')
-
-	if strings.ContainsAny(key, " .") { // keys with . or space.
-		newKey.WriteRune('"')
-		newKey.WriteString(key)
-		newKey.WriteRune('"')
-	} else {
-		newKey.WriteString(key)
-	}
-	o.key = newKey.String()
+	o.key = key
+	o.keySet = true
+	o.keysCount++
+}
+
+func (o *objectContext) countKeys() int {
+	return o.keysCount
+}
+
+func (o *objectContext) getKeys() *keyTracker {
+	return o.keys
 }
 
 func (o *objectContext) getPath() string {
-	return o.key
+	if !o.keySet {
+		return o.getContainerPath()
+	}
+	return o.pathOf(o.segment())
+}
+
+func (o *objectContext) segment() paths.Segment {
+	return paths.KeySegment(o.key)
 }
 
 func (o *objectContext) isArray() bool {
@@ -53,18 +122,19 @@ func (o *objectContext) isObject() bool {
 }
 
 func (o *objectContext) setValue() {
-	o.key = o.path
+	o.keySet = false
 }
 
-func newObjectContext(path string) *objectContext {
+func newObjectContext(location location) *objectContext {
 	return &objectContext{
-		path: path,
-		key:  path,
+		location:  location,
+		keysCount: 0,
 	}
 }
 
 type arrayContext struct {
-	path       string
+	location
+	span
 	elemsCount int
 }
 
@@ -76,8 +146,20 @@ func (a *arrayContext) setKey(string) {
 	panic("unimplemented")
 }
 
+func (a *arrayContext) countKeys() int {
+	panic("unimplemented")
+}
+
+func (a *arrayContext) getKeys() *keyTracker {
+	return nil
+}
+
 func (a *arrayContext) getPath() string {
-	return fmt.Sprintf("%s[%d]", a.path, a.elemsCount)
+	return a.pathOf(a.segment())
+}
+
+func (a *arrayContext) segment() paths.Segment {
+	return paths.IndexSegment(a.elemsCount)
 }
 
 func (a *arrayContext) setValue() {
@@ -92,9 +174,9 @@ func (a *arrayContext) isArray() bool {
 	return true
 }
 
-func newArrayContext(path string) *arrayContext {
+func newArrayContext(location location) *arrayContext {
 	return &arrayContext{
-		path:       path,
+		location:   location,
 		elemsCount: 0,
 	}
 }
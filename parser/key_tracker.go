@@ -0,0 +1,88 @@
+package parser
+
+import t "github.com/rodic/jmatch/tokenizer"
+
+// DuplicateKeyPolicy tells the parser what to do with a key found twice in
+// the same object.
+type DuplicateKeyPolicy int
+
+const (
+	// AllowDuplicateKeys emits the values of every occurrence, as if the
+	// keys were different. Keys are not tracked at all.
+	AllowDuplicateKeys DuplicateKeyPolicy = iota
+	// RejectDuplicateKeys fails with a common.DuplicateKeyErr.
+	RejectDuplicateKeys
+	// WarnDuplicateKeys calls Config.OnDuplicateKey and emits the values
+	// of every occurrence.
+	WarnDuplicateKeys
+	// FirstKeyWins emits only the value of the first occurrence.
+	FirstKeyWins
+	// LastKeyWins emits only the value of the last occurrence. Since a key
+	// can show up again at any point, values are held back until the
+	// object they are in is closed.
+	LastKeyWins
+)
+
+// keyTracker remembers the keys of an object seen so far. Objects are
+// given one only when duplicate keys have to be found.
+type keyTracker struct {
+	first    map[string]t.Token // first occurrence of every key
+	counts   map[string]int
+	current  string // key of the pair being parsed
+	ignoring bool   // whether the current pair repeats a key
+	held     []heldResult
+}
+
+// heldResult is a value held back until its object is closed, along with
+// the occurrence of the key it was found under.
+type heldResult struct {
+	key        string
+	occurrence int
+	result     ParsingResult
+}
+
+func newKeyTracker() *keyTracker {
+	return &keyTracker{
+		first:  map[string]t.Token{},
+		counts: map[string]int{},
+	}
+}
+
+// add records the key and returns its first occurrence if it was seen
+// before.
+func (k *keyTracker) add(key t.Token) (t.Token, bool) {
+	first, seen := k.first[key.Value]
+
+	if !seen {
+		k.first[key.Value] = key
+	}
+
+	k.current = key.Value
+	k.counts[key.Value]++
+	k.ignoring = seen
+
+	return first, seen
+}
+
+func (k *keyTracker) hold(result ParsingResult) {
+	k.held = append(k.held, heldResult{
+		key:        k.current,
+		occurrence: k.counts[k.current],
+		result:     result,
+	})
+}
+
+// release returns the held values found under the last occurrence of
+// their keys.
+func (k *keyTracker) release() []ParsingResult {
+	results := make([]ParsingResult, 0, len(k.held))
+
+	for _, h := range k.held {
+		if h.occurrence == k.counts[h.key] {
+			results = append(results, h.result)
+		}
+	}
+
+	k.held = nil
+	return results
+}
@@ -23,9 +23,37 @@ func (p *parenCounter) isBalanced() bool {
 	return p.bracesCounter == 0 && p.bracketCounter == 0
 }
 
+// isOpen tells whether some container was opened but not closed.
+func (p *parenCounter) isOpen() bool {
+	return p.bracesCounter > 0 || p.bracketCounter > 0
+}
+
 func newParenCounter() parenCounter {
 	return parenCounter{
 		bracketCounter: 0,
 		bracesCounter:  0,
 	}
 }
+
+// undo takes back the update for a token the parser decided to skip.
+func (p *parenCounter) undo(t t.Token) {
+	if t.IsLeftBrace() {
+		p.bracesCounter--
+	} else if t.IsRightBrace() {
+		p.bracesCounter++
+	} else if t.IsLeftBracket() {
+		p.bracketCounter--
+	} else if t.IsRightBracket() {
+		p.bracketCounter++
+	}
+}
+
+// closeImplicitly accounts for a context that was closed without its
+// closing paren during error recovery.
+func (p *parenCounter) closeImplicitly(c context) {
+	if c.isObject() {
+		p.bracesCounter--
+	} else if c.isArray() {
+		p.bracketCounter--
+	}
+}
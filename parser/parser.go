@@ -1,7 +1,10 @@
 package parser
 
 import (
+	"errors"
+
 	c "github.com/rodic/jmatch/common"
+	"github.com/rodic/jmatch/paths"
 	t "github.com/rodic/jmatch/tokenizer"
 )
 
@@ -9,52 +12,546 @@ type ParsingResult struct {
 	Path  string
 	Token t.Token
 	Error error
+	// Container holds the tokens of the container closed by Token, from
+	// the opening paren to the closing one, with BufferContainers. They
+	// are shared with the parser, which never writes over them.
+	Container []t.Token
+	// Lazy is the path of the value with LazyPaths, Path being left empty.
+	Lazy Path
+}
+
+// Config controls how the parser treats its input. Limits set to zero are
+// not enforced.
+type Config struct {
+	// Recover makes the parser report a syntax error and resynchronize at
+	// the next comma or closing paren on the same nesting level instead of
+	// stopping. Errors are sent on the result stream as they are found,
+	// except those found before it resynchronizes. Exceeded limits always
+	// stop the parser.
+	Recover bool
+
+	PathFormat PathFormat
+	// LazyPaths makes the parser pass the paths of values as Path, written
+	// out only if asked for, instead of as strings.
+	LazyPaths bool
+	// EmitContainers makes the parser emit the tokens opening and closing
+	// objects and arrays, along with the path of the container.
+	EmitContainers bool
+	// BufferContainers makes the parser keep the tokens of every open
+	// container and pass them along with the closing token emitted with
+	// EmitContainers. Each token is kept once, for all the containers it
+	// is in, until the outermost one is closed. The root container holds
+	// the whole input, so memory grows with it unless UnbufferedRoot is
+	// set.
+	BufferContainers bool
+	// UnbufferedRoot leaves the root container out of BufferContainers,
+	// none of its tokens being kept and none passed with its closing token.
+	UnbufferedRoot bool
+
+	MaxDepth         int // of nested objects and arrays, the root one included
+	MaxKeysPerObject int
+
+	DuplicateKeys DuplicateKeyPolicy
+	// OnDuplicateKey is called from the parsing goroutine for every
+	// duplicate key found with the WarnDuplicateKeys policy.
+	OnDuplicateKey func(c.DuplicateKeyErr)
 }
 
 type parser struct {
 	tokens       tokenList
 	context      context
 	stack        contextStack
+	config       Config
+	rootClosed   bool
+	buffer       []t.Token // kept with BufferContainers
+	bufferOffset int       // of the first token in buffer, in the input
+	failure      *failure  // reported last, while the parser recovers from it
 	resultStream chan ParsingResult
+	done         chan struct{} // closed by Stop
 }
 
-func NewParser(tokenStream <-chan t.TokenResult) (*parser, error) {
-	tokens, err := NewTokens(tokenStream)
+// failure is where an error was found in recovery mode: the position of
+// the token and the nesting level.
+type failure struct {
+	line   int
+	column int
+	level  int
+}
 
-	if err != nil {
-		return nil, err
-	}
+func NewParser(tokenStream <-chan t.TokenResult, config Config) (*parser, error) {
+	return newParser(streamSource(tokenStream), nil, config)
+}
 
-	parser := parser{
+// NewScannerParser makes a parser reading the tokens of the scanner itself,
+// on the goroutine running Parse, instead of from a stream fed by another
+// one. The first two tokens are read right away.
+func NewScannerParser(scanner *t.Scanner, config Config) (*parser, error) {
+	keys := keyCache{}
+	return newParser(scannerSource(scanner, keys), keys, config)
+}
+
+func newParser(source tokenSource, keys keyCache, config Config) (*parser, error) {
+	tokens, err := newTokens(source, keys, config.Recover)
+
+	p := &parser{
 		tokens:       *tokens,
 		stack:        newContextStack(),
+		config:       config,
 		resultStream: make(chan ParsingResult),
+		done:         make(chan struct{}),
 	}
 
-	return &parser, nil
+	if err != nil {
+		return nil, p.withPath(err, p.tokens.current)
+	}
+	return p, nil
 }
 
 func (p *parser) GetResultReadStream() <-chan ParsingResult {
 	return p.resultStream
 }
 
+// Stop makes Parse give up on the rest of the tokens, the results not read
+// yet being dropped, and returns once it has. It must be called once Parse
+// is started, by the reader of the result stream when it is done with it.
+// The token stream is not read from anymore, so the tokenizer should be
+// stopped next.
+func (p *parser) Stop() {
+	select {
+	case <-p.done:
+	default:
+		close(p.done)
+	}
+
+	for range p.resultStream {
+	}
+}
+
+// send passes the result on, unless the parser is stopped.
+func (p *parser) send(result ParsingResult) {
+	select {
+	case p.resultStream <- result:
+	case <-p.done:
+	}
+}
+
+func (p *parser) stopped() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
 func (p *parser) isValue(t t.Token) bool {
-	return t.IsString() || t.IsNumber() || t.IsBoolean() || t.IsNull()
+	return t.IsString() || t.IsNumber() || t.IsBoolean() || t.IsNull() || t.IsInvalid()
+}
+
+// emit sends the value of the current container at the segment.
+func (p *parser) emit(segment paths.Segment, token t.Token) {
+	if token.IsInvalid() {
+		return // reported by the tokenizer
+	}
+
+	container := p.context.getLocation()
+
+	if p.config.LazyPaths {
+		p.deliver(ParsingResult{Lazy: container.lazyPathOf(segment), Token: token}, p.stack.cnt)
+	} else {
+		p.deliver(ParsingResult{Path: container.pathOf(segment), Token: token}, p.stack.cnt)
+	}
+}
+
+// containerResult is the result of a token opening or closing the
+// container.
+func (p *parser) containerResult(container location, token t.Token) ParsingResult {
+	if p.config.LazyPaths {
+		return ParsingResult{Lazy: *container.lazy, Token: token}
+	}
+	return ParsingResult{Path: p.displayPath(container.getContainerPath()), Token: token}
+}
+
+// at returns the context on the given nesting level, the current one being
+// on the level p.stack.cnt.
+func (p *parser) at(level int) context {
+	if level == p.stack.cnt {
+		return p.context
+	}
+	return p.stack.stack[level]
+}
+
+// deliver sends a value found on the given nesting level unless the
+// duplicate key policy drops it or holds it back.
+func (p *parser) deliver(result ParsingResult, level int) {
+	switch p.config.DuplicateKeys {
+	case FirstKeyWins:
+		for l := level; l >= 0; l-- {
+			if keys := p.at(l).getKeys(); keys != nil && keys.ignoring {
+				return
+			}
+		}
+	case LastKeyWins:
+		for l := level; l >= 0; l-- {
+			if keys := p.at(l).getKeys(); keys != nil {
+				keys.hold(result)
+				return
+			}
+		}
+	}
+	p.send(result)
+}
+
+// release passes the values held back by the object on the given nesting
+// level to the one around it once it is closed.
+func (p *parser) release(level int) {
+	if p.config.DuplicateKeys != LastKeyWins {
+		return
+	}
+	if keys := p.at(level).getKeys(); keys != nil {
+		for _, result := range keys.release() {
+			p.deliver(result, level-1)
+		}
+	}
+}
+
+func (p *parser) releaseAll() {
+	for l := p.stack.cnt; l >= 0; l-- {
+		p.release(l)
+	}
+}
+
+func (p *parser) newObjectContext(container location) *objectContext {
+	context := newObjectContext(container)
+
+	if p.config.DuplicateKeys != AllowDuplicateKeys {
+		context.keys = newKeyTracker()
+	}
+	return context
+}
+
+// addKey applies the duplicate key policy to a key of the current object.
+func (p *parser) addKey(key t.Token) error {
+	first, duplicate := p.context.getKeys().add(key)
+
+	if !duplicate {
+		return nil
+	}
+
+	err := c.DuplicateKeyErr{
+		Key:         key.Value,
+		Path:        p.displayPath(p.context.getContainerPath()),
+		Line:        key.Line,
+		Column:      key.Column,
+		FirstLine:   first.Line,
+		FirstColumn: first.Column,
+	}
+
+	switch p.config.DuplicateKeys {
+	case RejectDuplicateKeys:
+		return err
+	case WarnDuplicateKeys:
+		if p.config.OnDuplicateKey != nil {
+			p.config.OnDuplicateKey(err)
+		}
+	}
+	return nil
+}
+
+// report sends a syntax error found in recovery mode. The errors found
+// next are dropped until the parser is back on track, at a comma or a
+// closing paren past the error on its nesting level or one around it: a
+// lexeme broken beyond repair is reported by the tokenizer and usually
+// trips it up right after, and so does every token skipped.
+func (p *parser) report(err error) {
+	if p.failure != nil {
+		return
+	}
+
+	p.failure = &failure{level: p.stack.cnt}
+	p.failure.line, p.failure.column = position(err)
+	p.send(ParsingResult{Error: err})
+}
+
+// resynchronized tells whether the current token gets the parser past the
+// last error reported.
+func (p *parser) resynchronized() bool {
+	current := p.tokens.current
+
+	if !current.IsComma() && !p.isClosing(current) || p.stack.cnt > p.failure.level {
+		return false
+	}
+	return current.Line > p.failure.line || current.Line == p.failure.line && current.Column >= p.failure.column
+}
+
+// position returns the position of the token an error is about.
+func position(err error) (int, int) {
+	var unexpected c.UnexpectedTokenErr
+	var duplicate c.DuplicateKeyErr
+
+	switch {
+	case errors.As(err, &unexpected):
+		return unexpected.Line, unexpected.Column
+	case errors.As(err, &duplicate):
+		return duplicate.Line, duplicate.Column
+	}
+	return 0, 0
+}
+
+// sendErrors reports the tokenizer errors put aside in recovery mode, all
+// of them about the token after the current one.
+func (p *parser) sendErrors(errors []error) {
+	for _, err := range errors {
+		p.report(p.withPath(err, p.tokens.current))
+	}
+}
+
+// withPath fills in the path of an error of the tokenizer, which knows
+// nothing of paths, found reading the token after the given one.
+func (p *parser) withPath(err error, before t.Token) error {
+	e, ok := err.(c.UnexpectedTokenErr)
+
+	if !ok || e.Path != "" {
+		return err
+	}
+
+	e.Path = p.displayPath(p.pathAfter(before))
+	return e
+}
+
+// pathAfter returns the path of the token following the given one, which
+// is the last token read and not parsed yet: the value of a key after its
+// colon, an element after a comma or an opening bracket, the container
+// otherwise.
+func (p *parser) pathAfter(before t.Token) string {
+	if p.context == nil {
+		root := p.rootLocation()
+
+		if before.IsLeftBracket() {
+			return root.pathOf(paths.IndexSegment(0))
+		}
+		return root.getContainerPath()
+	}
+
+	switch {
+	case p.isClosing(before) && !p.stack.isEmpty():
+		return p.stack.stack[p.stack.cnt-1].getContainerPath()
+	case p.isClosing(before):
+		return p.context.getContainerPath()
+	case p.context.isArray() && !before.IsLeftBracket() && !before.IsComma():
+		return p.context.getContainerPath()
+	}
+	return p.context.getPath()
+}
+
+func (p *parser) move() error {
+	moved := p.tokens.hasNext
+	err := p.tokens.move()
+
+	if err != nil {
+		err = p.withPath(err, p.tokens.next)
+	}
+	if moved && p.failure != nil && p.resynchronized() {
+		p.failure = nil
+	}
+	p.sendErrors(p.tokens.takeErrors())
+
+	if moved && err == nil && p.buffering() {
+		p.buffer = append(p.buffer, p.tokens.current)
+	}
+	return err
+}
+
+// buffering tells whether a container open keeps its tokens. The ones
+// below the root always do.
+func (p *parser) buffering() bool {
+	if !p.config.BufferContainers || p.context == nil || p.rootClosed {
+		return false
+	}
+	return p.stack.cnt > 0 || p.context.getStart() >= 0
+}
+
+// bufferEnd is the offset, in the input, of the next token kept.
+func (p *parser) bufferEnd() int {
+	return p.bufferOffset + len(p.buffer)
+}
+
+// containerTokens returns the tokens kept for the current container. The
+// capacity is cut so that appending to them does not write to the buffer.
+func (p *parser) containerTokens() []t.Token {
+	start := p.context.getStart()
+
+	if !p.config.BufferContainers || start < 0 {
+		return nil
+	}
+
+	end := len(p.buffer)
+	return p.buffer[start-p.bufferOffset : end : end]
+}
+
+// trimBuffer forgets the tokens before the outermost container open that
+// keeps them, the root or the one right in it. The tokens passed on stay
+// as they are, the buffer being cut from the front.
+func (p *parser) trimBuffer() {
+	start := p.bufferEnd()
+
+	for level := 0; level <= min(p.stack.cnt, 1); level++ {
+		if s := p.at(level).getStart(); s >= 0 {
+			start = s
+			break
+		}
+	}
+
+	p.buffer = p.buffer[start-p.bufferOffset:]
+	p.bufferOffset = start
+}
+
+func (p *parser) closes(t t.Token, c context) bool {
+	return (t.IsRightBrace() && c.isObject()) || (t.IsRightBracket() && c.isArray())
+}
+
+func (p *parser) isClosing(t t.Token) bool {
+	return t.IsRightBrace() || t.IsRightBracket()
 }
 
-func (p *parser) switchParsingContext() error {
+func (p *parser) isOpening(t t.Token) bool {
+	return t.IsLeftBrace() || t.IsLeftBracket()
+}
+
+// recover brings the parser back to a known state after a syntax error by
+// skipping tokens until the next comma or closing paren on the same nesting
+// level. A closing paren of the wrong kind is taken for a typo and closes
+// the current context unless it is the root one; the right one, found
+// before the value of a key, closes it as well.
+func (p *parser) recover(counter *parenCounter) error {
+	current := p.tokens.current
+
+	if p.closes(current, p.context) {
+		p.context.setValue() // the key left without a value
+		p.switchParsingContext()
+		return nil
+	}
+
+	if p.isClosing(current) {
+		counter.undo(current)
+
+		if !p.stack.isEmpty() {
+			counter.closeImplicitly(p.context)
+			p.switchParsingContext()
+			return nil
+		}
+	}
+
+	depth := 0
+
+	for p.tokens.hasNext {
+		next := p.tokens.next
+
+		if depth == 0 && (next.IsComma() || p.isClosing(next)) {
+			break
+		}
+
+		if p.isOpening(next) {
+			depth++
+		} else if p.isClosing(next) {
+			depth--
+		}
+
+		if err := p.move(); err != nil {
+			return err
+		}
+	}
+
+	if p.context.isObject() {
+		p.context.setValue() // forget the key of the broken pair
+	}
+
+	return nil
+}
+
+// unexpected describes a token found where one of the expected kinds
+// should have been.
+func (p *parser) unexpected(token t.Token, path string, expected ...string) error {
+	err := token.AsUnexpectedTokenErr()
+	err.Path = p.displayPath(path)
+	err.Expected = expected
+	return err
+}
+
+// recoverable tells whether parsing can go on after the error in recovery
+// mode. A duplicate key is skipped along with its value.
+func recoverable(err error) bool {
+	return errors.Is(err, c.ErrUnexpectedToken) || errors.Is(err, c.ErrDuplicateKey)
+}
+
+func (p *parser) displayPath(path string) string {
+	return p.config.PathFormat.display(path)
+}
+
+// enter pushes the current context and makes the container opened by the
+// token, at the segment of the current one, the current one.
+func (p *parser) enter(token t.Token, segment paths.Segment) error {
+	if limit := p.config.MaxDepth; limit > 0 && p.stack.cnt+2 > limit {
+		return c.DepthLimitErr{Limit: limit, Line: token.Line, Column: token.Column}
+	}
+
+	child := p.context.getLocation().child(segment)
+
+	if p.config.EmitContainers {
+		p.deliver(p.containerResult(child, token), p.stack.cnt)
+	}
+
+	p.stack.push(p.context)
+
+	if token.IsLeftBrace() {
+		p.context = p.newObjectContext(child)
+	} else {
+		p.context = newArrayContext(child)
+	}
+
+	if p.config.BufferContainers {
+		p.context.setStart(p.bufferEnd())
+	}
+	return nil
+}
+
+// switchParsingContext closes the current container, at the position of
+// the current token, and returns to the one around it.
+func (p *parser) switchParsingContext() {
+	p.release(p.stack.cnt)
+
+	if p.config.EmitContainers {
+		closing := p.tokens.current
+		if p.context.isObject() {
+			closing = t.NewRightBraceToken(closing.Line, closing.Column)
+		} else {
+			closing = t.NewRightBracketToken(closing.Line, closing.Column)
+		}
+
+		result := p.containerResult(p.context.getLocation(), closing)
+		result.Container = p.containerTokens()
+		p.deliver(result, p.stack.cnt-1)
+	}
+
 	if p.stack.isEmpty() {
-		return c.UnexpectedEndOfInputErr{}
+		p.rootClosed = true
+		return
 	}
 
 	p.context = p.stack.pop()
-	return nil
+
+	if p.config.BufferContainers {
+		p.trimBuffer()
+	}
 }
 
 func (p *parser) parseObject() error {
 	current := p.tokens.current
 
 	if current.IsRightBrace() {
+		if err := p.danglingKey(current); err != nil {
+			return err
+		}
 		p.switchParsingContext()
 		return nil
 	}
@@ -65,39 +562,61 @@ func (p *parser) parseObject() error {
 		return nil // pass
 	}
 	if current.IsComma() && p.context.isKeySet() {
-		return current.AsUnexpectedTokenErr()
+		return p.unexpected(current, p.context.getPath(), ":")
 	}
 	if current.IsColon() && !p.context.isKeySet() {
-		return current.AsUnexpectedTokenErr()
+		return p.unexpected(current, p.context.getPath(), ",", "}")
 	}
 	if current.IsLeftBrace() || current.IsComma() {
 		if next.IsString() {
 			p.context.setKey(next.Value)
-			return p.tokens.move()
+
+			if limit := p.config.MaxKeysPerObject; limit > 0 && p.context.countKeys() > limit {
+				return c.KeyCountLimitErr{
+					Limit:  limit,
+					Path:   p.displayPath(p.context.getContainerPath()),
+					Line:   next.Line,
+					Column: next.Column,
+				}
+			}
+			if p.config.DuplicateKeys != AllowDuplicateKeys {
+				if err := p.addKey(next); err != nil {
+					return err
+				}
+			}
+			return p.move()
+		} else if current.IsLeftBrace() {
+			return p.unexpected(next, p.context.getPath(), "string", "}")
 		} else {
-			return next.AsUnexpectedTokenErr()
+			return p.unexpected(next, p.context.getPath(), "string")
 		}
 	}
 	if current.IsColon() {
-		path := p.context.getPath()
+		segment := p.context.segment()
 		p.context.setValue()
 
 		if p.isValue(next) {
-			p.resultStream <- ParsingResult{Path: path, Token: next}
-			return p.tokens.move()
-		} else if next.IsLeftBrace() {
-			p.stack.push(p.context)
-			p.context = newObjectContext(path)
-		} else if next.IsLeftBracket() {
-			p.stack.push(p.context)
-			p.context = newArrayContext(path)
+			p.emit(segment, next)
+			return p.move()
+		} else if next.IsLeftBrace() || next.IsLeftBracket() {
+			return p.enter(next, segment)
 		} else {
-			return next.AsUnexpectedTokenErr()
+			return p.unexpected(next, p.context.getLocation().pathOf(segment), "value")
 		}
+	}
+	if p.context.isKeySet() {
+		return p.unexpected(current, p.context.getPath(), ":")
+	}
+	return p.unexpected(current, p.context.getPath(), ",", "}")
+}
 
-		return nil
+// danglingKey reports the key of the current object left without a value
+// by the closing brace, as in {"a"}.
+func (p *parser) danglingKey(closing t.Token) error {
+	if p.context.isObject() && p.context.isKeySet() {
+		return p.unexpected(closing, p.context.getPath(), ":")
 	}
-	return next.AsUnexpectedTokenErr()
+	return nil
 }
 
 func (p *parser) parseArray() error {
@@ -114,24 +633,24 @@ func (p *parser) parseArray() error {
 		return nil // pass
 	}
 	if current.IsLeftBracket() || current.IsComma() {
-		path := p.context.getPath()
+		segment := p.context.segment()
 		p.context.setValue()
 
 		if p.isValue(next) {
-			p.resultStream <- ParsingResult{Path: path, Token: next}
-			return p.tokens.move()
-		} else if next.IsLeftBracket() {
-			p.stack.push(p.context)
-			p.context = newArrayContext(path)
-		} else if next.IsLeftBrace() {
-			p.stack.push(p.context)
-			p.context = newObjectContext(path)
-		} else {
-			return next.AsUnexpectedTokenErr()
+			p.emit(segment, next)
+			return p.move()
+		} else if next.IsLeftBrace() || next.IsLeftBracket() {
+			return p.enter(next, segment)
 		}
-		return nil
+
+		path := p.context.getLocation().pathOf(segment)
+
+		if current.IsLeftBracket() {
+			return p.unexpected(next, path, "value", "]")
+		}
+		return p.unexpected(next, path, "value")
 	}
-	return current.AsUnexpectedTokenErr()
+	return p.unexpected(current, p.context.getContainerPath(), ",", "]")
 }
 
 func (p *parser) parseContext() error {
@@ -139,6 +658,14 @@ func (p *parser) parseContext() error {
 	var err error
 
 	for p.tokens.hasNext {
+		if p.stopped() {
+			return nil
+		}
+
+		if p.rootClosed {
+			return p.trailing(p.tokens.current)
+		}
+
 		parenCounter.update(p.tokens.current)
 
 		if p.context.isObject() {
@@ -148,10 +675,18 @@ func (p *parser) parseContext() error {
 		}
 
 		if err != nil {
-			return err
+			if !p.config.Recover || !recoverable(err) {
+				return err
+			}
+
+			p.report(err)
+
+			if err = p.recover(&parenCounter); err != nil {
+				return err
+			}
 		}
 
-		err = p.tokens.move()
+		err = p.move()
 
 		if err != nil {
 			return err
@@ -159,44 +694,115 @@ func (p *parser) parseContext() error {
 	}
 
 	last := p.tokens.current
+
+	if p.rootClosed {
+		return p.trailing(last)
+	}
 	parenCounter.update(last)
 
+	if p.closes(last, p.context) {
+		if err := p.danglingKey(last); err != nil {
+			if !p.config.Recover {
+				return err
+			}
+			p.report(err)
+		}
+		p.switchParsingContext()
+	}
+	p.releaseAll()
+
 	if !parenCounter.isBalanced() {
-		return c.UnexpectedEndOfInputErr{}
+		err := c.UnexpectedEndOfInputErr{Line: last.Line, Column: last.Column}
+
+		if parenCounter.isOpen() {
+			err.Path = p.displayPath(p.context.getContainerPath())
+		}
+		return err
 	}
 
 	return nil
 }
 
+// trailing reports a token found after the root is closed. Nothing is left
+// to resynchronize at, so parsing ends there even in recovery mode.
+func (p *parser) trailing(token t.Token) error {
+	err := p.unexpected(token, p.context.getContainerPath(), "end of input")
+
+	if !p.config.Recover {
+		return err
+	}
+	p.report(err)
+	return nil
+}
+
+// rootLocation is the location of the root, where the paths of all the
+// containers are written.
+func (p *parser) rootLocation() location {
+	buffer := newPathBuffer(p.config.PathFormat)
+	root := location{paths: buffer, length: len(buffer.path)}
+
+	if p.config.LazyPaths {
+		root.lazy = &Path{format: p.config.PathFormat}
+	}
+	return root
+}
+
 func (p *parser) Parse() {
 	defer close(p.resultStream)
 
 	var err error
 
+	p.sendErrors(p.tokens.takeErrors())
+
+	if p.tokens.empty {
+		p.send(ParsingResult{Error: c.UnexpectedEndOfInputErr{}})
+		return
+	}
+
 	first := p.tokens.current
+	top := p.rootLocation()
+	root := top.getContainerPath()
 
 	if p.isValue(first) && !p.tokens.hasNext {
-		p.resultStream <- ParsingResult{Path: ".", Token: first}
+		if !first.IsInvalid() {
+			p.send(p.containerResult(top, first))
+		}
+		return
+	}
+
+	if p.isValue(first) {
+		p.send(ParsingResult{Error: p.unexpected(p.tokens.next, root, "end of input")})
 		return
 	}
 
 	if !(first.IsLeftBrace() || first.IsLeftBracket()) {
-		p.resultStream <- ParsingResult{Error: c.UnexpectedEndOfInputErr{}}
+		p.send(ParsingResult{Error: p.unexpected(first, root, "value")})
 		return
 	}
 
+	if p.config.EmitContainers {
+		p.send(p.containerResult(top, first))
+	}
+
 	if first.IsLeftBrace() {
-		p.context = newObjectContext("")
+		p.context = p.newObjectContext(top)
 	}
 
 	if first.IsLeftBracket() {
-		p.context = newArrayContext(".")
+		p.context = newArrayContext(top)
+	}
+
+	switch {
+	case p.config.BufferContainers && p.config.UnbufferedRoot:
+		p.context.setStart(-1)
+	case p.config.BufferContainers:
+		p.buffer = append(p.buffer, first)
 	}
 
 	err = p.parseContext()
 
 	if err != nil {
-		p.resultStream <- ParsingResult{Error: err}
+		p.send(ParsingResult{Error: err})
 		return
 	}
 
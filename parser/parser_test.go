@@ -2,8 +2,11 @@ package parser
 
 import (
 	"reflect"
+	"strings"
 	"testing"
 
+	c "github.com/rodic/jmatch/common"
+	"github.com/rodic/jmatch/paths"
 	z "github.com/rodic/jmatch/tokenizer"
 )
 
@@ -356,7 +359,7 @@ func TestSuccessParse(t *testing.T) {
 				close(tokenStream)
 			}()
 
-			p, err := NewParser(tokenStream)
+			p, err := NewParser(tokenStream, Config{})
 
 			if err != nil {
 				t.Error(err)
@@ -384,76 +387,80 @@ func TestFailParse(t *testing.T) {
 		tokens   []z.Token
 		expected string
 	}{
+		{name: "empty",
+			tokens:   []z.Token{},
+			expected: "invalid JSON. Unexpected end of JSON input"},
+
 		// objects
 		{name: "{",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
 			},
-			expected: "invalid JSON. Unexpected end of JSON input"},
+			expected: "invalid JSON. Unexpected end of JSON input after line 1 column 1, container at . is not closed"},
 		{name: "{{",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
 				z.NewLeftBraceToken(1, 2),
 			},
-			expected: "invalid JSON. unexpected token { at line 1 column 2"},
+			expected: "invalid JSON. unexpected token { at line 1 column 2 in ., expected string or '}'"},
 		{name: "{{{",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
 				z.NewLeftBraceToken(1, 2),
 				z.NewLeftBraceToken(1, 3),
 			},
-			expected: "invalid JSON. unexpected token { at line 1 column 2"},
+			expected: "invalid JSON. unexpected token { at line 1 column 2 in ., expected string or '}'"},
 		{name: "{{}",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
 				z.NewLeftBraceToken(1, 2),
 				z.NewRightBraceToken(1, 3),
 			},
-			expected: "invalid JSON. unexpected token { at line 1 column 2"},
+			expected: "invalid JSON. unexpected token { at line 1 column 2 in ., expected string or '}'"},
 		{name: "{}}",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
 				z.NewRightBraceToken(1, 2),
 				z.NewRightBraceToken(1, 3),
 			},
-			expected: "invalid JSON. Unexpected end of JSON input"},
+			expected: "invalid JSON. unexpected token } at line 1 column 3 in ., expected end of input"},
 		{name: "{1",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
 				z.NewNumberToken("1", 1, 2),
 			},
-			expected: "invalid JSON. unexpected token 1 at line 1 column 2"},
+			expected: "invalid JSON. unexpected token 1 at line 1 column 2 in ., expected string or '}'"},
 		{name: "{true",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
 				z.NewBooleanToken("true", 1, 2),
 			},
-			expected: "invalid JSON. unexpected token true at line 1 column 2"},
+			expected: "invalid JSON. unexpected token true at line 1 column 2 in ., expected string or '}'"},
 		{name: "{null",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
 				z.NewNullToken(1, 2),
 			},
-			expected: "invalid JSON. unexpected token null at line 1 column 2"},
+			expected: "invalid JSON. unexpected token null at line 1 column 2 in ., expected string or '}'"},
 		{name: "{:",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
 				z.NewColonToken(1, 2),
 			},
-			expected: "invalid JSON. unexpected token : at line 1 column 2"},
+			expected: "invalid JSON. unexpected token : at line 1 column 2 in ., expected string or '}'"},
 		{name: "{,",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
 				z.NewCommaToken(1, 2),
 			},
-			expected: "invalid JSON. unexpected token , at line 1 column 2"},
+			expected: "invalid JSON. unexpected token , at line 1 column 2 in ., expected string or '}'"},
 		{name: "{'a',",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
 				z.NewStringToken("a", 1, 2),
 				z.NewCommaToken(1, 3),
 			},
-			expected: "invalid JSON. Unexpected end of JSON input"},
+			expected: "invalid JSON. Unexpected end of JSON input after line 1 column 3, container at . is not closed"},
 		{name: "{'a',1}",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
@@ -462,7 +469,7 @@ func TestFailParse(t *testing.T) {
 				z.NewNumberToken("1", 1, 4),
 				z.NewRightBraceToken(1, 5),
 			},
-			expected: "invalid JSON. unexpected token , at line 1 column 3"},
+			expected: "invalid JSON. unexpected token , at line 1 column 3 in .a, expected ':'"},
 		{name: "{'a','1'}",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
@@ -471,7 +478,36 @@ func TestFailParse(t *testing.T) {
 				z.NewStringToken("1", 1, 4),
 				z.NewRightBraceToken(1, 5),
 			},
-			expected: "invalid JSON. unexpected token , at line 1 column 3"},
+			expected: "invalid JSON. unexpected token , at line 1 column 3 in .a, expected ':'"},
+		{name: "{'a'}",
+			tokens: []z.Token{
+				z.NewLeftBraceToken(1, 1),
+				z.NewStringToken("a", 1, 2),
+				z.NewRightBraceToken(1, 5),
+			},
+			expected: "invalid JSON. unexpected token } at line 1 column 5 in .a, expected ':'"},
+		{name: "{'a': 1, 'b'}",
+			tokens: []z.Token{
+				z.NewLeftBraceToken(1, 1),
+				z.NewStringToken("a", 1, 2),
+				z.NewColonToken(1, 5),
+				z.NewNumberToken("1", 1, 6),
+				z.NewCommaToken(1, 7),
+				z.NewStringToken("b", 1, 8),
+				z.NewRightBraceToken(1, 11),
+			},
+			expected: "invalid JSON. unexpected token } at line 1 column 11 in .b, expected ':'"},
+		{name: "[{'a'}, 1]",
+			tokens: []z.Token{
+				z.NewLeftBracketToken(1, 1),
+				z.NewLeftBraceToken(1, 2),
+				z.NewStringToken("a", 1, 3),
+				z.NewRightBraceToken(1, 6),
+				z.NewCommaToken(1, 7),
+				z.NewNumberToken("1", 1, 8),
+				z.NewRightBracketToken(1, 9),
+			},
+			expected: "invalid JSON. unexpected token } at line 1 column 6 in .[0].a, expected ':'"},
 		{name: "{'a': 1,}",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
@@ -481,7 +517,7 @@ func TestFailParse(t *testing.T) {
 				z.NewCommaToken(1, 5),
 				z.NewRightBraceToken(1, 6),
 			},
-			expected: "invalid JSON. unexpected token } at line 1 column 6"},
+			expected: "invalid JSON. unexpected token } at line 1 column 6 in ., expected string"},
 		{name: "{'a': 1, 2}",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
@@ -492,7 +528,7 @@ func TestFailParse(t *testing.T) {
 				z.NewNumberToken("2", 1, 6),
 				z.NewRightBraceToken(1, 7),
 			},
-			expected: "invalid JSON. unexpected token 2 at line 1 column 6"},
+			expected: "invalid JSON. unexpected token 2 at line 1 column 6 in ., expected string"},
 		{name: "{'a': 'b': 1}",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
@@ -503,7 +539,7 @@ func TestFailParse(t *testing.T) {
 				z.NewNumberToken("1", 1, 6),
 				z.NewRightBraceToken(1, 7),
 			},
-			expected: "invalid JSON. unexpected token : at line 1 column 5"},
+			expected: "invalid JSON. unexpected token : at line 1 column 5 in ., expected ',' or '}'"},
 		{name: "{'a': {,}}",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
@@ -514,7 +550,7 @@ func TestFailParse(t *testing.T) {
 				z.NewRightBraceToken(1, 6),
 				z.NewRightBraceToken(1, 7),
 			},
-			expected: "invalid JSON. unexpected token , at line 1 column 5"},
+			expected: "invalid JSON. unexpected token , at line 1 column 5 in .a, expected string or '}'"},
 		{name: "{'a': {{}}}",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
@@ -526,47 +562,47 @@ func TestFailParse(t *testing.T) {
 				z.NewRightBraceToken(1, 7),
 				z.NewRightBraceToken(1, 8),
 			},
-			expected: "invalid JSON. unexpected token { at line 1 column 5"},
+			expected: "invalid JSON. unexpected token { at line 1 column 5 in .a, expected string or '}'"},
 
 		// arrays
 		{name: "[",
 			tokens: []z.Token{
 				z.NewLeftBracketToken(1, 1),
 			},
-			expected: "invalid JSON. Unexpected end of JSON input"},
+			expected: "invalid JSON. Unexpected end of JSON input after line 1 column 1, container at . is not closed"},
 		{name: "[[",
 			tokens: []z.Token{
 				z.NewLeftBracketToken(1, 1),
 				z.NewLeftBracketToken(1, 2),
 			},
-			expected: "invalid JSON. Unexpected end of JSON input"},
+			expected: "invalid JSON. Unexpected end of JSON input after line 1 column 2, container at .[0] is not closed"},
 		{name: "[[[",
 			tokens: []z.Token{
 				z.NewLeftBracketToken(1, 1),
 				z.NewLeftBracketToken(1, 2),
 				z.NewLeftBracketToken(1, 3),
 			},
-			expected: "invalid JSON. Unexpected end of JSON input"},
+			expected: "invalid JSON. Unexpected end of JSON input after line 1 column 3, container at .[0][0] is not closed"},
 		{name: "[[]",
 			tokens: []z.Token{
 				z.NewLeftBracketToken(1, 1),
 				z.NewLeftBracketToken(1, 2),
 				z.NewRightBracketToken(1, 3),
 			},
-			expected: "invalid JSON. Unexpected end of JSON input"},
+			expected: "invalid JSON. Unexpected end of JSON input after line 1 column 3, container at . is not closed"},
 		{name: "[]]",
 			tokens: []z.Token{
 				z.NewLeftBracketToken(1, 1),
 				z.NewRightBracketToken(1, 2),
 				z.NewRightBracketToken(1, 3),
 			},
-			expected: "invalid JSON. Unexpected end of JSON input"},
+			expected: "invalid JSON. unexpected token ] at line 1 column 3 in ., expected end of input"},
 		{name: "[,",
 			tokens: []z.Token{
 				z.NewLeftBracketToken(1, 1),
 				z.NewCommaToken(1, 2),
 			},
-			expected: "invalid JSON. unexpected token , at line 1 column 2"},
+			expected: "invalid JSON. unexpected token , at line 1 column 2 in .[0], expected value or ']'"},
 		{name: "[1,]",
 			tokens: []z.Token{
 				z.NewLeftBracketToken(1, 1),
@@ -574,7 +610,7 @@ func TestFailParse(t *testing.T) {
 				z.NewCommaToken(1, 3),
 				z.NewRightBracketToken(1, 4),
 			},
-			expected: "invalid JSON. unexpected token ] at line 1 column 4"},
+			expected: "invalid JSON. unexpected token ] at line 1 column 4 in .[1], expected value"},
 
 		// mixed
 		{name: "{]",
@@ -582,20 +618,20 @@ func TestFailParse(t *testing.T) {
 				z.NewLeftBraceToken(1, 1),
 				z.NewRightBracketToken(1, 2),
 			},
-			expected: "invalid JSON. unexpected token ] at line 1 column 2"},
+			expected: "invalid JSON. unexpected token ] at line 1 column 2 in ., expected string or '}'"},
 		{name: "[}",
 			tokens: []z.Token{
 				z.NewLeftBracketToken(1, 1),
 				z.NewRightBraceToken(1, 2),
 			},
-			expected: "invalid JSON. unexpected token } at line 1 column 2"},
+			expected: "invalid JSON. unexpected token } at line 1 column 2 in .[0], expected value or ']'"},
 		{name: "{[}",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
 				z.NewLeftBracketToken(1, 2),
 				z.NewRightBraceToken(1, 3),
 			},
-			expected: "invalid JSON. unexpected token [ at line 1 column 2"},
+			expected: "invalid JSON. unexpected token [ at line 1 column 2 in ., expected string or '}'"},
 		{name: "{'a': [}",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
@@ -604,7 +640,7 @@ func TestFailParse(t *testing.T) {
 				z.NewLeftBracketToken(1, 4),
 				z.NewRightBraceToken(1, 5),
 			},
-			expected: "invalid JSON. unexpected token } at line 1 column 5"},
+			expected: "invalid JSON. unexpected token } at line 1 column 5 in .a[0], expected value or ']'"},
 		{name: "{'a': [,",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
@@ -613,7 +649,7 @@ func TestFailParse(t *testing.T) {
 				z.NewLeftBracketToken(1, 4),
 				z.NewCommaToken(1, 5),
 			},
-			expected: "invalid JSON. unexpected token , at line 1 column 5"},
+			expected: "invalid JSON. unexpected token , at line 1 column 5 in .a[0], expected value or ']'"},
 		{name: "{'a': [,",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
@@ -622,7 +658,7 @@ func TestFailParse(t *testing.T) {
 				z.NewLeftBracketToken(1, 4),
 				z.NewCommaToken(1, 5),
 			},
-			expected: "invalid JSON. unexpected token , at line 1 column 5"},
+			expected: "invalid JSON. unexpected token , at line 1 column 5 in .a[0], expected value or ']'"},
 		{name: "{'a': [{},",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
@@ -632,7 +668,7 @@ func TestFailParse(t *testing.T) {
 				z.NewLeftBraceToken(1, 5),
 				z.NewRightBraceToken(1, 6),
 			},
-			expected: "invalid JSON. Unexpected end of JSON input"},
+			expected: "invalid JSON. Unexpected end of JSON input after line 1 column 6, container at .a is not closed"},
 		{name: "{'s': {'t': [[1], -2.0, '3', true, {'x': false,}]}}",
 			tokens: []z.Token{
 				z.NewLeftBraceToken(1, 1),
@@ -662,7 +698,7 @@ func TestFailParse(t *testing.T) {
 				z.NewRightBraceToken(1, 25),
 				z.NewRightBraceToken(1, 26),
 			},
-			expected: "invalid JSON. unexpected token } at line 1 column 23",
+			expected: "invalid JSON. unexpected token } at line 1 column 23 in .s.t[4], expected string",
 		},
 	}
 
@@ -678,7 +714,7 @@ func TestFailParse(t *testing.T) {
 				close(tokenStream)
 			}()
 
-			p, err := NewParser(tokenStream)
+			p, err := NewParser(tokenStream, Config{})
 
 			if err != nil {
 				t.Error(err)
@@ -698,3 +734,702 @@ func TestFailParse(t *testing.T) {
 		})
 	}
 }
+
+func TestRecoverParse(t *testing.T) {
+	testCases := []struct {
+		name     string
+		tokens   []z.Token
+		expected []ParsingResult
+	}{
+		{name: "{'a': 1, 'b': , 'c': 3}",
+			tokens: []z.Token{
+				z.NewLeftBraceToken(1, 1),
+				z.NewStringToken("a", 1, 2),
+				z.NewColonToken(1, 3),
+				z.NewNumberToken("1", 1, 4),
+				z.NewCommaToken(1, 5),
+				z.NewStringToken("b", 1, 6),
+				z.NewColonToken(1, 7),
+				z.NewCommaToken(1, 8),
+				z.NewStringToken("c", 1, 9),
+				z.NewColonToken(1, 10),
+				z.NewNumberToken("3", 1, 11),
+				z.NewRightBraceToken(1, 12),
+			},
+			expected: []ParsingResult{
+				{Path: ".a", Token: z.NewNumberToken("1", 1, 4)},
+				{Error: unexpected(z.NewCommaToken(1, 8), ".b", "value")},
+				{Path: ".c", Token: z.NewNumberToken("3", 1, 11)},
+			},
+		},
+		{name: "{'a', 'b': 1, 'c': 2}",
+			tokens: []z.Token{
+				z.NewLeftBraceToken(1, 1),
+				z.NewStringToken("a", 1, 2),
+				z.NewCommaToken(1, 3),
+				z.NewStringToken("b", 1, 4),
+				z.NewColonToken(1, 5),
+				z.NewNumberToken("1", 1, 6),
+				z.NewCommaToken(1, 7),
+				z.NewStringToken("c", 1, 8),
+				z.NewColonToken(1, 9),
+				z.NewNumberToken("2", 1, 10),
+				z.NewRightBraceToken(1, 11),
+			},
+			expected: []ParsingResult{
+				{Error: unexpected(z.NewCommaToken(1, 3), ".a", ":")},
+				{Path: ".c", Token: z.NewNumberToken("2", 1, 10)},
+			},
+		},
+		{name: "{'a': [1, 2}, 'b': 3}",
+			tokens: []z.Token{
+				z.NewLeftBraceToken(1, 1),
+				z.NewStringToken("a", 1, 2),
+				z.NewColonToken(1, 3),
+				z.NewLeftBracketToken(1, 4),
+				z.NewNumberToken("1", 1, 5),
+				z.NewCommaToken(1, 6),
+				z.NewNumberToken("2", 1, 7),
+				z.NewRightBraceToken(1, 8),
+				z.NewCommaToken(1, 9),
+				z.NewStringToken("b", 1, 10),
+				z.NewColonToken(1, 11),
+				z.NewNumberToken("3", 1, 12),
+				z.NewRightBraceToken(1, 13),
+			},
+			expected: []ParsingResult{
+				{Path: ".a[0]", Token: z.NewNumberToken("1", 1, 5)},
+				{Path: ".a[1]", Token: z.NewNumberToken("2", 1, 7)},
+				{Error: unexpected(z.NewRightBraceToken(1, 8), ".a", ",", "]")},
+				{Path: ".b", Token: z.NewNumberToken("3", 1, 12)},
+			},
+		},
+		{name: "[1, :, {'x': }, 2]",
+			tokens: []z.Token{
+				z.NewLeftBracketToken(1, 1),
+				z.NewNumberToken("1", 1, 2),
+				z.NewCommaToken(1, 3),
+				z.NewColonToken(1, 4),
+				z.NewCommaToken(1, 5),
+				z.NewLeftBraceToken(1, 6),
+				z.NewStringToken("x", 1, 7),
+				z.NewColonToken(1, 8),
+				z.NewRightBraceToken(1, 9),
+				z.NewCommaToken(1, 10),
+				z.NewNumberToken("2", 1, 11),
+				z.NewRightBracketToken(1, 12),
+			},
+			expected: []ParsingResult{
+				{Path: ".[0]", Token: z.NewNumberToken("1", 1, 2)},
+				{Error: unexpected(z.NewColonToken(1, 4), ".[1]", "value")},
+				{Error: unexpected(z.NewRightBraceToken(1, 9), ".[2].x", "value")},
+				{Path: ".[3]", Token: z.NewNumberToken("2", 1, 11)},
+			},
+		},
+		{name: "[{'a'}, 1, {'b': 2, 'c'}]",
+			tokens: []z.Token{
+				z.NewLeftBracketToken(1, 1),
+				z.NewLeftBraceToken(1, 2),
+				z.NewStringToken("a", 1, 3),
+				z.NewRightBraceToken(1, 6),
+				z.NewCommaToken(1, 7),
+				z.NewNumberToken("1", 1, 8),
+				z.NewCommaToken(1, 9),
+				z.NewLeftBraceToken(1, 10),
+				z.NewStringToken("b", 1, 11),
+				z.NewColonToken(1, 14),
+				z.NewNumberToken("2", 1, 15),
+				z.NewCommaToken(1, 16),
+				z.NewStringToken("c", 1, 17),
+				z.NewRightBraceToken(1, 20),
+				z.NewRightBracketToken(1, 21),
+			},
+			expected: []ParsingResult{
+				{Error: unexpected(z.NewRightBraceToken(1, 6), ".[0].a", ":")},
+				{Path: ".[1]", Token: z.NewNumberToken("1", 1, 8)},
+				{Path: ".[2].b", Token: z.NewNumberToken("2", 1, 15)},
+				{Error: unexpected(z.NewRightBraceToken(1, 20), ".[2].c", ":")},
+			},
+		},
+		{name: "['1', invalid, '2']",
+			tokens: []z.Token{
+				z.NewLeftBracketToken(1, 1),
+				z.NewStringToken("1", 1, 2),
+				z.NewCommaToken(1, 3),
+				z.NewInvalidToken("truef", 1, 4),
+				z.NewCommaToken(1, 5),
+				z.NewStringToken("2", 1, 6),
+				z.NewRightBracketToken(1, 7),
+			},
+			expected: []ParsingResult{
+				{Path: ".[0]", Token: z.NewStringToken("1", 1, 2)},
+				{Path: ".[2]", Token: z.NewStringToken("2", 1, 6)},
+			},
+		},
+		{name: "{'a': [1",
+			tokens: []z.Token{
+				z.NewLeftBraceToken(1, 1),
+				z.NewStringToken("a", 1, 2),
+				z.NewColonToken(1, 3),
+				z.NewLeftBracketToken(1, 4),
+				z.NewNumberToken("1", 1, 5),
+			},
+			expected: []ParsingResult{
+				{Path: ".a[0]", Token: z.NewNumberToken("1", 1, 5)},
+				{Error: c.UnexpectedEndOfInputErr{Line: 1, Column: 5, Path: ".a"}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			tokenStream := make(chan z.TokenResult)
+
+			go func() {
+				for _, t := range tc.tokens {
+					tokenStream <- z.TokenResult{Token: t}
+				}
+				close(tokenStream)
+			}()
+
+			p, err := NewParser(tokenStream, Config{Recover: true})
+
+			if err != nil {
+				t.Error(err)
+			}
+
+			go p.Parse()
+
+			result := make([]ParsingResult, 0, 10)
+
+			for pr := range p.GetResultReadStream() {
+				result = append(result, pr)
+			}
+
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, result)
+			}
+		})
+	}
+}
+
+func unexpected(token z.Token, path string, expected ...string) c.UnexpectedTokenErr {
+	err := token.AsUnexpectedTokenErr()
+	err.Path = path
+	err.Expected = expected
+	return err
+}
+
+func TestParseLimits(t *testing.T) {
+	testCases := []struct {
+		name     string
+		tokens   []z.Token
+		config   Config
+		expected []ParsingResult
+	}{
+		{name: "[[1], [[2]]]",
+			tokens: []z.Token{
+				z.NewLeftBracketToken(1, 1),
+				z.NewLeftBracketToken(1, 2),
+				z.NewNumberToken("1", 1, 3),
+				z.NewRightBracketToken(1, 4),
+				z.NewCommaToken(1, 5),
+				z.NewLeftBracketToken(1, 7),
+				z.NewLeftBracketToken(1, 8),
+				z.NewNumberToken("2", 1, 9),
+				z.NewRightBracketToken(1, 10),
+				z.NewRightBracketToken(1, 11),
+				z.NewRightBracketToken(1, 12),
+			},
+			config: Config{MaxDepth: 2},
+			expected: []ParsingResult{
+				{Path: ".[0][0]", Token: z.NewNumberToken("1", 1, 3)},
+				{Error: c.DepthLimitErr{Limit: 2, Line: 1, Column: 8}},
+			},
+		},
+		{name: "{'a': {'b': 1, 'c': 2}}",
+			tokens: []z.Token{
+				z.NewLeftBraceToken(1, 1),
+				z.NewStringToken("a", 1, 2),
+				z.NewColonToken(1, 3),
+				z.NewLeftBraceToken(1, 4),
+				z.NewStringToken("b", 1, 5),
+				z.NewColonToken(1, 6),
+				z.NewNumberToken("1", 1, 7),
+				z.NewCommaToken(1, 8),
+				z.NewStringToken("c", 1, 9),
+				z.NewColonToken(1, 10),
+				z.NewNumberToken("2", 1, 11),
+				z.NewRightBraceToken(1, 12),
+				z.NewRightBraceToken(1, 13),
+			},
+			config: Config{MaxKeysPerObject: 1},
+			expected: []ParsingResult{
+				{Path: ".a.b", Token: z.NewNumberToken("1", 1, 7)},
+				{Error: c.KeyCountLimitErr{Limit: 1, Path: ".a", Line: 1, Column: 9}},
+			},
+		},
+		{name: "[1, {'a': 1}] recovering",
+			tokens: []z.Token{
+				z.NewLeftBracketToken(1, 1),
+				z.NewNumberToken("1", 1, 2),
+				z.NewCommaToken(1, 3),
+				z.NewLeftBraceToken(1, 4),
+				z.NewStringToken("a", 1, 5),
+				z.NewColonToken(1, 6),
+				z.NewNumberToken("1", 1, 7),
+				z.NewRightBraceToken(1, 8),
+				z.NewRightBracketToken(1, 9),
+			},
+			config: Config{Recover: true, MaxDepth: 1},
+			expected: []ParsingResult{
+				{Path: ".[0]", Token: z.NewNumberToken("1", 1, 2)},
+				{Error: c.DepthLimitErr{Limit: 1, Line: 1, Column: 4}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			tokenStream := make(chan z.TokenResult)
+
+			go func() {
+				for _, t := range tc.tokens {
+					tokenStream <- z.TokenResult{Token: t}
+				}
+				close(tokenStream)
+			}()
+
+			p, err := NewParser(tokenStream, tc.config)
+
+			if err != nil {
+				t.Error(err)
+			}
+
+			go p.Parse()
+
+			result := make([]ParsingResult, 0, 10)
+
+			for pr := range p.GetResultReadStream() {
+				result = append(result, pr)
+			}
+
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseDuplicateKeys(t *testing.T) {
+	// {'a': 1, 'b': {'c': 2}, 'a': 3, 'b': {'c': 4}}
+	tokens := []z.Token{
+		z.NewLeftBraceToken(1, 1),
+		z.NewStringToken("a", 1, 2),
+		z.NewColonToken(1, 3),
+		z.NewNumberToken("1", 1, 4),
+		z.NewCommaToken(1, 5),
+		z.NewStringToken("b", 1, 6),
+		z.NewColonToken(1, 7),
+		z.NewLeftBraceToken(1, 8),
+		z.NewStringToken("c", 1, 9),
+		z.NewColonToken(1, 10),
+		z.NewNumberToken("2", 1, 11),
+		z.NewRightBraceToken(1, 12),
+		z.NewCommaToken(1, 13),
+		z.NewStringToken("a", 1, 14),
+		z.NewColonToken(1, 15),
+		z.NewNumberToken("3", 1, 16),
+		z.NewCommaToken(1, 17),
+		z.NewStringToken("b", 1, 18),
+		z.NewColonToken(1, 19),
+		z.NewLeftBraceToken(1, 20),
+		z.NewStringToken("c", 1, 21),
+		z.NewColonToken(1, 22),
+		z.NewNumberToken("4", 1, 23),
+		z.NewRightBraceToken(1, 24),
+		z.NewRightBraceToken(1, 25),
+	}
+
+	duplicateA := c.DuplicateKeyErr{Key: "a", Path: ".", Line: 1, Column: 14, FirstLine: 1, FirstColumn: 2}
+	duplicateB := c.DuplicateKeyErr{Key: "b", Path: ".", Line: 1, Column: 18, FirstLine: 1, FirstColumn: 6}
+
+	testCases := []struct {
+		name     string
+		config   Config
+		expected []ParsingResult
+	}{
+		{name: "allow",
+			config: Config{},
+			expected: []ParsingResult{
+				{Path: ".a", Token: z.NewNumberToken("1", 1, 4)},
+				{Path: ".b.c", Token: z.NewNumberToken("2", 1, 11)},
+				{Path: ".a", Token: z.NewNumberToken("3", 1, 16)},
+				{Path: ".b.c", Token: z.NewNumberToken("4", 1, 23)},
+			},
+		},
+		{name: "reject",
+			config: Config{DuplicateKeys: RejectDuplicateKeys},
+			expected: []ParsingResult{
+				{Path: ".a", Token: z.NewNumberToken("1", 1, 4)},
+				{Path: ".b.c", Token: z.NewNumberToken("2", 1, 11)},
+				{Error: duplicateA},
+			},
+		},
+		{name: "rejectRecovering",
+			config: Config{Recover: true, DuplicateKeys: RejectDuplicateKeys},
+			expected: []ParsingResult{
+				{Path: ".a", Token: z.NewNumberToken("1", 1, 4)},
+				{Path: ".b.c", Token: z.NewNumberToken("2", 1, 11)},
+				{Error: duplicateA},
+				{Error: duplicateB},
+			},
+		},
+		{name: "firstWins",
+			config: Config{DuplicateKeys: FirstKeyWins},
+			expected: []ParsingResult{
+				{Path: ".a", Token: z.NewNumberToken("1", 1, 4)},
+				{Path: ".b.c", Token: z.NewNumberToken("2", 1, 11)},
+			},
+		},
+		{name: "lastWins",
+			config: Config{DuplicateKeys: LastKeyWins},
+			expected: []ParsingResult{
+				{Path: ".a", Token: z.NewNumberToken("3", 1, 16)},
+				{Path: ".b.c", Token: z.NewNumberToken("4", 1, 23)},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			tokenStream := make(chan z.TokenResult)
+
+			go func() {
+				for _, t := range tokens {
+					tokenStream <- z.TokenResult{Token: t}
+				}
+				close(tokenStream)
+			}()
+
+			p, err := NewParser(tokenStream, tc.config)
+
+			if err != nil {
+				t.Error(err)
+			}
+
+			go p.Parse()
+
+			result := make([]ParsingResult, 0, 10)
+
+			for pr := range p.GetResultReadStream() {
+				result = append(result, pr)
+			}
+
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseOptions(t *testing.T) {
+	// {'a b': [1, {'c/d': 2}], 'e': {}}
+	tokens := []z.Token{
+		z.NewLeftBraceToken(1, 1),
+		z.NewStringToken("a b", 1, 2),
+		z.NewColonToken(1, 3),
+		z.NewLeftBracketToken(1, 4),
+		z.NewNumberToken("1", 1, 5),
+		z.NewCommaToken(1, 6),
+		z.NewLeftBraceToken(1, 7),
+		z.NewStringToken("c/d", 1, 8),
+		z.NewColonToken(1, 9),
+		z.NewNumberToken("2", 1, 10),
+		z.NewRightBraceToken(1, 11),
+		z.NewRightBracketToken(1, 12),
+		z.NewCommaToken(1, 13),
+		z.NewStringToken("e", 1, 14),
+		z.NewColonToken(1, 15),
+		z.NewLeftBraceToken(1, 16),
+		z.NewRightBraceToken(1, 17),
+		z.NewRightBraceToken(1, 18),
+	}
+
+	testCases := []struct {
+		name     string
+		config   Config
+		expected []ParsingResult
+	}{
+		{name: "jsonPointer",
+			config: Config{PathFormat: JSONPointer},
+			expected: []ParsingResult{
+				{Path: "/a b/0", Token: z.NewNumberToken("1", 1, 5)},
+				{Path: "/a b/1/c~1d", Token: z.NewNumberToken("2", 1, 10)},
+			},
+		},
+		{name: "jsonPath",
+			config: Config{PathFormat: JSONPath},
+			expected: []ParsingResult{
+				{Path: "$['a b'][0]", Token: z.NewNumberToken("1", 1, 5)},
+				{Path: "$['a b'][1]['c/d']", Token: z.NewNumberToken("2", 1, 10)},
+			},
+		},
+		{name: "emitContainers",
+			config: Config{EmitContainers: true},
+			expected: []ParsingResult{
+				{Path: ".", Token: z.NewLeftBraceToken(1, 1)},
+				{Path: ".\"a b\"", Token: z.NewLeftBracketToken(1, 4)},
+				{Path: ".\"a b\"[0]", Token: z.NewNumberToken("1", 1, 5)},
+				{Path: ".\"a b\"[1]", Token: z.NewLeftBraceToken(1, 7)},
+				{Path: ".\"a b\"[1].\"c/d\"", Token: z.NewNumberToken("2", 1, 10)},
+				{Path: ".\"a b\"[1]", Token: z.NewRightBraceToken(1, 11)},
+				{Path: ".\"a b\"", Token: z.NewRightBracketToken(1, 12)},
+				{Path: ".e", Token: z.NewLeftBraceToken(1, 16)},
+				{Path: ".e", Token: z.NewRightBraceToken(1, 17)},
+				{Path: ".", Token: z.NewRightBraceToken(1, 18)},
+			},
+		},
+		{name: "emitContainersLastKeyWins",
+			config: Config{EmitContainers: true, DuplicateKeys: LastKeyWins},
+			expected: []ParsingResult{
+				{Path: ".", Token: z.NewLeftBraceToken(1, 1)},
+				{Path: ".\"a b\"", Token: z.NewLeftBracketToken(1, 4)},
+				{Path: ".\"a b\"[0]", Token: z.NewNumberToken("1", 1, 5)},
+				{Path: ".\"a b\"[1]", Token: z.NewLeftBraceToken(1, 7)},
+				{Path: ".\"a b\"[1].\"c/d\"", Token: z.NewNumberToken("2", 1, 10)},
+				{Path: ".\"a b\"[1]", Token: z.NewRightBraceToken(1, 11)},
+				{Path: ".\"a b\"", Token: z.NewRightBracketToken(1, 12)},
+				{Path: ".e", Token: z.NewLeftBraceToken(1, 16)},
+				{Path: ".e", Token: z.NewRightBraceToken(1, 17)},
+				{Path: ".", Token: z.NewRightBraceToken(1, 18)},
+			},
+		},
+		{name: "bufferContainers",
+			config: Config{EmitContainers: true, BufferContainers: true},
+			expected: []ParsingResult{
+				{Path: ".", Token: z.NewLeftBraceToken(1, 1)},
+				{Path: ".\"a b\"", Token: z.NewLeftBracketToken(1, 4)},
+				{Path: ".\"a b\"[0]", Token: z.NewNumberToken("1", 1, 5)},
+				{Path: ".\"a b\"[1]", Token: z.NewLeftBraceToken(1, 7)},
+				{Path: ".\"a b\"[1].\"c/d\"", Token: z.NewNumberToken("2", 1, 10)},
+				{Path: ".\"a b\"[1]", Token: z.NewRightBraceToken(1, 11), Container: tokens[6:11]},
+				{Path: ".\"a b\"", Token: z.NewRightBracketToken(1, 12), Container: tokens[3:12]},
+				{Path: ".e", Token: z.NewLeftBraceToken(1, 16)},
+				{Path: ".e", Token: z.NewRightBraceToken(1, 17), Container: tokens[15:17]},
+				{Path: ".", Token: z.NewRightBraceToken(1, 18), Container: tokens},
+			},
+		},
+		{name: "unbufferedRoot",
+			config: Config{EmitContainers: true, BufferContainers: true, UnbufferedRoot: true},
+			expected: []ParsingResult{
+				{Path: ".", Token: z.NewLeftBraceToken(1, 1)},
+				{Path: ".\"a b\"", Token: z.NewLeftBracketToken(1, 4)},
+				{Path: ".\"a b\"[0]", Token: z.NewNumberToken("1", 1, 5)},
+				{Path: ".\"a b\"[1]", Token: z.NewLeftBraceToken(1, 7)},
+				{Path: ".\"a b\"[1].\"c/d\"", Token: z.NewNumberToken("2", 1, 10)},
+				{Path: ".\"a b\"[1]", Token: z.NewRightBraceToken(1, 11), Container: tokens[6:11]},
+				{Path: ".\"a b\"", Token: z.NewRightBracketToken(1, 12), Container: tokens[3:12]},
+				{Path: ".e", Token: z.NewLeftBraceToken(1, 16)},
+				{Path: ".e", Token: z.NewRightBraceToken(1, 17), Container: tokens[15:17]},
+				{Path: ".", Token: z.NewRightBraceToken(1, 18)},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			tokenStream := make(chan z.TokenResult)
+
+			go func() {
+				for _, t := range tokens {
+					tokenStream <- z.TokenResult{Token: t}
+				}
+				close(tokenStream)
+			}()
+
+			p, err := NewParser(tokenStream, tc.config)
+
+			if err != nil {
+				t.Error(err)
+			}
+
+			go p.Parse()
+
+			result := make([]ParsingResult, 0, 10)
+
+			for pr := range p.GetResultReadStream() {
+				result = append(result, pr)
+			}
+
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestParseLazyPaths(t *testing.T) {
+	// {'a b': [1, {'c/d': 2}], 'e': {}}
+	tokens := []z.Token{
+		z.NewLeftBraceToken(1, 1),
+		z.NewStringToken("a b", 1, 2),
+		z.NewColonToken(1, 3),
+		z.NewLeftBracketToken(1, 4),
+		z.NewNumberToken("1", 1, 5),
+		z.NewCommaToken(1, 6),
+		z.NewLeftBraceToken(1, 7),
+		z.NewStringToken("c/d", 1, 8),
+		z.NewColonToken(1, 9),
+		z.NewNumberToken("2", 1, 10),
+		z.NewRightBraceToken(1, 11),
+		z.NewRightBracketToken(1, 12),
+		z.NewCommaToken(1, 13),
+		z.NewStringToken("e", 1, 14),
+		z.NewColonToken(1, 15),
+		z.NewLeftBraceToken(1, 16),
+		z.NewRightBraceToken(1, 17),
+		z.NewRightBraceToken(1, 18),
+	}
+
+	type lazyResult struct {
+		path     string
+		segments []paths.Segment
+	}
+
+	ab, cd, e := paths.KeySegment("a b"), paths.KeySegment("c/d"), paths.KeySegment("e")
+
+	testCases := []struct {
+		name     string
+		config   Config
+		expected []lazyResult
+	}{
+		{name: "jqPath",
+			config: Config{LazyPaths: true, EmitContainers: true},
+			expected: []lazyResult{
+				{".", []paths.Segment{}},
+				{".\"a b\"", []paths.Segment{ab}},
+				{".\"a b\"[0]", []paths.Segment{ab, paths.IndexSegment(0)}},
+				{".\"a b\"[1]", []paths.Segment{ab, paths.IndexSegment(1)}},
+				{".\"a b\"[1].\"c/d\"", []paths.Segment{ab, paths.IndexSegment(1), cd}},
+				{".\"a b\"[1]", []paths.Segment{ab, paths.IndexSegment(1)}},
+				{".\"a b\"", []paths.Segment{ab}},
+				{".e", []paths.Segment{e}},
+				{".e", []paths.Segment{e}},
+				{".", []paths.Segment{}},
+			},
+		},
+		{name: "jsonPointer",
+			config: Config{LazyPaths: true, PathFormat: JSONPointer},
+			expected: []lazyResult{
+				{"/a b/0", []paths.Segment{ab, paths.IndexSegment(0)}},
+				{"/a b/1/c~1d", []paths.Segment{ab, paths.IndexSegment(1), cd}},
+			},
+		},
+		{name: "jsonPath",
+			config: Config{LazyPaths: true, PathFormat: JSONPath, DuplicateKeys: LastKeyWins},
+			expected: []lazyResult{
+				{"$['a b'][0]", []paths.Segment{ab, paths.IndexSegment(0)}},
+				{"$['a b'][1]['c/d']", []paths.Segment{ab, paths.IndexSegment(1), cd}},
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			tokenStream := make(chan z.TokenResult)
+
+			go func() {
+				for _, t := range tokens {
+					tokenStream <- z.TokenResult{Token: t}
+				}
+				close(tokenStream)
+			}()
+
+			p, err := NewParser(tokenStream, tc.config)
+
+			if err != nil {
+				t.Error(err)
+			}
+
+			go p.Parse()
+
+			result := make([]lazyResult, 0, 10)
+
+			for pr := range p.GetResultReadStream() {
+				if pr.Path != "" {
+					t.Errorf("Expected no path, got '%v' instead\n", pr.Path)
+				}
+				result = append(result, lazyResult{pr.Lazy.String(), pr.Lazy.Segments()})
+			}
+
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, result)
+			}
+		})
+	}
+}
+
+// nestedTokens are the tokens of arrays of objects nested depth levels
+// deep, holding width values each.
+func nestedTokens(depth int, width int) []z.Token {
+	var tokens []z.Token
+
+	for d := 0; d < depth; d++ {
+		tokens = append(tokens, z.NewLeftBracketToken(1, 1), z.NewLeftBraceToken(1, 1))
+
+		for w := 0; w < width; w++ {
+			tokens = append(tokens,
+				z.NewStringToken(strings.Repeat("k", w+1), 1, 1),
+				z.NewColonToken(1, 1),
+				z.NewNumberToken("1", 1, 1),
+				z.NewCommaToken(1, 1))
+		}
+		tokens = append(tokens, z.NewStringToken("next", 1, 1), z.NewColonToken(1, 1))
+	}
+
+	tokens = append(tokens, z.NewNullToken(1, 1))
+
+	for d := 0; d < depth; d++ {
+		tokens = append(tokens, z.NewRightBraceToken(1, 1), z.NewRightBracketToken(1, 1))
+	}
+	return tokens
+}
+
+func benchmarkParse(b *testing.B, config Config) {
+	tokens := nestedTokens(50, 20)
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		tokenStream := make(chan z.TokenResult, len(tokens))
+
+		for _, t := range tokens {
+			tokenStream <- z.TokenResult{Token: t}
+		}
+		close(tokenStream)
+
+		p, err := NewParser(tokenStream, config)
+
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		go p.Parse()
+
+		for range p.GetResultReadStream() {
+		}
+	}
+}
+
+func BenchmarkParse(b *testing.B) {
+	benchmarkParse(b, Config{})
+}
+
+func BenchmarkParseLazyPaths(b *testing.B) {
+	benchmarkParse(b, Config{LazyPaths: true})
+}
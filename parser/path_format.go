@@ -0,0 +1,180 @@
+package parser
+
+import (
+	"strconv"
+
+	"github.com/rodic/jmatch/paths"
+)
+
+// PathFormat is the notation paths are written in.
+type PathFormat int
+
+const (
+	// JQPath writes paths the way jq does: .a."b c"[0], and . for the root.
+	// Keys that are not identifiers are quoted.
+	JQPath PathFormat = iota
+	// JSONPointer writes paths as defined by RFC 6901: /a/b c/0, and an
+	// empty string for the root.
+	JSONPointer
+	// JSONPath writes paths in the bracket-for-special-keys JSONPath style:
+	// $.a['b c'][0], and $ for the root.
+	JSONPath
+)
+
+// root is the path of the root container.
+func (f PathFormat) root() string {
+	if f == JSONPath {
+		return "$"
+	}
+	return ""
+}
+
+// display makes the path of the root container printable, the rest of the
+// paths are printable as they are.
+func (f PathFormat) display(path string) string {
+	if f == JQPath && path == "" {
+		return "."
+	}
+	return path
+}
+
+// appendSegment appends the segment to the path written in dst from start
+// on.
+func (f PathFormat) appendSegment(dst []byte, start int, segment paths.Segment) []byte {
+	if segment.IsIndex() {
+		return f.appendIndex(dst, start, segment.Index)
+	}
+	return f.appendKey(dst, segment.Key)
+}
+
+func (f PathFormat) appendKey(dst []byte, key string) []byte {
+	switch f {
+	case JSONPointer:
+		dst = append(dst, '/')
+
+		for i := 0; i < len(key); i++ {
+			switch key[i] {
+			case '~':
+				dst = append(dst, "~0"...)
+			case '/':
+				dst = append(dst, "~1"...)
+			default:
+				dst = append(dst, key[i])
+			}
+		}
+		return dst
+	case JSONPath:
+		if paths.IsIdentifier(key) {
+			return append(append(dst, '.'), key...)
+		}
+
+		dst = append(dst, "['"...)
+
+		for i := 0; i < len(key); i++ {
+			if key[i] == '\\' || key[i] == '\'' {
+				dst = append(dst, '\\')
+			}
+			dst = append(dst, key[i])
+		}
+		return append(dst, "']"...)
+	default:
+		return paths.AppendKey(dst, key)
+	}
+}
+
+func (f PathFormat) appendIndex(dst []byte, start int, index int) []byte {
+	switch f {
+	case JSONPointer:
+		return strconv.AppendInt(append(dst, '/'), int64(index), 10)
+	case JSONPath:
+		return paths.AppendIndex(dst, index)
+	default:
+		if len(dst) == start {
+			dst = append(dst, '.') // .[0] as jq has it
+		}
+		return paths.AppendIndex(dst, index)
+	}
+}
+
+// pathBuffer holds the paths of the containers open, one after the other:
+// the path of a container starts with the one of the container around it.
+// A path is written by cutting the buffer down to the path of its
+// container and appending its last segment, so that paths are built
+// without copying the ones they start with.
+type pathBuffer struct {
+	format PathFormat
+	path   []byte
+}
+
+func newPathBuffer(format PathFormat) *pathBuffer {
+	return &pathBuffer{format: format, path: []byte(format.root())}
+}
+
+// write writes the path of the segment of the container whose path is
+// length bytes long.
+func (b *pathBuffer) write(length int, segment paths.Segment) []byte {
+	b.path = b.format.appendSegment(b.path[:length], 0, segment)
+	return b.path
+}
+
+// Path is the path of a value as a chain of segments, written out only
+// when asked for. Its containers are shared by the paths of the values
+// they hold, so passing a Path around allocates nothing.
+type Path struct {
+	format  PathFormat
+	parent  *Path // of the container, nil for the root
+	segment paths.Segment
+}
+
+// String writes the path the way it is written without lazy paths.
+func (p Path) String() string {
+	return string(p.AppendTo(nil))
+}
+
+// AppendTo appends the path, as String writes it, to dst, so that it can
+// be written to a buffer of the caller without allocating.
+func (p Path) AppendTo(dst []byte) []byte {
+	start := len(dst)
+	dst = p.appendTo(dst, start)
+
+	if len(dst) == start && p.format == JQPath {
+		dst = append(dst, '.')
+	}
+	return dst
+}
+
+func (p Path) appendTo(dst []byte, start int) []byte {
+	if p.parent == nil {
+		return append(dst, p.format.root()...)
+	}
+	dst = p.parent.appendTo(dst, start)
+	return p.format.appendSegment(dst, start, p.segment)
+}
+
+// Segments returns the keys and indexes the path is made of, from the
+// root on, none for the root.
+func (p Path) Segments() []paths.Segment {
+	segments := make([]paths.Segment, p.Depth())
+
+	for i := len(segments) - 1; i >= 0; i-- {
+		segments[i] = p.segment
+		p = *p.parent
+	}
+	return segments
+}
+
+// Last returns the last segment of the path, the key or index of the value
+// in its container, and false for the root.
+func (p Path) Last() (paths.Segment, bool) {
+	return p.segment, p.parent != nil
+}
+
+// Depth is the number of segments of the path.
+func (p Path) Depth() int {
+	depth := 0
+
+	for q := &p; q.parent != nil; q = q.parent {
+		depth++
+	}
+	return depth
+}
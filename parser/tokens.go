@@ -1,18 +1,93 @@
 package parser
 
-import z "github.com/rodic/jmatch/tokenizer"
+import (
+	"io"
+
+	c "github.com/rodic/jmatch/common"
+	z "github.com/rodic/jmatch/tokenizer"
+)
+
+// tokenSource returns the next token read, false once there are none
+// left.
+type tokenSource func() (z.TokenResult, bool)
+
+// streamSource reads the tokens sent down the stream.
+func streamSource(tokensChan <-chan z.TokenResult) tokenSource {
+	return func() (z.TokenResult, bool) {
+		result, isOpen := <-tokensChan
+		return result, isOpen
+	}
+}
+
+// scannerSource reads the tokens of the scanner on the goroutine asking
+// for them, copied out of its buffer unless they are known keys.
+func scannerSource(scanner *z.Scanner, keys keyCache) tokenSource {
+	return func() (z.TokenResult, bool) {
+		raw, err := scanner.Scan()
+
+		if err == io.EOF {
+			return z.TokenResult{}, false
+		}
+		if err != nil {
+			return z.TokenResult{Error: err}, true
+		}
+
+		if raw.IsString() {
+			if key, ok := keys[string(raw.Bytes)]; ok {
+				token := raw.Token
+				token.Value = key
+				return z.TokenResult{Token: token}, true
+			}
+		}
+		return z.TokenResult{Token: raw.Copy()}, true
+	}
+}
+
+const maxCachedKeys = 4096
+
+// keyCache holds the keys read so far, so that the ones repeated through a
+// document are copied out of the scanner's buffer once.
+type keyCache map[string]string
+
+func (k keyCache) add(key string) {
+	if _, ok := k[key]; !ok && len(k) < maxCachedKeys {
+		k[key] = key
+	}
+}
 
 type tokenList struct {
-	tokensChan <-chan z.TokenResult
-	current    z.Token
-	next       z.Token
-	hasNext    bool
+	source  tokenSource
+	current z.Token
+	next    z.Token
+	hasNext bool
+	empty   bool // the stream held no token at all
+	recover bool
+	errors  []error
+	keys    keyCache // nil unless the source reads them
+}
+
+// receive reads the next result from the token stream. In recovery mode
+// lexeme errors are put aside and an invalid token takes the place of the
+// lexeme that could not be read.
+func (t *tokenList) receive() (z.TokenResult, bool) {
+	result, isOpen := t.source()
+
+	if e, ok := result.Error.(c.UnexpectedTokenErr); ok && t.recover {
+		t.errors = append(t.errors, e)
+		return z.TokenResult{Token: z.NewInvalidToken(e.Token, e.Line, e.Column)}, isOpen
+	}
+
+	return result, isOpen
 }
 
 func (t *tokenList) move() error {
+	if !t.hasNext {
+		return nil // stay on the last token
+	}
+
 	var nextResult z.TokenResult
 
-	nextResult, t.hasNext = <-t.tokensChan
+	nextResult, t.hasNext = t.receive()
 
 	if nextResult.Error != nil {
 		return nextResult.Error
@@ -21,32 +96,66 @@ func (t *tokenList) move() error {
 	t.current = t.next
 	t.next = nextResult.Token
 
+	t.cacheKey()
 	return nil
 }
 
-func NewTokens(tokensChan <-chan z.TokenResult) (*tokenList, error) {
-	currentResult, isOpen := <-tokensChan
+// cacheKey adds the current token to the key cache if it is a key.
+func (t *tokenList) cacheKey() {
+	if t.keys != nil && t.current.IsString() && t.next.IsColon() {
+		t.keys.add(t.current.Value)
+	}
+}
+
+// takeErrors returns the tokenizer errors collected in recovery mode since
+// the last call.
+func (t *tokenList) takeErrors() []error {
+	errors := t.errors
+	t.errors = nil
+	return errors
+}
+
+func NewTokens(tokensChan <-chan z.TokenResult, recover bool) (*tokenList, error) {
+	tl, err := newTokens(streamSource(tokensChan), nil, recover)
+
+	if err != nil {
+		return nil, err
+	}
+	return tl, nil
+}
+
+// newTokens reads the first two tokens. On error, the list holds the ones
+// read before it.
+func newTokens(source tokenSource, keys keyCache, recover bool) (*tokenList, error) {
+	tl := tokenList{
+		source:  source,
+		recover: recover,
+		keys:    keys,
+	}
+
+	currentResult, isOpen := tl.receive()
 
 	if currentResult.Error != nil {
-		return nil, currentResult.Error
+		return &tl, currentResult.Error
 	}
 
 	var nextResult z.TokenResult
 
+	tl.empty = !isOpen
+
+	tl.current = currentResult.Token
+
 	if isOpen {
-		nextResult, isOpen = <-tokensChan
+		nextResult, isOpen = tl.receive()
 
 		if nextResult.Error != nil {
-			return nil, nextResult.Error
+			return &tl, nextResult.Error
 		}
 	}
 
-	tl := tokenList{
-		tokensChan: tokensChan,
-		current:    currentResult.Token,
-		next:       nextResult.Token,
-		hasNext:    isOpen,
-	}
+	tl.next = nextResult.Token
+	tl.hasNext = isOpen
 
+	tl.cacheKey()
 	return &tl, nil
 }
@@ -0,0 +1,210 @@
+package patch
+
+import (
+	"io"
+
+	"github.com/rodic/jmatch"
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+// Apply writes the document read with the patch applied. The values
+// operations work on are held in memory, with the objects and arrays
+// around them in these cases:
+//
+//   - elements added to or removed from an array hold the array
+//   - move and copy hold the closest object or array around both paths
+//
+// Other values are written out as they are read, so a patch touching a few
+// values of a large document is applied in little memory. Keys added to
+// an object are written at its end.
+func (p Patch) Apply(r io.Reader, w io.Writer) error {
+	steps, err := p.compile()
+
+	if err != nil {
+		return err
+	}
+
+	a := &applier{steps: steps, out: newOutput(w)}
+
+	if err := jmatch.Match(r, a.visit, jmatch.WithContainers()); err != nil {
+		return err
+	}
+	if a.err != nil {
+		return a.err
+	}
+
+	for _, s := range steps {
+		if !s.applied {
+			return s.fail(ErrNotFound)
+		}
+	}
+	return a.out.flush()
+}
+
+// container is an object or array of the document being written.
+type container struct {
+	path   []string
+	object bool
+	// slots are the keys of the members operations work on, set once the
+	// member is found
+	slots map[string]bool
+}
+
+type applier struct {
+	steps []*step
+	out   *output
+	err   error
+
+	stack []*container
+
+	// the value held in memory, with its path and key
+	capture *builder
+	base    []string
+	key     string
+}
+
+func (a *applier) visit(path string, token t.Token) {
+	if a.err != nil {
+		return
+	}
+
+	key, err := lastKey(path)
+
+	if err != nil {
+		a.err = err
+		return
+	}
+
+	if a.capture != nil {
+		if a.capture.add(key, token) {
+			a.release()
+		}
+		return
+	}
+
+	if token.IsRightBrace() || token.IsRightBracket() {
+		closed := a.stack[len(a.stack)-1]
+
+		if closed.object {
+			a.addMissing(closed)
+		}
+
+		a.stack = a.stack[:len(a.stack)-1]
+		a.out.token(token)
+		return
+	}
+
+	pointer, err := tokens(path)
+
+	if err != nil {
+		a.err = err
+		return
+	}
+
+	if len(a.stack) > 0 {
+		if parent := a.stack[len(a.stack)-1]; parent.object {
+			if _, ok := parent.slots[key]; ok {
+				parent.slots[key] = true
+			}
+		}
+	}
+
+	isContainer := token.IsLeftBrace() || token.IsLeftBracket()
+
+	// the targets of adds and removes in this container are known now
+	if isContainer {
+		for _, s := range a.steps {
+			if !s.known && len(s.path) == len(pointer)+1 && hasPrefix(s.path, pointer) {
+				s.known = true
+
+				if token.IsLeftBracket() {
+					s.root = pointer
+				}
+			}
+		}
+	}
+
+	if a.isRoot(pointer) {
+		a.capture = &builder{}
+		a.base = pointer
+		a.key = key
+
+		if a.capture.add(key, token) {
+			a.release()
+		}
+		return
+	}
+
+	a.out.start(key)
+	a.out.token(token)
+
+	if isContainer {
+		c := &container{path: pointer, object: token.IsLeftBrace()}
+
+		for _, s := range a.steps {
+			if s.known && !s.applied && len(s.root) == len(pointer)+1 && hasPrefix(s.root, pointer) {
+				if c.slots == nil {
+					c.slots = map[string]bool{}
+				}
+				c.slots[s.root[len(pointer)]] = false
+			}
+		}
+
+		a.stack = append(a.stack, c)
+	}
+}
+
+// isRoot tells whether an operation works on the value at the path.
+func (a *applier) isRoot(path []string) bool {
+	for _, s := range a.steps {
+		if s.known && !s.applied && equalPaths(s.root, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// release applies the operations to the value held and writes it.
+func (a *applier) release() {
+	v := a.capture.root
+	a.capture = nil
+
+	a.write(a.base, a.key, v)
+}
+
+// addMissing applies the operations on the members not found in the
+// object, writing those they add in the order of the operations.
+func (a *applier) addMissing(c *container) {
+	for _, s := range a.steps {
+		if s.applied || !s.known || len(s.root) != len(c.path)+1 || !hasPrefix(s.root, c.path) {
+			continue
+		}
+
+		key := s.root[len(c.path)]
+
+		if found, ok := c.slots[key]; ok && !found {
+			a.write(s.root, key, nil)
+		}
+	}
+}
+
+// write applies the operations working on the value found at the path,
+// nil if there is none, and writes what is left.
+func (a *applier) write(path []string, key string, v *value) {
+	for _, s := range a.steps {
+		if s.applied || !hasPrefix(s.anchor(), path) {
+			continue
+		}
+		if err := s.apply(&v, path); err != nil {
+			a.err = err
+			return
+		}
+	}
+
+	if v != nil {
+		a.out.start(key)
+		a.out.value(v)
+	} else if len(path) == 0 {
+		a.out.w.WriteString("null") // the document was removed
+	}
+}
@@ -0,0 +1,149 @@
+package patch
+
+import (
+	"io"
+
+	"github.com/rodic/jmatch"
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+// MergePatch is an RFC 7396 JSON Merge Patch: an object holding the
+// members to set, nested objects being merged and nulls removing members.
+// Any other value replaces the document.
+type MergePatch struct {
+	value *value
+}
+
+// ReadMerge reads a JSON Merge Patch document.
+func ReadMerge(r io.Reader) (MergePatch, error) {
+	v, err := read(r)
+
+	if err != nil {
+		return MergePatch{}, err
+	}
+	return MergePatch{value: v}, nil
+}
+
+// Apply writes the document read with the merge patch applied. Only the
+// patch is held in memory. Members added to an object are written at its
+// end.
+func (m MergePatch) Apply(r io.Reader, w io.Writer) error {
+	mg := &merger{patch: m.value, out: newOutput(w)}
+
+	if err := jmatch.Match(r, mg.visit, jmatch.WithContainers()); err != nil {
+		return err
+	}
+	if mg.err != nil {
+		return mg.err
+	}
+	return mg.out.flush()
+}
+
+// merged is an object or array of the document being written, with the
+// part of the patch merged into it, if any.
+type merged struct {
+	patch *value
+	found map[string]bool // keys of the patch found in the object
+}
+
+type merger struct {
+	patch *value
+	out   *output
+	err   error
+
+	stack   []*merged
+	skipped int // depth in a value being replaced
+}
+
+func (mg *merger) visit(path string, token t.Token) {
+	isOpening := token.IsLeftBrace() || token.IsLeftBracket()
+	isClosing := token.IsRightBrace() || token.IsRightBracket()
+
+	if mg.err != nil {
+		return
+	}
+
+	if mg.skipped > 0 {
+		switch {
+		case isOpening:
+			mg.skipped++
+		case isClosing:
+			mg.skipped--
+		}
+		return
+	}
+
+	if isClosing {
+		closed := mg.stack[len(mg.stack)-1]
+		mg.stack = mg.stack[:len(mg.stack)-1]
+
+		if closed.patch != nil {
+			for _, m := range closed.patch.members {
+				if !closed.found[m.key] && !m.value.isNull() {
+					mg.out.start(m.key)
+					mg.out.value(withoutNulls(m.value))
+				}
+			}
+		}
+
+		mg.out.token(token)
+		return
+	}
+
+	key, err := lastKey(path)
+
+	if err != nil {
+		mg.err = err
+		return
+	}
+
+	patch, patched := mg.patch, len(mg.stack) == 0
+
+	if !patched {
+		if parent := mg.stack[len(mg.stack)-1]; parent.patch != nil {
+			if i, ok := parent.patch.member(key); ok {
+				patch, patched = parent.patch.members[i].value, true
+				parent.found[key] = true
+			}
+		}
+	}
+
+	switch {
+	case !patched:
+		mg.out.start(key)
+		mg.out.token(token)
+
+		if isOpening {
+			mg.stack = append(mg.stack, &merged{})
+		}
+	case patch.kind == object && token.IsLeftBrace():
+		mg.out.start(key)
+		mg.out.token(token)
+		mg.stack = append(mg.stack, &merged{patch: patch, found: map[string]bool{}})
+	default:
+		if isOpening {
+			mg.skipped = 1
+		}
+		if len(mg.stack) == 0 || !patch.isNull() {
+			mg.out.start(key)
+			mg.out.value(withoutNulls(patch))
+		}
+	}
+}
+
+// withoutNulls returns the value a patch sets a member missing from the
+// document to, that is the patch merged into an empty object.
+func withoutNulls(v *value) *value {
+	if v.kind != object {
+		return v
+	}
+
+	stripped := &value{kind: object}
+
+	for _, m := range v.members {
+		if !m.value.isNull() {
+			stripped.members = append(stripped.members, member{key: m.key, value: withoutNulls(m.value)})
+		}
+	}
+	return stripped
+}
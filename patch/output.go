@@ -0,0 +1,66 @@
+package patch
+
+import (
+	"bufio"
+	"io"
+
+	"github.com/rodic/jmatch/format"
+	"github.com/rodic/jmatch/paths"
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+// output writes a document as compact JSON, value by value.
+type output struct {
+	w      *bufio.Writer
+	levels []level
+}
+
+// level is an object or array being written.
+type level struct {
+	object bool
+	count  int // of the values written in it
+}
+
+func newOutput(w io.Writer) *output {
+	return &output{w: bufio.NewWriter(w)}
+}
+
+// start writes what comes before a value: a comma if it is not the first
+// in its container and, in objects, its key.
+func (o *output) start(key string) {
+	if len(o.levels) == 0 {
+		return
+	}
+
+	l := &o.levels[len(o.levels)-1]
+
+	if l.count > 0 {
+		o.w.WriteRune(',')
+	}
+	l.count++
+
+	if l.object {
+		o.w.WriteString(paths.Quote(key))
+		o.w.WriteRune(':')
+	}
+}
+
+// token writes the token, opening and closing objects and arrays.
+func (o *output) token(token t.Token) {
+	switch {
+	case token.IsLeftBrace() || token.IsLeftBracket():
+		o.levels = append(o.levels, level{object: token.IsLeftBrace()})
+	case token.IsRightBrace() || token.IsRightBracket():
+		o.levels = o.levels[:len(o.levels)-1]
+	}
+	o.w.WriteString(format.Literal(token))
+}
+
+func (o *output) value(v *value) {
+	o.w.WriteString(v.String())
+}
+
+func (o *output) flush() error {
+	o.w.WriteRune('\n')
+	return o.w.Flush()
+}
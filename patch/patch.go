@@ -0,0 +1,363 @@
+// Package patch applies RFC 6902 JSON Patches and RFC 7396 JSON Merge
+// Patches to documents as they stream through the parser:
+//
+//	p, err := patch.Read(patchFile)
+//	err = p.Apply(r, w)
+//
+// Only the parts of the document the operations work on are held in
+// memory, the rest being written out as it is read. The result is
+// written as compact JSON. On error, what was written so far is not a
+// valid document.
+package patch
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/rodic/jmatch/paths"
+)
+
+var (
+	// ErrNotFound is returned when an operation refers to a value that is
+	// not in the document.
+	ErrNotFound = errors.New("path not found")
+	// ErrTestFailed is returned when a test operation fails.
+	ErrTestFailed = errors.New("test failed")
+)
+
+// Operation is an operation of a JSON Patch: add, remove, replace, move,
+// copy or test. Paths are JSON Pointers and values JSON texts.
+type Operation struct {
+	Op    string
+	Path  string
+	From  string // of move and copy
+	Value string // of add, replace and test
+}
+
+// Patch is a JSON Patch, its operations applied one after the other.
+type Patch []Operation
+
+// Read reads a JSON Patch document.
+func Read(r io.Reader) (Patch, error) {
+	doc, err := read(r)
+
+	if err != nil {
+		return nil, err
+	}
+	if doc.kind != array {
+		return nil, fmt.Errorf("a patch must be an array of operations, got %s", doc)
+	}
+
+	patch := make(Patch, len(doc.elems))
+
+	for i, elem := range doc.elems {
+		if elem.kind != object {
+			return nil, fmt.Errorf("operation %d: expected an object, got %s", i, elem)
+		}
+
+		var value bool
+
+		for _, m := range elem.members {
+			field := map[string]*string{"op": &patch[i].Op, "path": &patch[i].Path, "from": &patch[i].From}[m.key]
+
+			switch {
+			case m.key == "value":
+				patch[i].Value = m.value.String()
+				value = true
+			case field == nil:
+				continue // other members are ignored
+			case m.value.kind != scalar || !m.value.token.IsString():
+				return nil, fmt.Errorf("operation %d: %s must be a string, got %s", i, m.key, m.value)
+			default:
+				*field = m.value.token.Value
+			}
+		}
+
+		if needsValue(patch[i].Op) && !value {
+			return nil, fmt.Errorf("operation %d: %s needs a value", i, patch[i].Op)
+		}
+	}
+	return patch, nil
+}
+
+func needsValue(op string) bool {
+	return op == "add" || op == "replace" || op == "test"
+}
+
+// step is an operation ready to be applied.
+type step struct {
+	index int
+	op    string
+	path  []string
+	from  []string
+	value *value
+
+	// root is the value the operation works on, from which on the document
+	// is held in memory; it is known once the parent of the target of an
+	// add or remove is read, as arrays are held whole
+	root    []string
+	known   bool
+	applied bool
+}
+
+func (p Patch) compile() ([]*step, error) {
+	steps := make([]*step, len(p))
+
+	for i, op := range p {
+		s := &step{index: i, op: op.Op}
+		steps[i] = s
+
+		var err error
+
+		if s.path, err = paths.SplitPointer(op.Path); err != nil {
+			return nil, s.fail(err)
+		}
+
+		switch op.Op {
+		case "add", "remove":
+			s.root = s.path
+			s.known = len(s.path) == 0
+		case "replace", "test":
+			s.root = s.path
+			s.known = true
+		case "move", "copy":
+			if s.from, err = paths.SplitPointer(op.From); err != nil {
+				return nil, s.fail(err)
+			}
+			if op.Op == "move" && len(s.from) < len(s.path) && hasPrefix(s.path, s.from) {
+				return nil, s.fail(fmt.Errorf("cannot move a value into itself"))
+			}
+
+			from := s.from
+
+			if op.Op == "move" {
+				from = parent(from)
+			}
+			s.root = common(from, parent(s.path))
+			s.known = true
+		default:
+			return nil, s.fail(fmt.Errorf("unknown operation %q", op.Op))
+		}
+
+		if needsValue(op.Op) {
+			if s.value, err = parse(op.Value); err != nil {
+				return nil, s.fail(err)
+			}
+		}
+	}
+	return steps, nil
+}
+
+func (s *step) fail(err error) error {
+	return fmt.Errorf("operation %d (%s): %w", s.index, s.op, err)
+}
+
+// anchor is the root of the operation if it is known, its target if not.
+func (s *step) anchor() []string {
+	if s.known {
+		return s.root
+	}
+	return s.path
+}
+
+// apply applies the operation to the value found at base, nil if there
+// is none.
+func (s *step) apply(root **value, base []string) error {
+	s.applied = true
+
+	path := s.path[len(base):]
+	var err error
+
+	switch s.op {
+	case "add":
+		err = add(root, path, s.value.copy())
+	case "remove":
+		_, err = remove(root, path)
+	case "replace":
+		if _, err = remove(root, path); err == nil {
+			err = add(root, path, s.value.copy())
+		}
+	case "move":
+		var v *value
+
+		if v, err = remove(root, s.from[len(base):]); err == nil {
+			err = add(root, path, v)
+		}
+	case "copy":
+		var v *value
+
+		if v, err = get(*root, s.from[len(base):]); err == nil {
+			err = add(root, path, v.copy())
+		}
+	case "test":
+		var v *value
+
+		if v, err = get(*root, path); err == nil && !equal(v, s.value) {
+			err = ErrTestFailed
+		}
+	}
+
+	if err != nil {
+		return s.fail(err)
+	}
+	return nil
+}
+
+// get returns the value at the path.
+func get(v *value, path []string) (*value, error) {
+	for _, token := range path {
+		if v == nil {
+			return nil, ErrNotFound
+		}
+
+		switch v.kind {
+		case object:
+			i, ok := v.member(token)
+
+			if !ok {
+				return nil, ErrNotFound
+			}
+			v = v.members[i].value
+		case array:
+			i, err := index(token, len(v.elems)-1)
+
+			if err != nil {
+				return nil, err
+			}
+			v = v.elems[i]
+		default:
+			return nil, ErrNotFound
+		}
+	}
+
+	if v == nil {
+		return nil, ErrNotFound
+	}
+	return v, nil
+}
+
+func add(root **value, path []string, v *value) error {
+	if len(path) == 0 {
+		*root = v
+		return nil
+	}
+
+	parent, err := get(*root, path[:len(path)-1])
+
+	if err != nil {
+		return err
+	}
+
+	token := path[len(path)-1]
+
+	switch parent.kind {
+	case object:
+		parent.set(token, v)
+	case array:
+		i := len(parent.elems)
+
+		if token != "-" {
+			if i, err = index(token, len(parent.elems)); err != nil {
+				return err
+			}
+		}
+
+		parent.elems = append(parent.elems, nil)
+		copy(parent.elems[i+1:], parent.elems[i:])
+		parent.elems[i] = v
+	default:
+		return ErrNotFound
+	}
+	return nil
+}
+
+func remove(root **value, path []string) (*value, error) {
+	if len(path) == 0 {
+		if *root == nil {
+			return nil, ErrNotFound
+		}
+
+		removed := *root
+		*root = nil
+		return removed, nil
+	}
+
+	parent, err := get(*root, path[:len(path)-1])
+
+	if err != nil {
+		return nil, err
+	}
+
+	token := path[len(path)-1]
+
+	switch parent.kind {
+	case object:
+		i, ok := parent.member(token)
+
+		if !ok {
+			return nil, ErrNotFound
+		}
+
+		removed := parent.members[i].value
+		parent.members = append(parent.members[:i], parent.members[i+1:]...)
+		return removed, nil
+	case array:
+		i, err := index(token, len(parent.elems)-1)
+
+		if err != nil {
+			return nil, err
+		}
+
+		removed := parent.elems[i]
+		parent.elems = append(parent.elems[:i], parent.elems[i+1:]...)
+		return removed, nil
+	}
+	return nil, ErrNotFound
+}
+
+// index reads an array index no greater than max.
+func index(token string, max int) (int, error) {
+	i, err := strconv.Atoi(token)
+
+	if err != nil || i < 0 || (len(token) > 1 && token[0] == '0') || token[0] == '+' {
+		return 0, fmt.Errorf("invalid array index %q", token)
+	}
+	if i > max {
+		return 0, ErrNotFound
+	}
+	return i, nil
+}
+
+func parent(path []string) []string {
+	if len(path) == 0 {
+		return path
+	}
+	return path[:len(path)-1]
+}
+
+func common(a []string, b []string) []string {
+	i := 0
+
+	for i < len(a) && i < len(b) && a[i] == b[i] {
+		i++
+	}
+	return a[:i]
+}
+
+func hasPrefix(path []string, prefix []string) bool {
+	return len(path) >= len(prefix) && equalPaths(path[:len(prefix)], prefix)
+}
+
+func equalPaths(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,260 @@
+package patch
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rodic/jmatch/diff"
+)
+
+func TestApply(t *testing.T) {
+	testCases := []struct {
+		name     string
+		doc      string
+		patch    string
+		expected string
+	}{
+		// the examples of RFC 6902, appendix A
+		{name: "addMember",
+			doc:      `{"foo": "bar"}`,
+			patch:    `[{"op": "add", "path": "/baz", "value": "qux"}]`,
+			expected: `{"foo":"bar","baz":"qux"}`},
+		{name: "addElement",
+			doc:      `{"foo": ["bar", "baz"]}`,
+			patch:    `[{"op": "add", "path": "/foo/1", "value": "qux"}]`,
+			expected: `{"foo":["bar","qux","baz"]}`},
+		{name: "removeMember",
+			doc:      `{"baz": "qux", "foo": "bar"}`,
+			patch:    `[{"op": "remove", "path": "/baz"}]`,
+			expected: `{"foo":"bar"}`},
+		{name: "removeElement",
+			doc:      `{"foo": ["bar", "qux", "baz"]}`,
+			patch:    `[{"op": "remove", "path": "/foo/1"}]`,
+			expected: `{"foo":["bar","baz"]}`},
+		{name: "replace",
+			doc:      `{"baz": "qux", "foo": "bar"}`,
+			patch:    `[{"op": "replace", "path": "/baz", "value": "boo"}]`,
+			expected: `{"baz":"boo","foo":"bar"}`},
+		{name: "move",
+			doc:      `{"foo": {"bar": "baz", "waldo": "fred"}, "qux": {"corge": "grault"}}`,
+			patch:    `[{"op": "move", "from": "/foo/waldo", "path": "/qux/thud"}]`,
+			expected: `{"foo":{"bar":"baz"},"qux":{"corge":"grault","thud":"fred"}}`},
+		{name: "moveElement",
+			doc:      `{"foo": ["all", "grass", "cows", "eat"]}`,
+			patch:    `[{"op": "move", "from": "/foo/1", "path": "/foo/3"}]`,
+			expected: `{"foo":["all","cows","eat","grass"]}`},
+		{name: "test",
+			doc:      `{"baz": "qux", "foo": ["a", 2, "c"]}`,
+			patch:    `[{"op": "test", "path": "/baz", "value": "qux"}, {"op": "test", "path": "/foo/1", "value": 2.0}]`,
+			expected: `{"baz":"qux","foo":["a",2,"c"]}`},
+		{name: "addNested",
+			doc:      `{"foo": "bar"}`,
+			patch:    `[{"op": "add", "path": "/child", "value": {"grandchild": {}}}]`,
+			expected: `{"foo":"bar","child":{"grandchild":{}}}`},
+		{name: "addArray",
+			doc:      `{"foo": ["bar"]}`,
+			patch:    `[{"op": "add", "path": "/foo/-", "value": ["abc", "def"]}]`,
+			expected: `{"foo":["bar",["abc","def"]]}`},
+		{name: "escapes",
+			doc:      `{"/": 1, "m~n": 2}`,
+			patch:    `[{"op": "test", "path": "/~1", "value": 1}, {"op": "replace", "path": "/m~0n", "value": 3}]`,
+			expected: `{"/":1,"m~n":3}`},
+		{name: "sequence",
+			doc: `{"a": {"b": [1, 2, 3]}, "c": 1}`,
+			patch: `[{"op": "remove", "path": "/a/b/0"}, {"op": "replace", "path": "/a/b/0", "value": 9},
+				{"op": "add", "path": "/d", "value": 1}, {"op": "remove", "path": "/d"}, {"op": "add", "path": "/d", "value": 2}]`,
+			expected: `{"a":{"b":[9,3]},"c":1,"d":2}`},
+		{name: "copy",
+			doc:      `{"a": {"x": [1]}, "b": {}}`,
+			patch:    `[{"op": "copy", "from": "/a/x", "path": "/b/y"}, {"op": "add", "path": "/b/y/-", "value": 2}]`,
+			expected: `{"a":{"x":[1]},"b":{"y":[1,2]}}`},
+		{name: "replaceDocument",
+			doc:      `[1, 2]`,
+			patch:    `[{"op": "replace", "path": "", "value": {"a": 1}}]`,
+			expected: `{"a":1}`},
+		{name: "untouched",
+			doc:      "{\"a\": [1, {\"b\": null}],\n \"c\": \"\\u00e9\"}",
+			patch:    `[]`,
+			expected: `{"a":[1,{"b":null}],"c":"é"}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := Read(strings.NewReader(tc.patch))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var out strings.Builder
+
+			if err := p.Apply(strings.NewReader(tc.doc), &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tc.expected+"\n" {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestApplyErrors(t *testing.T) {
+	testCases := []struct {
+		name     string
+		doc      string
+		patch    string
+		expected error
+	}{
+		{name: "testFailed",
+			doc:      `{"baz": "qux"}`,
+			patch:    `[{"op": "test", "path": "/baz", "value": "bar"}]`,
+			expected: ErrTestFailed},
+		{name: "missingParent",
+			doc:      `{"foo": "bar"}`,
+			patch:    `[{"op": "add", "path": "/baz/bat", "value": "qux"}]`,
+			expected: ErrNotFound},
+		{name: "missingMember",
+			doc:      `{"foo": "bar"}`,
+			patch:    `[{"op": "remove", "path": "/baz"}]`,
+			expected: ErrNotFound},
+		{name: "outOfRange",
+			doc:      `{"foo": [1]}`,
+			patch:    `[{"op": "replace", "path": "/foo/1", "value": 2}]`,
+			expected: ErrNotFound},
+		{name: "addOutOfRange",
+			doc:      `{"foo": [1]}`,
+			patch:    `[{"op": "add", "path": "/foo/2", "value": 2}]`,
+			expected: ErrNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := Read(strings.NewReader(tc.patch))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var out strings.Builder
+
+			if err := p.Apply(strings.NewReader(tc.doc), &out); !errors.Is(err, tc.expected) {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, err)
+			}
+		})
+	}
+
+	invalid := []string{
+		`{"op": "add"}`,
+		`[{"op": "add", "path": "/a"}]`,
+		`[{"op": "frob", "path": "/a"}]`,
+		`[{"op": "remove", "path": "a"}]`,
+		`[{"op": "move", "from": "/a", "path": "/a/b"}]`,
+		`[{"op": "remove", "path": 1}]`,
+		`[{"op": "test", "path": "/a", "value": }]`,
+	}
+
+	for _, patch := range invalid {
+		p, err := Read(strings.NewReader(patch))
+
+		if err == nil {
+			err = p.Apply(strings.NewReader(`{"a": 1}`), &strings.Builder{})
+		}
+		if err == nil {
+			t.Errorf("Expected an error for %s\n", patch)
+		}
+	}
+}
+
+func TestMergePatch(t *testing.T) {
+	testCases := []struct {
+		doc      string
+		patch    string
+		expected string
+	}{
+		// the examples of RFC 7396, appendix A
+		{doc: `{"a":"b"}`, patch: `{"a":"c"}`, expected: `{"a":"c"}`},
+		{doc: `{"a":"b"}`, patch: `{"b":"c"}`, expected: `{"a":"b","b":"c"}`},
+		{doc: `{"a":"b"}`, patch: `{"a":null}`, expected: `{}`},
+		{doc: `{"a":"b","b":"c"}`, patch: `{"a":null}`, expected: `{"b":"c"}`},
+		{doc: `{"a":["b"]}`, patch: `{"a":"c"}`, expected: `{"a":"c"}`},
+		{doc: `{"a":"c"}`, patch: `{"a":["b"]}`, expected: `{"a":["b"]}`},
+		{doc: `{"a":{"b":"c"}}`, patch: `{"a":{"b":"d","c":null}}`, expected: `{"a":{"b":"d"}}`},
+		{doc: `{"a":[{"b":"c"}]}`, patch: `{"a":[1]}`, expected: `{"a":[1]}`},
+		{doc: `["a","b"]`, patch: `["c","d"]`, expected: `["c","d"]`},
+		{doc: `{"a":"b"}`, patch: `["c"]`, expected: `["c"]`},
+		{doc: `{"a":"foo"}`, patch: `null`, expected: `null`},
+		{doc: `{"a":"foo"}`, patch: `"bar"`, expected: `"bar"`},
+		{doc: `{"e":null}`, patch: `{"a":1}`, expected: `{"e":null,"a":1}`},
+		{doc: `[1,2]`, patch: `{"a":"b","c":null}`, expected: `{"a":"b"}`},
+		{doc: `{}`, patch: `{"a":{"bb":{"ccc":null}}}`, expected: `{"a":{"bb":{}}}`},
+		{doc: `{"a":{"x":[1,{"y":2}]},"z":3}`, patch: `{"a":{"w":1}}`, expected: `{"a":{"x":[1,{"y":2}],"w":1},"z":3}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.patch, func(t *testing.T) {
+			m, err := ReadMerge(strings.NewReader(tc.patch))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var out strings.Builder
+
+			if err := m.Apply(strings.NewReader(tc.doc), &out); err != nil {
+				t.Fatal(err)
+			}
+			if out.String() != tc.expected+"\n" {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, out.String())
+			}
+		})
+	}
+}
+
+func TestApplyDiff(t *testing.T) {
+	testCases := []struct {
+		left      string
+		right     string
+		arrayKeys map[string]string
+	}{
+		{left: `{"a": 1, "b": [1, 2, 3], "c": {"x": 1}, "d": "s"}`,
+			right: `{"a": 2, "b": [1, 2], "c": [1], "d": "s", "e": {"f": [true]}}`},
+		{left: `[[1, 2, 3], [1], 3, 4]`,
+			right: `[[1], [1, 2], 3]`},
+		{left: `[{"id": 1, "v": "a"}, {"id": 2, "v": "b"}, {"id": 3, "v": [1]}]`,
+			right:     `[{"id": 0}, {"id": 1, "v": "a"}, {"id": 3, "v": [1, 2]}]`,
+			arrayKeys: map[string]string{".[]": ".id"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.right, func(t *testing.T) {
+			changes, err := diff.Diff(strings.NewReader(tc.left), strings.NewReader(tc.right), diff.Config{ArrayKeys: tc.arrayKeys})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var patch, out, expected strings.Builder
+
+			if err := diff.WritePatch(&patch, changes); err != nil {
+				t.Fatal(err)
+			}
+
+			p, err := Read(strings.NewReader(patch.String()))
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := p.Apply(strings.NewReader(tc.left), &out); err != nil {
+				t.Fatal(err)
+			}
+			if err := (Patch{}).Apply(strings.NewReader(tc.right), &expected); err != nil {
+				t.Fatal(err)
+			}
+
+			if out.String() != expected.String() {
+				t.Errorf("Expected '%v', got '%v' instead\n", expected.String(), out.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,254 @@
+package patch
+
+import (
+	"fmt"
+	"io"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/rodic/jmatch"
+	"github.com/rodic/jmatch/format"
+	"github.com/rodic/jmatch/paths"
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+type kind int
+
+const (
+	scalar kind = iota
+	object
+	array
+)
+
+// value is a part of a document held in memory. Objects keep the order of
+// their keys.
+type value struct {
+	kind    kind
+	token   t.Token // of scalars
+	members []member
+	elems   []*value
+}
+
+type member struct {
+	key   string
+	value *value
+}
+
+func (v *value) isNull() bool {
+	return v.kind == scalar && v.token.IsNull()
+}
+
+func (v *value) member(key string) (int, bool) {
+	for i, m := range v.members {
+		if m.key == key {
+			return i, true
+		}
+	}
+	return len(v.members), false
+}
+
+// set adds the member, or replaces its value if it is there.
+func (v *value) set(key string, child *value) {
+	if i, ok := v.member(key); ok {
+		v.members[i].value = child
+		return
+	}
+	v.members = append(v.members, member{key: key, value: child})
+}
+
+func (v *value) copy() *value {
+	c := &value{kind: v.kind, token: v.token}
+
+	for _, m := range v.members {
+		c.members = append(c.members, member{key: m.key, value: m.value.copy()})
+	}
+	for _, e := range v.elems {
+		c.elems = append(c.elems, e.copy())
+	}
+	return c
+}
+
+// equal compares values the way the test operation does: numbers by
+// value and objects whatever the order of their keys.
+func equal(a *value, b *value) bool {
+	if a.kind != b.kind {
+		return false
+	}
+
+	switch a.kind {
+	case object:
+		if len(a.members) != len(b.members) {
+			return false
+		}
+		for _, m := range a.members {
+			i, ok := b.member(m.key)
+
+			if !ok || !equal(m.value, b.members[i].value) {
+				return false
+			}
+		}
+		return true
+	case array:
+		if len(a.elems) != len(b.elems) {
+			return false
+		}
+		for i := range a.elems {
+			if !equal(a.elems[i], b.elems[i]) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if a.token.IsNumber() && b.token.IsNumber() {
+		x, okX := new(big.Rat).SetString(a.token.Value)
+		y, okY := new(big.Rat).SetString(b.token.Value)
+
+		if okX && okY {
+			return x.Cmp(y) == 0
+		}
+	}
+	return format.Literal(a.token) == format.Literal(b.token)
+}
+
+func (v *value) encode(json *strings.Builder) {
+	switch v.kind {
+	case object:
+		json.WriteRune('{')
+
+		for i, m := range v.members {
+			if i > 0 {
+				json.WriteRune(',')
+			}
+			json.WriteString(paths.Quote(m.key))
+			json.WriteRune(':')
+			m.value.encode(json)
+		}
+
+		json.WriteRune('}')
+	case array:
+		json.WriteRune('[')
+
+		for i, e := range v.elems {
+			if i > 0 {
+				json.WriteRune(',')
+			}
+			e.encode(json)
+		}
+
+		json.WriteRune(']')
+	default:
+		json.WriteString(format.Literal(v.token))
+	}
+}
+
+func (v *value) String() string {
+	var json strings.Builder
+	v.encode(&json)
+	return json.String()
+}
+
+// builder puts together the value made of the tokens passed to it, as
+// jmatch.Match passes them with jmatch.WithContainers.
+type builder struct {
+	root  *value
+	stack []*value
+	keys  []string // of the values in objects, by depth
+}
+
+// add adds the token, found under the key in an object. It tells whether
+// the value is complete.
+func (b *builder) add(key string, token t.Token) bool {
+	if token.IsRightBrace() || token.IsRightBracket() {
+		b.stack = b.stack[:len(b.stack)-1]
+		return len(b.stack) == 0
+	}
+
+	v := &value{kind: scalar, token: token}
+
+	switch {
+	case token.IsLeftBrace():
+		v = &value{kind: object}
+	case token.IsLeftBracket():
+		v = &value{kind: array}
+	}
+
+	if len(b.stack) == 0 {
+		b.root = v
+	} else if parent := b.stack[len(b.stack)-1]; parent.kind == object {
+		parent.members = append(parent.members, member{key: key, value: v})
+	} else {
+		parent.elems = append(parent.elems, v)
+	}
+
+	if v.kind != scalar {
+		b.stack = append(b.stack, v)
+		return false
+	}
+	return len(b.stack) == 0
+}
+
+// read reads the JSON text into memory.
+func read(r io.Reader) (*value, error) {
+	var b builder
+	var err error
+
+	matchErr := jmatch.Match(r, func(path string, token t.Token) {
+		if err != nil {
+			return
+		}
+
+		var key string
+		key, err = lastKey(path)
+
+		if err == nil {
+			b.add(key, token)
+		}
+	}, jmatch.WithContainers())
+
+	if matchErr != nil {
+		return nil, matchErr
+	}
+	if err != nil {
+		return nil, err
+	}
+	if b.root == nil {
+		return nil, fmt.Errorf("no JSON value found")
+	}
+	return b.root, nil
+}
+
+func parse(json string) (*value, error) {
+	return read(strings.NewReader(json))
+}
+
+// lastKey returns the last segment of the jq path as a pointer token.
+func lastKey(path string) (string, error) {
+	tokens, err := tokens(path)
+
+	if err != nil || len(tokens) == 0 {
+		return "", err
+	}
+	return tokens[len(tokens)-1], nil
+}
+
+// tokens turns a jq path into the reference tokens of a JSON Pointer.
+func tokens(path string) ([]string, error) {
+	segments, err := paths.Split(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := make([]string, len(segments))
+
+	for i, s := range segments {
+		if s.IsIndex() {
+			tokens[i] = strconv.Itoa(s.Index)
+		} else {
+			tokens[i] = s.Key
+		}
+	}
+	return tokens, nil
+}
@@ -0,0 +1,305 @@
+// Package paths reads and writes paths in the jq notation used by jmatch:
+// .a."b c"[0], with . standing for the root.
+package paths
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+type segmentKind int
+
+const (
+	key segmentKind = iota
+	index
+	anyKey   // .* in patterns
+	anyIndex // [*] or [] in patterns
+	anyDepth // .. in patterns
+)
+
+// Segment is a step of a path, either an object key or an array index.
+type Segment struct {
+	kind  segmentKind
+	Key   string
+	Index int
+}
+
+func KeySegment(k string) Segment {
+	return Segment{kind: key, Key: k}
+}
+
+func IndexSegment(i int) Segment {
+	return Segment{kind: index, Index: i}
+}
+
+func (s Segment) IsKey() bool {
+	return s.kind == key
+}
+
+func (s Segment) IsIndex() bool {
+	return s.kind == index
+}
+
+func (s Segment) String() string {
+	switch s.kind {
+	case key:
+		return Key(s.Key)
+	case index:
+		return Index(s.Index)
+	case anyKey:
+		return ".*"
+	case anyIndex:
+		return "[*]"
+	default:
+		return ".."
+	}
+}
+
+// Key writes the path segment of an object key. Keys that are not plain
+// identifiers are quoted, so that the path can be pasted into jq.
+func Key(k string) string {
+	return string(AppendKey(nil, k))
+}
+
+// AppendKey appends the path segment of an object key, as Key writes it,
+// to dst.
+func AppendKey(dst []byte, k string) []byte {
+	dst = append(dst, '.')
+
+	if IsIdentifier(k) {
+		return append(dst, k...)
+	}
+	return AppendQuote(dst, k)
+}
+
+// Index writes the path segment of an array element.
+func Index(i int) string {
+	return string(AppendIndex(nil, i))
+}
+
+// AppendIndex appends the path segment of an array element, as Index
+// writes it, to dst.
+func AppendIndex(dst []byte, i int) []byte {
+	dst = append(dst, '[')
+	dst = strconv.AppendInt(dst, int64(i), 10)
+	return append(dst, ']')
+}
+
+// Join writes the segments as a path.
+func Join(segments []Segment) string {
+	if len(segments) == 0 {
+		return "."
+	}
+
+	var path strings.Builder
+
+	if kind := segments[0].kind; kind == index || kind == anyIndex {
+		path.WriteRune('.') // .[0] as jq has it
+	}
+
+	for _, s := range segments {
+		path.WriteString(s.String())
+	}
+	return path.String()
+}
+
+// Collapse writes the path with its indexes left out, as .a[].b, so that
+// the paths of the elements of an array come out the same. The result is
+// a pattern matching all of them.
+func Collapse(path string) (string, error) {
+	segments, err := Split(path)
+
+	if err != nil {
+		return "", err
+	}
+	if len(segments) == 0 {
+		return ".", nil
+	}
+
+	var collapsed strings.Builder
+
+	if segments[0].IsIndex() {
+		collapsed.WriteRune('.')
+	}
+
+	for _, s := range segments {
+		if s.IsIndex() {
+			collapsed.WriteString("[]")
+		} else {
+			collapsed.WriteString(s.String())
+		}
+	}
+	return collapsed.String(), nil
+}
+
+// Split reads a path written by jmatch back into its segments.
+func Split(path string) ([]Segment, error) {
+	return parse(path, false)
+}
+
+// IsIdentifier tells whether the key can be written without quotes.
+func IsIdentifier(k string) bool {
+	if k == "" {
+		return false
+	}
+	for i, r := range k {
+		if r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (i > 0 && r >= '0' && r <= '9') {
+			continue
+		}
+		return false
+	}
+	return true
+}
+
+// Quote writes the string as a JSON string literal.
+func Quote(s string) string {
+	return string(AppendQuote(nil, s))
+}
+
+// AppendQuote appends the string, as Quote writes it, to dst.
+func AppendQuote(dst []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+
+	dst = append(dst, '"')
+
+	for _, r := range s {
+		switch r {
+		case '"':
+			dst = append(dst, `\"`...)
+		case '\\':
+			dst = append(dst, `\\`...)
+		case '\n':
+			dst = append(dst, `\n`...)
+		case '\r':
+			dst = append(dst, `\r`...)
+		case '\t':
+			dst = append(dst, `\t`...)
+		case '\b':
+			dst = append(dst, `\b`...)
+		case '\f':
+			dst = append(dst, `\f`...)
+		default:
+			if r < 0x20 || r == utf8.RuneError {
+				dst = append(dst, '\\', 'u', hex[r>>12&0xf], hex[r>>8&0xf], hex[r>>4&0xf], hex[r&0xf])
+			} else {
+				dst = utf8.AppendRune(dst, r)
+			}
+		}
+	}
+
+	return append(dst, '"')
+}
+
+// parse reads a path, or a pattern if wildcards are allowed.
+func parse(path string, wildcards bool) ([]Segment, error) {
+	if path == "." {
+		return []Segment{}, nil
+	}
+
+	segments := []Segment{}
+	i := 0
+
+	fail := func(expected string) error {
+		if i >= len(path) {
+			return fmt.Errorf("invalid path %q: unexpected end, expected %s", path, expected)
+		}
+		return fmt.Errorf("invalid path %q: unexpected %q at %d, expected %s", path, path[i], i, expected)
+	}
+
+	for i < len(path) {
+		switch {
+		case strings.HasPrefix(path[i:], ".."):
+			if !wildcards {
+				return nil, fail("key")
+			}
+			segments = append(segments, Segment{kind: anyDepth})
+			i += 2
+
+			// ..name is short for .. followed by .name
+			if i < len(path) && path[i] != '.' && path[i] != '[' {
+				i--
+			}
+		case path[i] == '.':
+			i++
+
+			if i < len(path) && path[i] == '[' {
+				continue // .[0] is the same as [0]
+			}
+			if i < len(path) && path[i] == '"' {
+				k, n, err := Unquote(path[i:])
+				if err != nil {
+					return nil, fmt.Errorf("invalid path %q: %w", path, err)
+				}
+				segments = append(segments, KeySegment(k))
+				i += n
+			} else if wildcards && i < len(path) && path[i] == '*' {
+				segments = append(segments, Segment{kind: anyKey})
+				i++
+			} else {
+				start := i
+				for i < len(path) && path[i] != '.' && path[i] != '[' {
+					i++
+				}
+				if !IsIdentifier(path[start:i]) {
+					i = start
+					return nil, fail("key")
+				}
+				segments = append(segments, KeySegment(path[start:i]))
+			}
+		case path[i] == '[':
+			i++
+			end := strings.IndexByte(path[i:], ']')
+
+			if end < 0 {
+				i = len(path)
+				return nil, fail("]")
+			}
+
+			inside := path[i : i+end]
+
+			if wildcards && (inside == "" || inside == "*") {
+				segments = append(segments, Segment{kind: anyIndex})
+			} else if n, err := strconv.Atoi(inside); err == nil && n >= 0 && inside[0] != '+' {
+				segments = append(segments, IndexSegment(n))
+			} else {
+				return nil, fail("index")
+			}
+			i += end + 1
+		default:
+			return nil, fail(". or [")
+		}
+	}
+
+	return segments, nil
+}
+
+// Unquote reads the JSON string literal at the start of s and returns it
+// along with its length in s. Escapes are decoded by the tokenizer, as in
+// the input, so that Unquote reads back what Quote writes.
+func Unquote(s string) (string, int, error) {
+	escaped := false
+
+	for i := 1; i < len(s); i++ {
+		if escaped {
+			escaped = false
+			continue
+		}
+		switch s[i] {
+		case '\\':
+			escaped = true
+		case '"':
+			scanner := t.NewScanner(strings.NewReader(s[:i+1]), t.Config{Strict: true})
+			token, err := scanner.Scan()
+
+			if err != nil || !token.IsString() {
+				return "", 0, fmt.Errorf("invalid key %s", s[:i+1])
+			}
+			return string(token.Bytes), i + 1, nil
+		}
+	}
+	return "", 0, fmt.Errorf("unterminated key %s", s)
+}
@@ -0,0 +1,275 @@
+package paths
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	testCases := []struct {
+		path     string
+		expected []Segment
+	}{
+		{path: ".", expected: []Segment{}},
+		{path: ".a", expected: []Segment{KeySegment("a")}},
+		{path: ".[0]", expected: []Segment{IndexSegment(0)}},
+		{path: ".a[12].b_2", expected: []Segment{KeySegment("a"), IndexSegment(12), KeySegment("b_2")}},
+		{path: `."a b"."x\"y"[1]`, expected: []Segment{KeySegment("a b"), KeySegment(`x"y`), IndexSegment(1)}},
+		{path: `.""`, expected: []Segment{KeySegment("")}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			segments, err := Split(tc.path)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(segments, tc.expected) {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, segments)
+			}
+			if path := Join(segments); path != tc.path {
+				t.Errorf("Expected '%s' joined back, got '%s' instead\n", tc.path, path)
+			}
+		})
+	}
+
+	for _, path := range []string{"a", ".a-b", ".[x]", ".[-1]", ".[1", `."a`, ".a..b", ".*"} {
+		if _, err := Split(path); err == nil {
+			t.Errorf("Expected an error for '%s'", path)
+		}
+	}
+}
+
+func TestCollapse(t *testing.T) {
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{path: ".", expected: "."},
+		{path: ".a", expected: ".a"},
+		{path: ".[3]", expected: ".[]"},
+		{path: `.a[12]."b c"[0][1]`, expected: `.a[]."b c"[][]`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			collapsed, err := Collapse(tc.path)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if collapsed != tc.expected {
+				t.Errorf("Expected '%s', got '%s' instead\n", tc.expected, collapsed)
+			}
+			if !MustCompile(collapsed).Match(tc.path) {
+				t.Errorf("Expected '%s' to match '%s'\n", collapsed, tc.path)
+			}
+		})
+	}
+
+	if _, err := Collapse(".a-b"); err == nil {
+		t.Error("Expected an error for an invalid path")
+	}
+}
+
+func TestPointer(t *testing.T) {
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{path: ".", expected: ""},
+		{path: ".[0]", expected: "/0"},
+		{path: `.a."b/c"."d~e"[2]`, expected: "/a/b~1c/d~0e/2"},
+		{path: `.""`, expected: "/"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			segments, err := Split(tc.path)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if pointer := Pointer(segments); pointer != tc.expected {
+				t.Errorf("Expected '%s', got '%s' instead\n", tc.expected, pointer)
+			}
+		})
+	}
+}
+
+func TestSplitPointer(t *testing.T) {
+	testCases := []struct {
+		pointer  string
+		expected []string
+	}{
+		{pointer: "", expected: []string{}},
+		{pointer: "/", expected: []string{""}},
+		{pointer: "/a/0/-", expected: []string{"a", "0", "-"}},
+		{pointer: "/b~1c/d~0e/~01", expected: []string{"b/c", "d~e", "~1"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pointer, func(t *testing.T) {
+			tokens, err := SplitPointer(tc.pointer)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(tokens, tc.expected) {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, tokens)
+			}
+		})
+	}
+
+	for _, pointer := range []string{"a", "/a~2", "/a~"} {
+		if _, err := SplitPointer(pointer); err == nil {
+			t.Errorf("Expected an error for %q\n", pointer)
+		}
+	}
+}
+
+func TestKey(t *testing.T) {
+	testCases := []struct {
+		key      string
+		expected string
+	}{
+		{key: "a", expected: ".a"},
+		{key: "_a1", expected: "._a1"},
+		{key: "1a", expected: `."1a"`},
+		{key: "a-b", expected: `."a-b"`},
+		{key: "a.b c", expected: `."a.b c"`},
+		{key: "ü", expected: `."ü"`},
+		{key: "a\"\\\n\x01", expected: `."a\"\\\n\u0001"`},
+	}
+
+	for _, tc := range testCases {
+		if key := Key(tc.key); key != tc.expected {
+			t.Errorf("Expected '%s', got '%s' instead\n", tc.expected, key)
+		}
+	}
+}
+
+func TestUnquote(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+		length   int
+	}{
+		{input: `"a"`, expected: "a", length: 3},
+		{input: `"a\/b".c`, expected: "a/b", length: 6},
+		{input: `"\u00fc"`, expected: "ü", length: 8},
+		{input: `"\ud83d\ude00"[0]`, expected: "\U0001F600", length: 14},
+		{input: Quote("a\"\\\n\x01\ufffd"), expected: "a\"\\\n\x01\ufffd", length: 21},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			key, n, err := Unquote(tc.input)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if key != tc.expected || n != tc.length {
+				t.Errorf("Expected '%s' of length %d, got '%s' of length %d instead\n", tc.expected, tc.length, key, n)
+			}
+		})
+	}
+
+	for _, input := range []string{`"\x41"`, `"\a"`, `"\U0001F600"`, `"\'"`, "\"a\tb\"", `"a`} {
+		if _, _, err := Unquote(input); err == nil {
+			t.Errorf("Expected an error for '%s'", input)
+		}
+	}
+}
+
+func TestPatternMatch(t *testing.T) {
+	testCases := []struct {
+		pattern  string
+		path     string
+		expected bool
+	}{
+		{pattern: ".a", path: ".a", expected: true},
+		{pattern: ".a", path: ".a.b", expected: false},
+		{pattern: ".a.*", path: ".a.b", expected: true},
+		{pattern: ".a.*", path: ".a[0]", expected: false},
+		{pattern: ".a[*]", path: ".a[3]", expected: true},
+		{pattern: ".a[]", path: ".a[3]", expected: true},
+		{pattern: ".a[2]", path: ".a[3]", expected: false},
+		{pattern: "..id", path: ".id", expected: true},
+		{pattern: "..id", path: ".users[0].id", expected: true},
+		{pattern: "..id", path: ".users[0].uid", expected: false},
+		{pattern: ".users..", path: ".users[0].name.first", expected: true},
+		{pattern: ".users..", path: ".groups[0]", expected: false},
+		{pattern: ".a..[*].b", path: ".a.x[1].b", expected: true},
+		{pattern: `."a b"`, path: `."a b"`, expected: true},
+		{pattern: ".", path: ".", expected: true},
+		{pattern: ".", path: ".a", expected: false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.pattern+" "+tc.path, func(t *testing.T) {
+			if matched := MustCompile(tc.pattern).Match(tc.path); matched != tc.expected {
+				t.Errorf("Expected %v, got %v instead\n", tc.expected, matched)
+			}
+		})
+	}
+
+	for _, pattern := range []string{"a", ".[x]", ".a*"} {
+		if _, err := Compile(pattern); err == nil {
+			t.Errorf("Expected an error for '%s'", pattern)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	patterns := []string{".a", ".a.*", "..id", ".users[*].id", ".users..", ".", ".a", "..", ".x....y"}
+
+	var set Set
+
+	for i, pattern := range patterns {
+		if id := set.Add(MustCompile(pattern)); id != i {
+			t.Errorf("Expected '%v', got '%v' instead\n", i, id)
+		}
+	}
+
+	testCases := []struct {
+		path     string
+		expected []int
+	}{
+		{path: ".", expected: []int{5, 7}},
+		{path: ".a", expected: []int{0, 6, 7}},
+		{path: ".a.b", expected: []int{1, 7}},
+		{path: ".a[0]", expected: []int{7}},
+		{path: ".id", expected: []int{2, 7}},
+		{path: ".users[2].id", expected: []int{2, 3, 4, 7}},
+		{path: ".users", expected: []int{4, 7}},
+		{path: ".x.a.b.y", expected: []int{7, 8}},
+		{path: ".x.y", expected: []int{7, 8}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.path, func(t *testing.T) {
+			matched := set.Match(tc.path)
+
+			// every pattern in the set, one at a time
+			var expected []int
+
+			for i, pattern := range patterns {
+				if MustCompile(pattern).Match(tc.path) {
+					expected = append(expected, i)
+				}
+			}
+
+			if !reflect.DeepEqual(matched, tc.expected) || !reflect.DeepEqual(expected, tc.expected) {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, matched)
+			}
+		})
+	}
+
+	var empty Set
+
+	if matched := empty.Match(".a"); len(matched) != 0 {
+		t.Errorf("Expected no match, got '%v' instead\n", matched)
+	}
+}
@@ -0,0 +1,89 @@
+package paths
+
+// Pattern is a path that may hold wildcards:
+//
+//	.*     any key
+//	[*]    any index, [] works too
+//	..     any number of keys and indexes, none included
+//
+// A pattern matches whole paths only, so .a matches .a but not .a.b, which
+// is matched by .a.* and .a.. instead.
+type Pattern struct {
+	segments []Segment
+}
+
+// Compile reads the pattern.
+func Compile(pattern string) (Pattern, error) {
+	segments, err := parse(pattern, true)
+
+	if err != nil {
+		return Pattern{}, err
+	}
+	return Pattern{segments: segments}, nil
+}
+
+// MustCompile is like Compile but panics if the pattern is invalid.
+func MustCompile(pattern string) Pattern {
+	p, err := Compile(pattern)
+
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+func (p Pattern) String() string {
+	return Join(p.segments)
+}
+
+// Match tells whether the path, as written by jmatch, matches the pattern.
+// Paths that can't be read match nothing.
+func (p Pattern) Match(path string) bool {
+	segments, err := Split(path)
+
+	if err != nil {
+		return false
+	}
+	return p.MatchSegments(segments)
+}
+
+// MatchSegments tells whether the path split into segments matches the
+// pattern.
+func (p Pattern) MatchSegments(segments []Segment) bool {
+	return match(p.segments, segments)
+}
+
+func match(pattern []Segment, path []Segment) bool {
+	for len(pattern) > 0 {
+		head := pattern[0]
+
+		if head.kind == anyDepth {
+			for skip := 0; skip <= len(path); skip++ {
+				if match(pattern[1:], path[skip:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(path) == 0 || !matchSegment(head, path[0]) {
+			return false
+		}
+
+		pattern, path = pattern[1:], path[1:]
+	}
+	return len(path) == 0
+}
+
+func matchSegment(pattern Segment, segment Segment) bool {
+	switch pattern.kind {
+	case anyKey:
+		return segment.kind == key
+	case anyIndex:
+		return segment.kind == index
+	case key:
+		return segment.kind == key && segment.Key == pattern.Key
+	default:
+		return segment.kind == index && segment.Index == pattern.Index
+	}
+}
@@ -0,0 +1,53 @@
+package paths
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	pointerEscaper   = strings.NewReplacer("~", "~0", "/", "~1")
+	pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+)
+
+// Pointer writes the segments as an RFC 6901 JSON Pointer, such as
+// /a/b~1c/0. The root is the empty pointer.
+func Pointer(segments []Segment) string {
+	var pointer strings.Builder
+
+	for _, s := range segments {
+		pointer.WriteRune('/')
+
+		if s.IsIndex() {
+			pointer.WriteString(strconv.Itoa(s.Index))
+		} else {
+			pointer.WriteString(pointerEscaper.Replace(s.Key))
+		}
+	}
+	return pointer.String()
+}
+
+// SplitPointer reads an RFC 6901 JSON Pointer into its reference tokens,
+// unescaped. Whether a token is a key or an index depends on the value it
+// is applied to, so all are returned as strings.
+func SplitPointer(pointer string) ([]string, error) {
+	if pointer == "" {
+		return []string{}, nil
+	}
+	if pointer[0] != '/' {
+		return nil, fmt.Errorf("invalid pointer %q: expected / at 0", pointer)
+	}
+
+	tokens := strings.Split(pointer[1:], "/")
+
+	for i, token := range tokens {
+		for j := 0; j < len(token); j++ {
+			if token[j] == '~' && (j+1 == len(token) || token[j+1] != '0' && token[j+1] != '1') {
+				return nil, fmt.Errorf("invalid pointer %q: invalid escape in %q", pointer, token)
+			}
+		}
+		tokens[i] = pointerUnescaper.Replace(token)
+	}
+	return tokens, nil
+}
@@ -0,0 +1,162 @@
+package paths
+
+import "sort"
+
+// Set matches a path against many patterns at once. The patterns are kept
+// in a trie, so that matching takes time in the depth of the path rather
+// than in the number of patterns; only .. makes it try more than one
+// branch of the trie.
+type Set struct {
+	root  node
+	count int
+}
+
+type node struct {
+	keys     map[string]*node
+	indexes  map[int]*node
+	anyKey   *node
+	anyIndex *node
+	anyDepth *node // matching any number of segments, looping on itself
+
+	patterns []int // ending here
+}
+
+// Add adds the pattern to the set and returns its number, counted from 0
+// in the order patterns are added.
+func (s *Set) Add(p Pattern) int {
+	n := &s.root
+
+	for _, segment := range p.segments {
+		n = n.child(segment)
+	}
+
+	id := s.count
+	s.count++
+	n.patterns = append(n.patterns, id)
+	return id
+}
+
+// Len is the number of patterns added.
+func (s *Set) Len() int {
+	return s.count
+}
+
+func (n *node) child(segment Segment) *node {
+	var next **node
+
+	switch segment.kind {
+	case key:
+		if n.keys == nil {
+			n.keys = map[string]*node{}
+		}
+		if child, ok := n.keys[segment.Key]; ok {
+			return child
+		}
+		child := &node{}
+		n.keys[segment.Key] = child
+		return child
+	case index:
+		if n.indexes == nil {
+			n.indexes = map[int]*node{}
+		}
+		if child, ok := n.indexes[segment.Index]; ok {
+			return child
+		}
+		child := &node{}
+		n.indexes[segment.Index] = child
+		return child
+	case anyKey:
+		next = &n.anyKey
+	case anyIndex:
+		next = &n.anyIndex
+	default:
+		if n == n.anyDepth {
+			return n // .... is the same as ..
+		}
+		next = &n.anyDepth
+	}
+
+	if *next == nil {
+		*next = &node{}
+
+		if segment.kind == anyDepth {
+			(*next).anyDepth = *next
+		}
+	}
+	return *next
+}
+
+// Match returns the numbers of the patterns matching the path, as written
+// by jmatch, in increasing order. Paths that can't be read match nothing.
+func (s *Set) Match(path string) []int {
+	segments, err := Split(path)
+
+	if err != nil {
+		return nil
+	}
+	return s.MatchSegments(segments)
+}
+
+// MatchSegments returns the numbers of the patterns matching the path
+// split into segments, in increasing order.
+func (s *Set) MatchSegments(segments []Segment) []int {
+	states := reach(nil, &s.root)
+
+	for _, segment := range segments {
+		var next []*node
+
+		for _, n := range states {
+			switch segment.kind {
+			case key:
+				if child, ok := n.keys[segment.Key]; ok {
+					next = reach(next, child)
+				}
+				if n.anyKey != nil {
+					next = reach(next, n.anyKey)
+				}
+			case index:
+				if child, ok := n.indexes[segment.Index]; ok {
+					next = reach(next, child)
+				}
+				if n.anyIndex != nil {
+					next = reach(next, n.anyIndex)
+				}
+			}
+			if n.anyDepth == n {
+				next = reach(next, n)
+			}
+		}
+
+		if len(next) == 0 {
+			return nil
+		}
+		states = next
+	}
+
+	var matched []int
+
+	for _, n := range states {
+		matched = append(matched, n.patterns...)
+	}
+	if len(states) > 1 {
+		sort.Ints(matched)
+	}
+	return matched
+}
+
+// reach adds the node to the states, along with the node of the .. that
+// follows it, which matches no segment too.
+func reach(states []*node, n *node) []*node {
+	for _, s := range states {
+		if s == n {
+			return states
+		}
+	}
+
+	states = append(states, n)
+
+	if n.anyDepth != nil && n.anyDepth != n {
+		states = reach(states, n.anyDepth)
+	}
+	return states
+}
@@ -0,0 +1,153 @@
+package jmatch
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/rodic/jmatch/format"
+	p "github.com/rodic/jmatch/parser"
+	"github.com/rodic/jmatch/paths"
+)
+
+// projection is a container being read by Project.
+type projection struct {
+	segment paths.Segment // of the container in its parent
+	object  bool
+	kept    bool // as a whole, matched by a pattern
+	written bool // opened in the output
+	count   int  // of the values written in it
+}
+
+// Project writes the part of the JSON read found at paths matching the
+// patterns, the way paths.Compile reads them. Objects and arrays matched
+// are kept whole. The objects and arrays around the values kept are
+// written too, so the output has the nesting of the input:
+//
+//	jmatch.Project(r, w, ".name", ".address.city", ".tags[0]")
+//
+// turns {"name": "x", "age": 3, "tags": ["a", "b"], "address": {"city":
+// "y", "zip": 1}} into {"name":"x","tags":["a"],"address":{"city":"y"}}.
+// Arrays are compacted, the elements kept being renumbered from 0. The
+// output is compact JSON on a single line, holding the root object or
+// array even if nothing in it is kept, and null for a root value that is
+// not a container and is not matched.
+func Project(reader io.Reader, writer io.Writer, patterns ...string) error {
+	compiled := make([]paths.Pattern, len(patterns))
+
+	for i, pattern := range patterns {
+		var err error
+
+		if compiled[i], err = paths.Compile(pattern); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+	}
+
+	var config config
+	config.parser.EmitContainers = true
+	config.parser.LazyPaths = true
+
+	out := bufio.NewWriter(writer)
+
+	var stack []*projection
+
+	// path holds the segments of the value read, those of the containers
+	// on the stack below the root first, so that paths are matched without
+	// being written out
+	var path []paths.Segment
+
+	matches := func(result p.ParsingResult) (paths.Segment, bool) {
+		segment, ok := result.Lazy.Last()
+
+		if ok {
+			path = append(path[:len(stack)-1], segment)
+		} else {
+			path = path[:0]
+		}
+
+		for _, pattern := range compiled {
+			if pattern.MatchSegments(path) {
+				return segment, true
+			}
+		}
+		return segment, false
+	}
+
+	// open writes the containers around a value kept not written yet, the
+	// comma and the key of the value included.
+	open := func(segment paths.Segment) {
+		for i, s := range stack {
+			if !s.written {
+				if i > 0 {
+					writeMember(out, stack[i-1], s.segment)
+				}
+				if s.object {
+					out.WriteRune('{')
+				} else {
+					out.WriteRune('[')
+				}
+				s.written = true
+			}
+		}
+		if len(stack) > 0 {
+			writeMember(out, stack[len(stack)-1], segment)
+		}
+	}
+
+	err := match(reader, func(result p.ParsingResult) {
+		token := result.Token
+
+		switch {
+		case token.IsLeftBrace() || token.IsLeftBracket():
+			segment, matched := matches(result)
+			kept := matched || len(stack) > 0 && stack[len(stack)-1].kept
+
+			if kept || len(stack) == 0 {
+				open(segment)
+				out.WriteString(token.Value)
+			}
+
+			stack = append(stack, &projection{
+				segment: segment,
+				object:  token.IsLeftBrace(),
+				kept:    kept,
+				written: kept || len(stack) == 0,
+			})
+		case token.IsRightBrace() || token.IsRightBracket():
+			if stack[len(stack)-1].written {
+				out.WriteString(token.Value)
+			}
+			stack = stack[:len(stack)-1]
+		default:
+			segment, matched := matches(result)
+
+			if matched || len(stack) > 0 && stack[len(stack)-1].kept {
+				open(segment)
+				out.WriteString(format.Literal(token))
+			} else if len(stack) == 0 {
+				out.WriteString("null")
+			}
+		}
+	}, config)
+
+	if err != nil {
+		return err
+	}
+
+	out.WriteRune('\n')
+	return out.Flush()
+}
+
+// writeMember writes the comma before a value written in the container
+// and, in objects, its key.
+func writeMember(out *bufio.Writer, container *projection, segment paths.Segment) {
+	if container.count > 0 {
+		out.WriteRune(',')
+	}
+	container.count++
+
+	if container.object {
+		out.WriteString(paths.Quote(segment.Key))
+		out.WriteRune(':')
+	}
+}
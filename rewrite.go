@@ -0,0 +1,374 @@
+package jmatch
+
+import (
+	"bufio"
+	"io"
+	"sync"
+	"unicode/utf8"
+
+	p "github.com/rodic/jmatch/parser"
+	"github.com/rodic/jmatch/paths"
+)
+
+// Action is what Rewrite does with a value.
+type Action struct {
+	remove  bool
+	literal string // put in place of the value, if set
+}
+
+// Keep leaves the value as it is.
+var Keep = Action{}
+
+// Remove takes the value out, along with its key in objects. A root value
+// that is not an object or array is replaced by null instead.
+var Remove = Action{remove: true}
+
+// Replace puts the JSON text given in place of the value. The text is
+// written as it is, so it must be valid JSON.
+func Replace(literal string) Action {
+	return Action{literal: literal}
+}
+
+// ReplaceString puts the string given in place of the value.
+func ReplaceString(s string) Action {
+	return Replace(paths.Quote(s))
+}
+
+// Rewriter tells what to do with a value.
+type Rewriter func(path string, token Token) Action
+
+// Rewrite copies the JSON read to the writer with the values rewritten as
+// the rewriter tells, objects and arrays left out. The rest of the input
+// is copied as it is, formatting included, unless WithCompactOutput is
+// given. The output is written as the input is read, whether values are
+// rewritten or not, and only the last few tens of kilobytes of the input
+// are kept in memory, so arbitrarily large documents can be rewritten:
+//
+//	err := jmatch.Rewrite(r, w, func(path string, token jmatch.Token) jmatch.Action {
+//		if pattern.Match(path) {
+//			return jmatch.ReplaceString("***")
+//		}
+//		return jmatch.Keep
+//	})
+//
+// On error, the output written so far is left as it is.
+func Rewrite(reader io.Reader, writer io.Writer, rewriter Rewriter, opts ...Option) error {
+	config := newConfig(opts)
+	input := &recorder{reader: reader}
+
+	out := bufio.NewWriter(writer)
+	var output io.Writer = out
+
+	if config.compact {
+		output = &compactWriter{w: out}
+	}
+
+	rw := &rewrite{input: input, output: output, line: 1}
+
+	err := match(input, func(result p.ParsingResult) {
+		if result.Token.IsContainer() || rw.err != nil {
+			return
+		}
+
+		action := rewriter(result.Path, result.Token)
+
+		if action.remove || action.literal != "" {
+			rw.edit(result.Token, action)
+		} else {
+			rw.keep(result.Token)
+		}
+	}, config)
+
+	if err == nil {
+		err = rw.err
+	}
+	if err == nil {
+		input.mutex.Lock()
+		rw.flush(input.offset + len(input.buffer))
+		input.mutex.Unlock()
+		err = rw.err
+	}
+	if flushErr := out.Flush(); err == nil {
+		err = flushErr
+	}
+	return err
+}
+
+// recorder keeps what is read from the reader until Rewrite is done with
+// it. It is filled by the parsing goroutine, which scans the input, and
+// read by the matcher, hence the mutex.
+type recorder struct {
+	reader io.Reader
+	mutex  sync.Mutex
+	buffer []byte
+	offset int // of the first byte in buffer, in the input
+}
+
+func (r *recorder) Read(b []byte) (int, error) {
+	n, err := r.reader.Read(b)
+
+	r.mutex.Lock()
+	r.buffer = append(r.buffer, b[:n]...)
+	r.mutex.Unlock()
+
+	return n, err
+}
+
+// discardAt is how much of the input is kept before it is written out and
+// forgotten.
+const discardAt = 64 * 1024
+
+// discardBefore forgets the input before the offset once enough of it
+// piles up.
+func (r *recorder) discardBefore(offset int) {
+	if cut := offset - r.offset; cut >= discardAt && cut >= len(r.buffer)/2 {
+		r.buffer = append(r.buffer[:0], r.buffer[cut:]...)
+		r.offset = offset
+	}
+}
+
+// rewrite writes the input with the edits made so far. Offsets are counted
+// from the start of the input.
+type rewrite struct {
+	input  *recorder
+	output io.Writer
+	err    error
+
+	written int // the input before it is written or edited out
+
+	// the position of the tokenizer at the scanned offset, the line and
+	// the runes read on it
+	scanned int
+	line    int
+	column  int
+
+	// a value was removed at the start of an object or array, so the
+	// comma after it has to go too
+	dropComma bool
+}
+
+func (rw *rewrite) edit(token Token, action Action) {
+	rw.input.mutex.Lock()
+	defer rw.input.mutex.Unlock()
+
+	start := rw.find(token.Line, token.Column)
+	end := rw.lexemeEnd(start)
+
+	if !action.remove {
+		rw.flush(start)
+		rw.write(action.literal)
+	} else {
+		rw.remove(start)
+	}
+
+	rw.skipTo(end)
+	rw.written = end
+	rw.input.discardBefore(end)
+}
+
+// keep writes the input up to the value left as it is once enough of it
+// piles up, so that the input is not held in memory until a value is
+// edited. The value itself is left for the next flush, a value removed
+// next looking back no further than its key and the comma before it.
+func (rw *rewrite) keep(token Token) {
+	rw.input.mutex.Lock()
+	defer rw.input.mutex.Unlock()
+
+	if len(rw.input.buffer) < discardAt {
+		return
+	}
+
+	start := rw.find(token.Line, token.Column)
+
+	rw.flush(start)
+	rw.input.discardBefore(start)
+}
+
+// remove writes the input up to the value starting at the offset and
+// drops the value along with its key and the comma before it. The first
+// value of a container leaves the comma after it to the next flush.
+func (rw *rewrite) remove(start int) {
+	first := start
+	before := rw.previous(start)
+
+	if before >= 0 && rw.at(before) == ':' {
+		first = rw.keyStart(before)
+		before = rw.previous(first)
+	}
+
+	switch {
+	case before < 0 && first == start && rw.written == 0:
+		rw.flush(start)
+		rw.write("null") // the root value
+	case before >= 0 && rw.at(before) == ',':
+		rw.flush(before)
+	default:
+		rw.flush(first)
+		rw.dropComma = true
+	}
+}
+
+// flush writes the input up to the offset, the comma left by a value
+// removed from the start of a container aside.
+func (rw *rewrite) flush(offset int) {
+	text := rw.input.buffer[rw.written-rw.input.offset : offset-rw.input.offset]
+
+	if rw.dropComma {
+		i := skipSpace(text, 0)
+
+		if i < len(text) {
+			rw.dropComma = false
+
+			if text[i] == ',' {
+				text = text[skipSpace(text, i+1):]
+			}
+		}
+	}
+
+	rw.write(string(text))
+	rw.written = offset
+}
+
+func (rw *rewrite) write(s string) {
+	if rw.err == nil {
+		_, rw.err = io.WriteString(rw.output, s)
+	}
+}
+
+func (rw *rewrite) at(offset int) byte {
+	return rw.input.buffer[offset-rw.input.offset]
+}
+
+// previous returns the offset of the last byte before the given one that
+// is not whitespace, -1 if there is none left to be written.
+func (rw *rewrite) previous(offset int) int {
+	for offset--; offset >= rw.written; offset-- {
+		if !isSpace(rw.at(offset)) {
+			return offset
+		}
+	}
+	return -1
+}
+
+// keyStart returns the offset of the key before the colon at the offset.
+func (rw *rewrite) keyStart(colon int) int {
+	quote := rw.previous(colon)
+
+	for offset := quote - 1; offset >= rw.written; offset-- {
+		if rw.at(offset) != '"' {
+			continue
+		}
+
+		escapes := 0
+
+		for rw.at(offset-escapes-1) == '\\' {
+			escapes++
+		}
+		if escapes%2 == 0 {
+			return offset
+		}
+	}
+	return quote
+}
+
+// find returns the offset of the rune at the line and column given, the
+// way the tokenizer counts them, scanning forward from the last one.
+func (rw *rewrite) find(line int, column int) int {
+	for rw.scanned-rw.input.offset < len(rw.input.buffer) {
+		r, size := utf8.DecodeRune(rw.input.buffer[rw.scanned-rw.input.offset:])
+		nextLine, nextColumn := rw.line, rw.column+1
+
+		if r == '\n' {
+			nextLine, nextColumn = rw.line+1, 0
+		}
+		if nextLine == line && nextColumn == column {
+			return rw.scanned
+		}
+
+		rw.scanned += size
+		rw.line, rw.column = nextLine, nextColumn
+	}
+	return rw.scanned
+}
+
+// skipTo moves the scanner to the offset, keeping count of the position.
+func (rw *rewrite) skipTo(offset int) {
+	for rw.scanned < offset {
+		r, size := utf8.DecodeRune(rw.input.buffer[rw.scanned-rw.input.offset:])
+
+		if r == '\n' {
+			rw.line, rw.column = rw.line+1, 0
+		} else {
+			rw.column++
+		}
+		rw.scanned += size
+	}
+}
+
+// lexemeEnd returns the offset right after the value starting at the
+// offset.
+func (rw *rewrite) lexemeEnd(start int) int {
+	buffer := rw.input.buffer[start-rw.input.offset:]
+
+	if len(buffer) > 0 && buffer[0] == '"' {
+		for i := 1; i < len(buffer); i++ {
+			switch buffer[i] {
+			case '\\':
+				i++
+			case '"':
+				return start + i + 1
+			}
+		}
+		return start + len(buffer)
+	}
+
+	for i, b := range buffer {
+		if isSpace(b) || b == ',' || b == ']' || b == '}' {
+			return start + i
+		}
+	}
+	return start + len(buffer)
+}
+
+func isSpace(b byte) bool {
+	return b == ' ' || b == '\t' || b == '\r' || b == '\n'
+}
+
+func skipSpace(text []byte, i int) int {
+	for i < len(text) && isSpace(text[i]) {
+		i++
+	}
+	return i
+}
+
+// compactWriter drops the whitespace between the tokens of the JSON text
+// written through it.
+type compactWriter struct {
+	w        io.Writer
+	inString bool
+	escaped  bool
+	buffer   []byte
+}
+
+func (c *compactWriter) Write(b []byte) (int, error) {
+	c.buffer = c.buffer[:0]
+
+	for _, ch := range b {
+		switch {
+		case c.escaped:
+			c.escaped = false
+		case c.inString && ch == '\\':
+			c.escaped = true
+		case ch == '"':
+			c.inString = !c.inString
+		case !c.inString && isSpace(ch):
+			continue
+		}
+		c.buffer = append(c.buffer, ch)
+	}
+
+	if _, err := c.w.Write(c.buffer); err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
@@ -0,0 +1,51 @@
+package jmatch
+
+import (
+	"fmt"
+
+	"github.com/rodic/jmatch/paths"
+)
+
+// Router passes every value to the handlers registered for the patterns
+// its path matches, so that a single pass over a document feeds many
+// independent consumers:
+//
+//	router := jmatch.NewRouter()
+//	router.Handle(".user.id", onUser)
+//	router.Handle(".events[*].type", onEvent)
+//	err := jmatch.Match(r, router.Dispatch)
+//
+// Patterns are the ones paths.Compile reads. They are kept in a trie, so
+// dispatching a value takes time in the depth of its path, not in the
+// number of patterns. Handlers must not be added while dispatching.
+type Router struct {
+	patterns paths.Set
+	handlers []Matcher
+}
+
+// NewRouter returns a router with no handlers.
+func NewRouter() *Router {
+	return &Router{}
+}
+
+// Handle registers the handler for the values whose path matches the
+// pattern. It panics if the pattern is invalid, patterns being written in
+// the code that registers them.
+func (r *Router) Handle(pattern string, handler Matcher) {
+	compiled, err := paths.Compile(pattern)
+
+	if err != nil {
+		panic(fmt.Sprintf("jmatch: invalid pattern %q: %v", pattern, err))
+	}
+
+	r.patterns.Add(compiled)
+	r.handlers = append(r.handlers, handler)
+}
+
+// Dispatch passes the value to the handlers of the patterns its path
+// matches, in the order they were registered. It is a Matcher.
+func (r *Router) Dispatch(path string, token Token) {
+	for _, i := range r.patterns.Match(path) {
+		r.handlers[i](path, token)
+	}
+}
@@ -0,0 +1,253 @@
+package schema
+
+import (
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/rodic/jmatch/paths"
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+// Config controls how schemas are inferred.
+type Config struct {
+	// MaxEnum is the largest number of distinct strings a value may take
+	// to be described by an enum, provided some are seen more than once.
+	// Enums are not inferred if it is zero.
+	MaxEnum int
+}
+
+// the JSON Schema types, in the order they are written
+var typeNames = []string{"object", "array", "string", "integer", "number", "boolean", "null"}
+
+// node sums up the values found at a place of the documents, the elements
+// of an array sharing theirs.
+type node struct {
+	types      map[string]int
+	properties map[string]*node
+	present    map[string]int // objects holding each key
+	items      *node
+	strings    map[string]bool // nil once there are too many
+	min, max   t.Token
+	minValue   float64
+	maxValue   float64
+	numbers    int
+}
+
+func newNode() *node {
+	return &node{types: map[string]int{}, strings: map[string]bool{}}
+}
+
+func (n *node) property(key string) *node {
+	if n.properties == nil {
+		n.properties = map[string]*node{}
+		n.present = map[string]int{}
+	}
+
+	child, ok := n.properties[key]
+
+	if !ok {
+		child = newNode()
+		n.properties[key] = child
+	}
+	return child
+}
+
+func (n *node) elements() *node {
+	if n.items == nil {
+		n.items = newNode()
+	}
+	return n.items
+}
+
+func (n *node) add(token t.Token, maxEnum int) {
+	switch {
+	case token.IsLeftBrace():
+		n.types["object"]++
+	case token.IsLeftBracket():
+		n.types["array"]++
+	case token.IsString():
+		n.types["string"]++
+
+		if n.strings != nil {
+			n.strings[token.Value] = true
+
+			if len(n.strings) > maxEnum {
+				n.strings = nil
+			}
+		}
+	case token.IsNumber():
+		value, err := strconv.ParseFloat(token.Value, 64)
+
+		if err != nil || value != math.Trunc(value) {
+			n.types["number"]++
+		} else {
+			n.types["integer"]++
+		}
+		if err != nil {
+			return // out of the range of float64
+		}
+		if n.numbers == 0 || value < n.minValue {
+			n.min, n.minValue = token, value
+		}
+		if n.numbers == 0 || value > n.maxValue {
+			n.max, n.maxValue = token, value
+		}
+		n.numbers++
+	case token.IsBoolean():
+		n.types["boolean"]++
+	case token.IsNull():
+		n.types["null"]++
+	}
+}
+
+func (n *node) schema(maxEnum int) *Schema {
+	s := &Schema{}
+
+	for _, name := range typeNames {
+		// integers are numbers too
+		if n.types[name] > 0 && !(name == "integer" && n.types["number"] > 0) {
+			s.Type = append(s.Type, name)
+		}
+	}
+
+	if n.properties != nil {
+		s.Properties = map[string]*Schema{}
+
+		for key, child := range n.properties {
+			s.Properties[key] = child.schema(maxEnum)
+
+			if n.present[key] == n.types["object"] {
+				s.Required = append(s.Required, key)
+			}
+		}
+		sort.Strings(s.Required)
+	}
+
+	if n.items != nil {
+		s.Items = n.items.schema(maxEnum)
+	}
+
+	if n.isEnum(maxEnum) {
+		values := make([]string, 0, len(n.strings))
+
+		for value := range n.strings {
+			values = append(values, value)
+		}
+		sort.Strings(values)
+
+		for _, value := range values {
+			s.Enum = append(s.Enum, value)
+		}
+		if n.types["null"] > 0 {
+			s.Enum = append(s.Enum, nil)
+		}
+	}
+
+	if n.numbers > 0 {
+		min, max := json.Number(n.min.Value), json.Number(n.max.Value)
+		s.Minimum, s.Maximum = &min, &max
+	}
+	return s
+}
+
+// isEnum tells whether the values are few strings, and nulls, repeated.
+func (n *node) isEnum(maxEnum int) bool {
+	if maxEnum == 0 || n.strings == nil || len(n.strings) >= n.types["string"] {
+		return false
+	}
+	for name, count := range n.types {
+		if count > 0 && name != "string" && name != "null" {
+			return false
+		}
+	}
+	return true
+}
+
+// frame is a container being read.
+type frame struct {
+	node     *node
+	isObject bool
+	keys     map[string]bool // found in the object
+}
+
+// Inferrer infers a schema from the values passed to Add, those of one
+// document or of many, such as the records of NDJSON input.
+type Inferrer struct {
+	config Config
+	root   *node
+	stack  []*frame
+}
+
+func NewInferrer(config Config) *Inferrer {
+	return &Inferrer{config: config, root: newNode()}
+}
+
+// Add is a jmatch.Matcher taking the values of the documents, containers
+// included, in the order they are found. Objects and arrays are described
+// only if they are passed along, see jmatch.WithContainers. Paths are
+// expected in jq notation, the default.
+func (i *Inferrer) Add(path string, token t.Token) {
+	// a new document, the previous one may have been left broken
+	if path == "." && !token.IsRightBrace() && !token.IsRightBracket() {
+		i.stack = i.stack[:0]
+	}
+
+	if token.IsRightBrace() || token.IsRightBracket() {
+		i.close()
+		return
+	}
+
+	n := i.root
+
+	if len(i.stack) > 0 {
+		parent := i.stack[len(i.stack)-1]
+
+		if parent.isObject {
+			segments, err := paths.Split(path)
+
+			if err != nil || len(segments) == 0 || !segments[len(segments)-1].IsKey() {
+				return
+			}
+
+			key := segments[len(segments)-1].Key
+			parent.keys[key] = true
+			n = parent.node.property(key)
+		} else {
+			n = parent.node.elements()
+		}
+	}
+
+	n.add(token, i.config.MaxEnum)
+
+	if token.IsLeftBrace() {
+		i.stack = append(i.stack, &frame{node: n, isObject: true, keys: map[string]bool{}})
+	} else if token.IsLeftBracket() {
+		i.stack = append(i.stack, &frame{node: n})
+	}
+}
+
+func (i *Inferrer) close() {
+	if len(i.stack) == 0 {
+		return
+	}
+
+	closed := i.stack[len(i.stack)-1]
+	i.stack = i.stack[:len(i.stack)-1]
+
+	for key := range closed.keys {
+		closed.node.present[key]++
+	}
+}
+
+// Schema returns the schema of the values added so far. Properties are
+// required if they are found in every object, strings are described by an
+// enum if there are at most Config.MaxEnum of them and some are repeated
+// and numbers by their range. A value never seen has an empty schema,
+// which anything matches.
+func (i *Inferrer) Schema() *Schema {
+	s := i.root.schema(i.config.MaxEnum)
+	s.Schema = Draft
+	return s
+}
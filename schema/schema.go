@@ -0,0 +1,133 @@
+// Package schema infers JSON Schemas (draft 2020-12) from the values
+// passed to a jmatch matcher, so that payloads coming without a spec can
+// be documented from samples:
+//
+//	inferrer := schema.NewInferrer(schema.Config{MaxEnum: 10})
+//	err := jmatch.Match(r, inferrer.Add, jmatch.WithContainers())
+//	s := inferrer.Schema()
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+// Draft is the $schema of the schemas written by the package.
+const Draft = "https://json-schema.org/draft/2020-12/schema"
+
+// Schema is the subset of JSON Schema known to the package, the core
+// vocabulary for validation. Other keywords are ignored. The boolean
+// schemas true and false are read as the empty schema, which anything
+// matches, and as one nothing matches.
+type Schema struct {
+	Schema     string             `json:"$schema,omitempty"`
+	Type       Types              `json:"type,omitempty"`
+	Properties map[string]*Schema `json:"properties,omitempty"`
+	Required   []string           `json:"required,omitempty"`
+	// AdditionalProperties is the schema of the properties missing from
+	// Properties, any value being allowed if it is nil.
+	AdditionalProperties *Schema `json:"additionalProperties,omitempty"`
+	Items                *Schema `json:"items,omitempty"`
+
+	Enum  []any           `json:"enum,omitempty"`
+	Const json.RawMessage `json:"const,omitempty"`
+
+	Minimum          *json.Number `json:"minimum,omitempty"`
+	Maximum          *json.Number `json:"maximum,omitempty"`
+	ExclusiveMinimum *json.Number `json:"exclusiveMinimum,omitempty"`
+	ExclusiveMaximum *json.Number `json:"exclusiveMaximum,omitempty"`
+
+	MinLength *int   `json:"minLength,omitempty"`
+	MaxLength *int   `json:"maxLength,omitempty"`
+	Pattern   string `json:"pattern,omitempty"`
+
+	MinItems *int `json:"minItems,omitempty"`
+	MaxItems *int `json:"maxItems,omitempty"`
+
+	never   bool // the false schema
+	pattern *regexp.Regexp
+}
+
+// Never returns the false schema.
+func Never() *Schema {
+	return &Schema{never: true}
+}
+
+// Read reads a schema written as JSON.
+func Read(r io.Reader) (*Schema, error) {
+	var s Schema
+
+	if err := json.NewDecoder(r).Decode(&s); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	if err := s.compile(); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return &s, nil
+}
+
+// schema is Schema without its methods, for the JSON encoding.
+type schema Schema
+
+func (s *Schema) MarshalJSON() ([]byte, error) {
+	if s.never {
+		return []byte("false"), nil
+	}
+	return json.Marshal((*schema)(s))
+}
+
+func (s *Schema) UnmarshalJSON(data []byte) error {
+	switch string(bytes.TrimSpace(data)) {
+	case "true":
+		*s = Schema{}
+		return nil
+	case "false":
+		*s = Schema{never: true}
+		return nil
+	}
+	return json.Unmarshal(data, (*schema)(s))
+}
+
+// Types are the JSON types a value may have. A single one is written as a
+// string, as is usual, and several as an array.
+type Types []string
+
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+func (t *Types) UnmarshalJSON(data []byte) error {
+	var name string
+
+	if err := json.Unmarshal(data, &name); err == nil {
+		*t = Types{name}
+		return nil
+	}
+	return json.Unmarshal(data, (*[]string)(t))
+}
+
+// Has tells whether the type is one of the types.
+func (t Types) Has(name string) bool {
+	for _, n := range t {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+// String writes the schema as indented JSON.
+func (s *Schema) String() string {
+	data, err := json.MarshalIndent(s, "", "  ")
+
+	if err != nil {
+		return err.Error() // numbers are the only part that can be invalid
+	}
+	return string(data)
+}
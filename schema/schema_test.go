@@ -0,0 +1,292 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/rodic/jmatch"
+)
+
+func TestInfer(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		maxEnum  int
+		expected string
+	}{
+		{name: "object",
+			input:   `{"id": 1, "name": "ann", "tags": ["a", "b"], "meta": {}}`,
+			maxEnum: 0,
+			expected: `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "properties": {
+    "id": {
+      "type": "integer",
+      "minimum": 1,
+      "maximum": 1
+    },
+    "meta": {
+      "type": "object"
+    },
+    "name": {
+      "type": "string"
+    },
+    "tags": {
+      "type": "array",
+      "items": {
+        "type": "string"
+      }
+    }
+  },
+  "required": [
+    "id",
+    "meta",
+    "name",
+    "tags"
+  ]
+}`},
+		{name: "records",
+			input: `{"id": 1, "status": "ok"}
+{"id": 2.5, "status": "failed", "note": null}
+{"id": -3, "status": "ok", "note": "late"}
+{"id": 4, "status": null}`,
+			maxEnum: 3,
+			expected: `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "properties": {
+    "id": {
+      "type": "number",
+      "minimum": -3,
+      "maximum": 4
+    },
+    "note": {
+      "type": [
+        "string",
+        "null"
+      ]
+    },
+    "status": {
+      "type": [
+        "string",
+        "null"
+      ],
+      "enum": [
+        "failed",
+        "ok",
+        null
+      ]
+    }
+  },
+  "required": [
+    "id",
+    "status"
+  ]
+}`},
+		{name: "tooManyForEnum",
+			input:   "\"a\"\n\"b\"\n\"c\"\n\"a\"",
+			maxEnum: 2,
+			expected: `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "string"
+}`},
+		{name: "arraysOfObjects",
+			input:   `[{"a": true}, {"a": false, "b": [[]]}, 1]`,
+			maxEnum: 10,
+			expected: `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "array",
+  "items": {
+    "type": [
+      "object",
+      "integer"
+    ],
+    "properties": {
+      "a": {
+        "type": "boolean"
+      },
+      "b": {
+        "type": "array",
+        "items": {
+          "type": "array"
+        }
+      }
+    },
+    "required": [
+      "a"
+    ],
+    "minimum": 1,
+    "maximum": 1
+  }
+}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			inferrer := NewInferrer(Config{MaxEnum: tc.maxEnum})
+
+			err := jmatch.MatchNDJSON(strings.NewReader(tc.input), inferrer.Add, jmatch.WithContainers())
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if s := inferrer.Schema().String(); s != tc.expected {
+				t.Errorf("Expected '%s', got '%s' instead\n", tc.expected, s)
+			}
+		})
+	}
+}
+
+func TestInferBrokenRecord(t *testing.T) {
+	inferrer := NewInferrer(Config{})
+
+	err := jmatch.MatchNDJSON(strings.NewReader("{\"a\": {\"b\": [1,\n{\"c\": 2}"), inferrer.Add,
+		jmatch.WithContainers(), jmatch.WithRecovery())
+
+	if err == nil {
+		t.Error("Expected an error for the broken record")
+	}
+
+	s := inferrer.Schema()
+
+	if !s.Properties["c"].Type.Has("integer") || s.Properties["a"].Properties["c"] != nil {
+		t.Errorf("Expected the broken record left behind, got '%s' instead\n", s)
+	}
+}
+
+func TestTypes(t *testing.T) {
+	for _, input := range []string{`"string"`, `["string","null"]`} {
+		var types Types
+
+		if err := json.Unmarshal([]byte(input), &types); err != nil {
+			t.Fatal(err)
+		}
+
+		output, err := json.Marshal(types)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(output) != input {
+			t.Errorf("Expected '%s', got '%s' instead\n", input, output)
+		}
+	}
+
+	if types := (Types{"string", "null"}); !types.Has("null") || types.Has("number") {
+		t.Errorf("Expected '%v' to have null and not number\n", types)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	s, err := Read(strings.NewReader(`{
+		"type": "object",
+		"required": ["id", "status", "items"],
+		"additionalProperties": false,
+		"properties": {
+			"id": {"type": "integer", "minimum": 1, "exclusiveMaximum": 100},
+			"status": {"enum": ["ok", "failed", null]},
+			"code": {"type": "string", "pattern": "^[A-Z]{3}$", "minLength": 3, "maxLength": 3},
+			"version": {"const": 2},
+			"point": {"const": {"x": 1, "y": [true]}},
+			"items": {"type": "array", "minItems": 1, "items": {"type": ["number", "null"], "maximum": 9.5}},
+			"meta": true,
+			"old": false
+		}
+	}`))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{name: "valid",
+			input:    `{"id": 1, "status": null, "code": "ABC", "version": 2.0, "point": {"y": [true], "x": 1}, "items": [1.5, null], "meta": {"any": [1]}}`,
+			expected: nil},
+		{name: "scalars",
+			input: `{"id": 100, "status": "lost", "code": "abcd", "version": 3, "items": [10, "x"]}`,
+			expected: []string{
+				".id 1:8 exclusiveMaximum: 100 is not less than 100",
+				".status 1:23 enum: value is not one of the enum values",
+				".code 1:39 maxLength: string has 4 characters, more than 3",
+				".code 1:39 pattern: string does not match the pattern \"^[A-Z]{3}$\"",
+				".version 1:58 const: value is not 2",
+				".items[0] 1:71 maximum: 10 is greater than the maximum 9.5",
+				".items[1] 1:75 type: expected number or null, got string",
+			}},
+		{name: "containers",
+			input: `{"id": 0.5, "point": {"x": 1, "y": []}, "items": [], "old": 1, "new": {}}`,
+			expected: []string{
+				".id 1:8 type: expected integer, got number",
+				".id 1:8 minimum: 0.5 is less than the minimum 1",
+				".point 1:22 const: value is not {\"x\": 1, \"y\": [true]}",
+				".items 1:50 minItems: array has 0 items, fewer than 1",
+				".old 1:61 false: no value is allowed",
+				".new 1:71 additionalProperties: property \"new\" is not allowed",
+				". 1:1 required: required property \"status\" is missing",
+			}},
+		{name: "notAnObject",
+			input:    `[1]`,
+			expected: []string{". 1:1 type: expected object, got array"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			violations, err := Validate(strings.NewReader(tc.input), s)
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var found []string
+
+			for _, v := range violations {
+				found = append(found, fmt.Sprintf("%s %d:%d %s: %s", v.Path, v.Line, v.Column, v.Keyword, v.Message))
+			}
+
+			if !reflect.DeepEqual(found, tc.expected) {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, found)
+			}
+		})
+	}
+
+	if _, err := Read(strings.NewReader(`{"pattern": "("}`)); err == nil {
+		t.Error("Expected an error for an invalid pattern")
+	}
+}
+
+func TestValidateInferred(t *testing.T) {
+	inferrer := NewInferrer(Config{MaxEnum: 10})
+	records := "{\"id\": 1, \"status\": \"ok\"}\n{\"id\": 2, \"status\": \"ok\"}\n{\"id\": 3, \"status\": \"failed\"}"
+
+	if err := jmatch.MatchNDJSON(strings.NewReader(records), inferrer.Add, jmatch.WithContainers()); err != nil {
+		t.Fatal(err)
+	}
+
+	var violations []Violation
+
+	validator, err := NewValidator(inferrer.Schema(), func(v Violation) {
+		violations = append(violations, v)
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = jmatch.MatchNDJSON(strings.NewReader(records+"\n{\"id\": 4, \"status\": \"lost\"}"), validator.Add, jmatch.WithContainers())
+
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(violations) != 2 || violations[0].Keyword != "maximum" || violations[1].Line != 4 {
+		t.Errorf("Expected the last record to break the maximum and the enum, got '%v' instead\n", violations)
+	}
+}
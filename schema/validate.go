@@ -0,0 +1,430 @@
+package schema
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"regexp"
+	"strconv"
+	"unicode/utf8"
+
+	"github.com/rodic/jmatch"
+	"github.com/rodic/jmatch/paths"
+	t "github.com/rodic/jmatch/tokenizer"
+)
+
+// Violation is a part of a document not matching its schema.
+type Violation struct {
+	Path    string
+	Line    int
+	Column  int
+	Keyword string // of the schema, false for the false schema
+	Message string
+}
+
+func (v Violation) Error() string {
+	return fmt.Sprintf("%s at line %d column %d: %s", v.Path, v.Line, v.Column, v.Message)
+}
+
+// compile checks the schema and the ones in it, compiling their patterns.
+func (s *Schema) compile() error {
+	if s == nil {
+		return nil
+	}
+
+	if s.Pattern != "" && s.pattern == nil {
+		pattern, err := regexp.Compile(s.Pattern)
+
+		if err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", s.Pattern, err)
+		}
+		s.pattern = pattern
+	}
+
+	if len(s.Const) > 0 && !json.Valid(s.Const) {
+		return fmt.Errorf("invalid const %s", s.Const)
+	}
+
+	for _, property := range s.Properties {
+		if err := property.compile(); err != nil {
+			return err
+		}
+	}
+	if err := s.AdditionalProperties.compile(); err != nil {
+		return err
+	}
+	return s.Items.compile()
+}
+
+// property returns the schema of a property of the objects described and
+// whether the property is allowed at all. Nil schemas allow anything.
+func (s *Schema) property(key string) (*Schema, bool) {
+	if s == nil {
+		return nil, true
+	}
+	if property, ok := s.Properties[key]; ok {
+		return property, true
+	}
+	if s.AdditionalProperties != nil && s.AdditionalProperties.never {
+		return nil, false
+	}
+	return s.AdditionalProperties, true
+}
+
+func (s *Schema) items() *Schema {
+	if s == nil {
+		return nil
+	}
+	return s.Items
+}
+
+// compares tells whether checking the schema takes the value as a whole,
+// as const and enum do.
+func (s *Schema) compares() bool {
+	return s != nil && (len(s.Const) > 0 || len(s.Enum) > 0)
+}
+
+// container is an object or array being validated.
+type container struct {
+	schema   *Schema
+	path     string
+	token    t.Token // opening the container
+	key      string  // of the container in its object
+	isObject bool
+	keys     map[string]bool // found in the object
+	items    int
+
+	// the value of the container, read when it, or a container around
+	// it, has to be compared
+	capture bool
+	object  map[string]any
+	array   []any
+}
+
+// Validator checks documents against a schema as they are read, keeping
+// in memory the open containers only, or the whole values compared to a
+// const or enum.
+type Validator struct {
+	schema *Schema
+	report func(Violation)
+	stack  []*container
+}
+
+// NewValidator returns a validator passing the violations of the schema
+// to report in the order they are found.
+func NewValidator(s *Schema, report func(Violation)) (*Validator, error) {
+	if err := s.compile(); err != nil {
+		return nil, fmt.Errorf("invalid schema: %w", err)
+	}
+	return &Validator{schema: s, report: report}, nil
+}
+
+// Validate reads the document and returns the violations of the schema.
+// The options are passed to jmatch.Match, which is given the containers
+// along with the values.
+func Validate(r io.Reader, s *Schema, opts ...jmatch.Option) ([]Violation, error) {
+	var violations []Violation
+
+	validator, err := NewValidator(s, func(v Violation) {
+		violations = append(violations, v)
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = jmatch.Match(r, validator.Add, append(opts, jmatch.WithContainers())...)
+	return violations, err
+}
+
+// Add is a jmatch.Matcher taking the values of the documents, containers
+// included, see jmatch.WithContainers. Paths are expected in jq notation,
+// the default.
+func (v *Validator) Add(path string, token t.Token) {
+	if token.IsRightBrace() || token.IsRightBracket() {
+		v.close(token)
+		return
+	}
+
+	// a new document, the previous one may have been left broken
+	if path == "." {
+		v.stack = v.stack[:0]
+	}
+
+	s := v.schema
+	key := ""
+	capture := false
+
+	if len(v.stack) > 0 {
+		parent := v.stack[len(v.stack)-1]
+		capture = parent.capture
+
+		if parent.isObject {
+			segments, err := paths.Split(path)
+
+			if err != nil || len(segments) == 0 || !segments[len(segments)-1].IsKey() {
+				return
+			}
+
+			key = segments[len(segments)-1].Key
+			parent.keys[key] = true
+
+			var allowed bool
+
+			if s, allowed = parent.schema.property(key); !allowed {
+				v.violation(path, token, "additionalProperties", "property %s is not allowed", paths.Quote(key))
+			}
+		} else {
+			parent.items++
+			s = parent.schema.items()
+		}
+	}
+
+	v.check(s, path, token)
+
+	if !token.IsLeftBrace() && !token.IsLeftBracket() {
+		if capture {
+			v.insert(key, scalar(token))
+		}
+		return
+	}
+
+	f := &container{
+		schema:   s,
+		path:     path,
+		token:    token,
+		key:      key,
+		isObject: token.IsLeftBrace(),
+		capture:  capture || s.compares(),
+	}
+
+	if f.isObject {
+		f.keys = map[string]bool{}
+	}
+	if f.capture && f.isObject {
+		f.object = map[string]any{}
+	} else if f.capture {
+		f.array = []any{}
+	}
+
+	v.stack = append(v.stack, f)
+}
+
+func (v *Validator) close(token t.Token) {
+	if len(v.stack) == 0 {
+		return
+	}
+
+	f := v.stack[len(v.stack)-1]
+	v.stack = v.stack[:len(v.stack)-1]
+
+	if s := f.schema; s != nil {
+		v.checkContainer(s, f)
+	}
+
+	if !f.capture {
+		return
+	}
+
+	var value any = f.array
+
+	if f.isObject {
+		value = f.object
+	}
+
+	if f.schema != nil {
+		v.compare(f.schema, f.path, f.token, value)
+	}
+	v.insert(f.key, value)
+}
+
+// checkContainer validates what can be told of a container once closed.
+func (v *Validator) checkContainer(s *Schema, f *container) {
+	if f.isObject {
+		for _, key := range s.Required {
+			if !f.keys[key] {
+				v.violation(f.path, f.token, "required", "required property %s is missing", paths.Quote(key))
+			}
+		}
+	} else {
+		if s.MinItems != nil && f.items < *s.MinItems {
+			v.violation(f.path, f.token, "minItems", "array has %d items, fewer than %d", f.items, *s.MinItems)
+		}
+		if s.MaxItems != nil && f.items > *s.MaxItems {
+			v.violation(f.path, f.token, "maxItems", "array has %d items, more than %d", f.items, *s.MaxItems)
+		}
+	}
+}
+
+// insert adds a value read to the container around it, if it is read too.
+func (v *Validator) insert(key string, value any) {
+	if len(v.stack) == 0 {
+		return
+	}
+
+	parent := v.stack[len(v.stack)-1]
+
+	if !parent.capture {
+		return
+	}
+	if parent.isObject {
+		parent.object[key] = value
+	} else {
+		parent.array = append(parent.array, value)
+	}
+}
+
+// check validates what can be told of a value from its first token.
+func (v *Validator) check(s *Schema, path string, token t.Token) {
+	if s == nil {
+		return
+	}
+	if s.never {
+		v.violation(path, token, "false", "no value is allowed")
+		return
+	}
+
+	if name := typeName(token); len(s.Type) > 0 && !s.Type.Has(name) && !(name == "integer" && s.Type.Has("number")) {
+		v.violation(path, token, "type", "expected %s, got %s", joinTypes(s.Type), name)
+	}
+
+	switch {
+	case token.IsString():
+		length := utf8.RuneCountInString(token.Value)
+
+		if s.MinLength != nil && length < *s.MinLength {
+			v.violation(path, token, "minLength", "string has %d characters, fewer than %d", length, *s.MinLength)
+		}
+		if s.MaxLength != nil && length > *s.MaxLength {
+			v.violation(path, token, "maxLength", "string has %d characters, more than %d", length, *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(token.Value) {
+			v.violation(path, token, "pattern", "string does not match the pattern %q", s.Pattern)
+		}
+	case token.IsNumber():
+		v.checkNumber(s, path, token)
+	}
+
+	if !token.IsLeftBrace() && !token.IsLeftBracket() {
+		v.compare(s, path, token, scalar(token))
+	}
+}
+
+func (v *Validator) checkNumber(s *Schema, path string, token t.Token) {
+	value, err := strconv.ParseFloat(token.Value, 64)
+
+	if err != nil {
+		return // out of the range of float64
+	}
+
+	bound := func(limit *json.Number) (float64, bool) {
+		if limit == nil {
+			return 0, false
+		}
+		b, err := limit.Float64()
+		return b, err == nil
+	}
+
+	if b, ok := bound(s.Minimum); ok && value < b {
+		v.violation(path, token, "minimum", "%s is less than the minimum %s", token.Value, *s.Minimum)
+	}
+	if b, ok := bound(s.Maximum); ok && value > b {
+		v.violation(path, token, "maximum", "%s is greater than the maximum %s", token.Value, *s.Maximum)
+	}
+	if b, ok := bound(s.ExclusiveMinimum); ok && value <= b {
+		v.violation(path, token, "exclusiveMinimum", "%s is not greater than %s", token.Value, *s.ExclusiveMinimum)
+	}
+	if b, ok := bound(s.ExclusiveMaximum); ok && value >= b {
+		v.violation(path, token, "exclusiveMaximum", "%s is not less than %s", token.Value, *s.ExclusiveMaximum)
+	}
+}
+
+// compare checks the value against the const and enum of the schema.
+func (v *Validator) compare(s *Schema, path string, token t.Token, value any) {
+	if len(s.Const) > 0 {
+		var expected any
+
+		if json.Unmarshal(s.Const, &expected) == nil && !reflect.DeepEqual(value, expected) {
+			v.violation(path, token, "const", "value is not %s", s.Const)
+		}
+	}
+
+	if len(s.Enum) == 0 {
+		return
+	}
+	for _, expected := range s.Enum {
+		if reflect.DeepEqual(value, expected) {
+			return
+		}
+	}
+	v.violation(path, token, "enum", "value is not one of the enum values")
+}
+
+func (v *Validator) violation(path string, token t.Token, keyword string, format string, args ...any) {
+	v.report(Violation{
+		Path:    path,
+		Line:    token.Line,
+		Column:  token.Column,
+		Keyword: keyword,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+// scalar returns the value of the token the way encoding/json reads it.
+func scalar(token t.Token) any {
+	switch {
+	case token.IsString():
+		return token.Value
+	case token.IsNumber():
+		value, _ := strconv.ParseFloat(token.Value, 64)
+		return value
+	case token.IsBoolean():
+		return token.Value == "true"
+	}
+	return nil
+}
+
+// typeName returns the JSON Schema type of the value the token starts,
+// integer for numbers without a fractional part.
+func typeName(token t.Token) string {
+	switch {
+	case token.IsLeftBrace():
+		return "object"
+	case token.IsLeftBracket():
+		return "array"
+	case token.IsString():
+		return "string"
+	case token.IsNumber():
+		value, err := strconv.ParseFloat(token.Value, 64)
+
+		if err == nil && value == math.Trunc(value) {
+			return "integer"
+		}
+		return "number"
+	case token.IsBoolean():
+		return "boolean"
+	}
+	return "null"
+}
+
+func joinTypes(types Types) string {
+	if len(types) == 1 {
+		return types[0]
+	}
+
+	joined := ""
+
+	for i, name := range types {
+		switch {
+		case i == 0:
+		case i == len(types)-1:
+			joined += " or "
+		default:
+			joined += ", "
+		}
+		joined += name
+	}
+	return joined
+}
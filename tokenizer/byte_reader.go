@@ -0,0 +1,155 @@
+package tokenizer
+
+import (
+	"io"
+	"unicode/utf8"
+)
+
+const defaultBufferSize = 4096
+
+// byteReader reads the input rune by rune through a buffer of bytes,
+// decoding UTF-8 only outside of ASCII. The bytes of the lexeme being read,
+// from mark on, stay in the buffer, which grows to hold them if need be,
+// so that the value of a token is a slice of the buffer. Invalid UTF-8 is
+// read one byte at a time as U+FFFD.
+type byteReader struct {
+	reader io.Reader
+	buffer []byte
+	pos    int   // of the next byte to read
+	end    int   // of the bytes in the buffer
+	mark   int   // where the lexeme kept starts, -1 if none
+	err    error // of the reader, returned once the buffer is used up
+
+	current rune
+	width   int // of current in the input
+	line    int
+	column  int
+	done    bool
+
+	position  textPositionCounter
+	previous  rune // current before the last move, for rewind
+	rewindPos int  // -1 if rewind can't be done
+}
+
+func newByteReader(reader io.Reader, size int) *byteReader {
+	if size <= 0 {
+		size = defaultBufferSize
+	}
+
+	return &byteReader{
+		reader:    reader,
+		buffer:    make([]byte, size),
+		mark:      -1,
+		position:  newTextPositionCounter(),
+		rewindPos: -1,
+	}
+}
+
+// move reads the next rune. At the end of input done is set and the
+// current rune is left as it is.
+func (r *byteReader) move() error {
+	if r.pos == r.end || (r.buffer[r.pos] >= utf8.RuneSelf && !utf8.FullRune(r.buffer[r.pos:r.end])) {
+		r.fill()
+	}
+
+	if r.pos == r.end {
+		if r.err == io.EOF {
+			r.done = true
+			r.rewindPos = -1
+			return nil
+		}
+		return r.err
+	}
+
+	r.previous = r.current
+	r.rewindPos = r.pos
+
+	if b := r.buffer[r.pos]; b < utf8.RuneSelf {
+		r.current, r.width = rune(b), 1
+	} else {
+		r.current, r.width = utf8.DecodeRune(r.buffer[r.pos:r.end])
+	}
+	r.pos += r.width
+
+	r.position.increase(r.current)
+	r.line, r.column = r.position.line, r.position.column
+	return nil
+}
+
+// rewind puts the current rune back, to be read again by the next move.
+// Only the last move can be undone, and not at the end of input.
+func (r *byteReader) rewind() {
+	if r.rewindPos < 0 {
+		return
+	}
+
+	r.pos = r.rewindPos
+	r.rewindPos = -1
+
+	r.position.decrease(r.current)
+	r.line, r.column = r.position.line, r.position.column
+	r.current = r.previous
+}
+
+// advance moves past n bytes of ASCII other than new lines, already in
+// the buffer, without decoding them. They can't be rewound.
+func (r *byteReader) advance(n int) {
+	if n == 0 {
+		return
+	}
+
+	r.pos += n
+	r.position.column += n
+	r.column = r.position.column
+	r.rewindPos = -1
+}
+
+// lexeme returns the bytes read since the mark.
+func (r *byteReader) lexeme() []byte {
+	return r.buffer[r.mark:r.pos]
+}
+
+// fill reads more of the input, keeping the bytes of the lexeme and those
+// a rewind may need.
+func (r *byteReader) fill() {
+	keep := r.pos
+
+	if r.mark >= 0 && r.mark < keep {
+		keep = r.mark
+	}
+	if r.rewindPos >= 0 && r.rewindPos < keep {
+		keep = r.rewindPos
+	}
+
+	if keep > 0 {
+		copy(r.buffer, r.buffer[keep:r.end])
+		r.pos -= keep
+		r.end -= keep
+
+		if r.mark >= 0 {
+			r.mark -= keep
+		}
+		if r.rewindPos >= 0 {
+			r.rewindPos -= keep
+		}
+	}
+
+	for empty := 0; r.err == nil && !utf8.FullRune(r.buffer[r.pos:r.end]); {
+		if r.end == len(r.buffer) {
+			grown := make([]byte, 2*len(r.buffer))
+			copy(grown, r.buffer[:r.end])
+			r.buffer = grown
+		}
+
+		n, err := r.reader.Read(r.buffer[r.end:])
+		r.end += n
+		r.err = err
+
+		if n > 0 {
+			continue
+		}
+		if empty++; empty == 100 {
+			r.err = io.ErrNoProgress
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package tokenizer
+
+import (
+	"io"
+
+	c "github.com/rodic/jmatch/common"
+)
+
+// sizeLimitReader passes through at most limit bytes and fails with
+// InputSizeLimitErr if the input has more to offer.
+type sizeLimitReader struct {
+	reader io.Reader
+	limit  int64
+	read   int64
+}
+
+func newSizeLimitReader(reader io.Reader, limit int64) *sizeLimitReader {
+	return &sizeLimitReader{
+		reader: reader,
+		limit:  limit,
+		read:   0,
+	}
+}
+
+func (s *sizeLimitReader) Read(b []byte) (int, error) {
+	if s.read > s.limit {
+		return 0, c.InputSizeLimitErr{Limit: s.limit}
+	}
+
+	// read one byte past the limit to find out whether there is more
+	if remaining := s.limit - s.read + 1; int64(len(b)) > remaining {
+		b = b[:remaining]
+	}
+
+	n, err := s.reader.Read(b)
+	s.read += int64(n)
+
+	if s.read > s.limit {
+		return n - int(s.read-s.limit), c.InputSizeLimitErr{Limit: s.limit}
+	}
+
+	return n, err
+}
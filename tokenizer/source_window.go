@@ -0,0 +1,137 @@
+package tokenizer
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// sourceWindowSize is how much of the most recently read input is kept
+// around for error excerpts. The window shrinks back to this size once it
+// grows to twice as much.
+const sourceWindowSize = 64 * 1024
+
+// excerptWidth is how many runes are shown on each side of the caret.
+const excerptWidth = 40
+
+// sourceWindow records the tail of the input as it is being read so that
+// errors can show the line they were found on. It is read by the tokenizer
+// goroutine and queried from others, hence the mutex.
+type sourceWindow struct {
+	reader io.Reader
+	mutex  sync.Mutex
+	buffer []byte
+	line   int // line of the first byte in buffer
+	column int // runes preceding the first byte in buffer on its line
+}
+
+func newSourceWindow(reader io.Reader) *sourceWindow {
+	return &sourceWindow{
+		reader: reader,
+		line:   1,
+		column: 0,
+	}
+}
+
+func (s *sourceWindow) Read(b []byte) (int, error) {
+	n, err := s.reader.Read(b)
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.buffer = append(s.buffer, b[:n]...)
+
+	if len(s.buffer) >= 2*sourceWindowSize {
+		cut := len(s.buffer) - sourceWindowSize
+
+		for cut < len(s.buffer) && !utf8.RuneStart(s.buffer[cut]) {
+			cut++
+		}
+
+		s.drop(s.buffer[:cut])
+		s.buffer = append(s.buffer[:0], s.buffer[cut:]...)
+	}
+
+	return n, err
+}
+
+func (s *sourceWindow) drop(dropped []byte) {
+	lines := bytes.Count(dropped, []byte{'\n'})
+
+	if lines == 0 {
+		s.column += utf8.RuneCount(dropped)
+		return
+	}
+
+	s.line += lines
+	s.column = utf8.RuneCount(dropped[bytes.LastIndexByte(dropped, '\n')+1:])
+}
+
+// excerpt returns the given line with a caret under the given column, or
+// an empty string if the line is no longer in the window.
+func (s *sourceWindow) excerpt(line int, column int) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if line < s.line {
+		return ""
+	}
+
+	start := 0
+	first := s.column + 1 // column of the rune at start
+
+	for l := s.line; l < line; l++ {
+		i := bytes.IndexByte(s.buffer[start:], '\n')
+		if i < 0 {
+			return ""
+		}
+		start += i + 1
+		first = 1
+	}
+
+	end := bytes.IndexByte(s.buffer[start:], '\n')
+
+	if end < 0 {
+		end = len(s.buffer)
+	} else {
+		end += start
+	}
+
+	text := []rune(strings.TrimRight(string(s.buffer[start:end]), "\r"))
+	at := column - first
+
+	if at < 0 || at > len(text) {
+		return ""
+	}
+
+	from, to := max(at-excerptWidth, 0), min(at+excerptWidth, len(text))
+	prefix, suffix := "", ""
+
+	if from > 0 || first > 1 {
+		prefix = "..."
+	}
+	if to < len(text) {
+		suffix = "..."
+	}
+
+	var caret strings.Builder
+
+	caret.WriteString(strings.Repeat(" ", len(prefix)))
+
+	for _, r := range text[from:at] {
+		if r == '\t' {
+			caret.WriteRune('\t')
+		} else {
+			caret.WriteRune(' ')
+		}
+	}
+	caret.WriteRune('^')
+
+	gutter := fmt.Sprintf("%d | ", line)
+	margin := strings.Repeat(" ", len(gutter)-2) + "| "
+
+	return gutter + prefix + string(text[from:to]) + suffix + "\n" + margin + caret.String()
+}
@@ -0,0 +1,67 @@
+package tokenizer
+
+import (
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestSourceWindowExcerpt(t *testing.T) {
+	long := strings.Repeat("x", 100)
+	filler := strings.Repeat("[1, 2, 3],\n", 20000)
+
+	testCases := []struct {
+		name     string
+		input    string
+		line     int
+		column   int
+		expected string
+	}{
+		{name: "singleLine",
+			input:    "{\"a\" 1}",
+			line:     1,
+			column:   6,
+			expected: "1 | {\"a\" 1}\n  |      ^"},
+		{name: "secondLine",
+			input:    "{\n\t\"a\" 1\n}",
+			line:     2,
+			column:   6,
+			expected: "2 | \t\"a\" 1\n  | \t    ^"},
+		{name: "longLine",
+			input:    "[\"" + long + "\", 1 2]",
+			line:     1,
+			column:   108,
+			expected: "1 | ..." + long[:35] + "\", 1 2]\n  | " + strings.Repeat(" ", 43) + "^"},
+		{name: "afterTrimming",
+			input:    "[\n" + filler + "1 2]",
+			line:     20002,
+			column:   3,
+			expected: "20002 | 1 2]\n      |   ^"},
+		{name: "trimmedAway",
+			input:    "[\n" + filler + "1 2]",
+			line:     1,
+			column:   1,
+			expected: ""},
+		{name: "missingLine",
+			input:    "[1 2]",
+			line:     2,
+			column:   1,
+			expected: ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			source := newSourceWindow(strings.NewReader(tc.input))
+
+			if _, err := io.Copy(io.Discard, source); err != nil {
+				t.Fatal(err)
+			}
+
+			result := source.excerpt(tc.line, tc.column)
+
+			if result != tc.expected {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, result)
+			}
+		})
+	}
+}
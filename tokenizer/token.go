@@ -15,6 +15,7 @@ const (
 	boolean
 	null
 	colon
+	invalid
 )
 
 type Token struct {
@@ -73,6 +74,12 @@ func NewCommaToken(line int, column int) Token {
 	return Token{_type: comma, Value: ",", Line: line, Column: column}
 }
 
+// NewInvalidToken stands in for a lexeme the tokenizer could not read when
+// parsing in recovery mode.
+func NewInvalidToken(value string, line int, column int) Token {
+	return new(invalid, value, line, column)
+}
+
 func (t Token) IsLeftBrace() bool {
 	return t._type == leftBrace
 }
@@ -89,6 +96,12 @@ func (t Token) IsRightBracket() bool {
 	return t._type == rightBracket
 }
 
+// IsContainer tells whether the token opens or closes an object or an
+// array.
+func (t Token) IsContainer() bool {
+	return t.IsLeftBrace() || t.IsRightBrace() || t.IsLeftBracket() || t.IsRightBracket()
+}
+
 func (t Token) IsComma() bool {
 	return t._type == comma
 }
@@ -113,6 +126,10 @@ func (t Token) IsColon() bool {
 	return t._type == colon
 }
 
+func (t Token) IsInvalid() bool {
+	return t._type == invalid
+}
+
 func (t Token) AsUnexpectedTokenErr() c.UnexpectedTokenErr {
 	return c.UnexpectedTokenErr{Token: t.Value, Line: t.Line, Column: t.Column}
 }
@@ -1,10 +1,12 @@
 package tokenizer
 
 import (
-	"fmt"
+	"errors"
 	"io"
+	"strconv"
 	"unicode"
-	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
 
 	c "github.com/rodic/jmatch/common"
 )
@@ -14,15 +16,107 @@ type TokenResult struct {
 	Error error
 }
 
+// Config controls how the tokenizer treats its input. Limits set to zero
+// are not enforced.
+type Config struct {
+	// Recover makes the tokenizer report an invalid lexeme and carry on
+	// with the rest of the input instead of stopping at the first error.
+	Recover bool
+
+	// Strict rejects what RFC 8259 does not allow but is harmless to let
+	// through: unknown escapes, escapes of unpaired surrogates and raw
+	// control characters in strings.
+	Strict bool
+
+	// BufferSize is the size of the buffer input is read through, 4096
+	// bytes if not set.
+	BufferSize int
+
+	MaxStringLength int   // in bytes, after the quotes are removed
+	MaxNumberLength int   // in characters
+	MaxTokens       int   // in the whole input
+	MaxInputBytes   int64 // read from the reader
+}
+
+// Scanner reads the tokens of its input one at a time, on the goroutine
+// calling Scan. Values are not copied out of its buffer, so tokens cost no
+// allocation:
+//
+//	scanner := tokenizer.NewScanner(r, tokenizer.Config{})
+//
+//	for {
+//		token, err := scanner.Scan()
+//		if err == io.EOF {
+//			break
+//		}
+//		...
+//	}
+type Scanner struct {
+	input        *byteReader
+	source       *sourceWindow
+	config       Config
+	tokensCount  int
+	numberLine   int // where the number being read starts
+	numberColumn int
+	scratch      []byte // the value of the string read, if it is not a slice of the input
+	stopped      bool
+}
+
+// RawToken is a token read by a Scanner. The values of strings and numbers
+// are in Bytes, a slice of the scanner's buffer valid until the next call
+// to Scan, the Value of the token being left empty. Copy makes a Token
+// that outlives it.
+type RawToken struct {
+	Token
+	Bytes []byte
+}
+
+// Copy returns the token with its value copied out of the scanner's
+// buffer.
+func (r RawToken) Copy() Token {
+	token := r.Token
+
+	if token.IsString() || token.IsNumber() {
+		token.Value = string(r.Bytes)
+	}
+	return token
+}
+
+func NewScanner(r io.Reader, config Config) *Scanner {
+	if config.MaxInputBytes > 0 {
+		r = newSizeLimitReader(r, config.MaxInputBytes)
+	}
+
+	source := newSourceWindow(r)
+
+	return &Scanner{
+		input:  newByteReader(source, config.BufferSize),
+		source: source,
+		config: config,
+	}
+}
+
+// Excerpt returns the input line with a caret under the given column, for
+// error messages. Only the recently read part of the input is kept, so the
+// result is empty for positions too far behind the scanner. It is safe to
+// call while scanning.
+func (s *Scanner) Excerpt(line int, column int) string {
+	return s.source.excerpt(line, column)
+}
+
+// tokenizer sends the tokens of a scanner down a channel, copied, so that
+// they can be read on another goroutine.
 type tokenizer struct {
-	runes       RuneReader
+	*Scanner
 	tokenStream chan TokenResult
+	done        chan struct{} // closed by Stop
 }
 
-func NewTokenizer(r io.Reader) tokenizer {
+func NewTokenizer(r io.Reader, config Config) tokenizer {
 	return tokenizer{
-		runes:       NewRuneReader(r),
+		Scanner:     NewScanner(r, config),
 		tokenStream: make(chan TokenResult),
+		done:        make(chan struct{}),
 	}
 }
 
@@ -30,153 +124,679 @@ func (t *tokenizer) GetTokenReadStream() <-chan TokenResult {
 	return t.tokenStream
 }
 
-func (t *tokenizer) getString() (string, error) {
-	var res strings.Builder
+func (t *tokenizer) Tokenize() {
+	defer close(t.tokenStream)
+
+	for {
+		token, err := t.Scan()
+
+		if err == io.EOF {
+			return
+		}
+
+		result := TokenResult{Error: err}
+
+		if err == nil {
+			result.Token = token.Copy()
+		}
+
+		select {
+		case t.tokenStream <- result:
+		case <-t.done:
+			return
+		}
+	}
+}
+
+// Stop makes Tokenize give up on the rest of the input, the tokens not
+// read yet being dropped, and returns once it has, so that the reader is
+// no longer read from. It must be called once Tokenize is started, by the
+// reader of the token stream when it is done with it.
+func (t *tokenizer) Stop() {
+	select {
+	case <-t.done:
+	default:
+		close(t.done)
+	}
+
+	for range t.tokenStream {
+	}
+}
+
+// Scan returns the next token, io.EOF at the end of input. In recovery
+// mode, scanning goes on after an invalid lexeme; other errors end it, the
+// next calls returning io.EOF.
+func (s *Scanner) Scan() (RawToken, error) {
+	if s.stopped {
+		return RawToken{}, io.EOF
+	}
+
+	token, recoverable, err := s.next()
 
-	if err := t.runes.move(); err != nil {
-		return "", err
+	if err != nil && !(recoverable && s.config.Recover) {
+		s.stopped = true
 	}
+	return token, err
+}
+
+// next reads the next token and tells, on error, whether scanning may go
+// on in recovery mode.
+func (s *Scanner) next() (token RawToken, recoverable bool, err error) {
+	in := s.input
 
 	for {
-		if t.runes.current == '"' {
-			break
+		// spaces and tabs, the bulk of indentation
+		n := 0
+
+		for rest := in.buffer[in.pos:in.end]; n < len(rest) && (rest[n] == ' ' || rest[n] == '\t'); n++ {
 		}
-		res.WriteRune(t.runes.current)
+		in.advance(n)
 
-		if err := t.runes.move(); err != nil {
-			return "", err
+		if err = in.move(); err != nil {
+			return RawToken{}, false, err
 		}
+
+		if in.done {
+			return RawToken{}, false, io.EOF
+		}
+
+		current := in.current
+
+		line := in.line
+		column := in.column
+
+		if current == ' ' || current == '\n' || current == '\t' || current == '\r' {
+			continue
+		}
+
+		if limit := s.config.MaxTokens; limit > 0 && s.tokensCount == limit {
+			return RawToken{}, false, c.TokenCountLimitErr{Limit: limit, Line: line, Column: column}
+		}
+
+		switch current {
+		case '{':
+			token.Token = NewLeftBraceToken(line, column)
+		case '}':
+			token.Token = NewRightBraceToken(line, column)
+		case '[':
+			token.Token = NewLeftBracketToken(line, column)
+		case ']':
+			token.Token = NewRightBracketToken(line, column)
+		case ',':
+			token.Token = NewCommaToken(line, column)
+		case ':':
+			token.Token = NewColonToken(line, column)
+		case '"':
+			var str []byte
+
+			if str, err = s.getString(); err != nil {
+				return RawToken{}, errors.Is(err, c.ErrUnexpectedToken), err
+			}
+			token = RawToken{Token: NewStringToken("", line, column), Bytes: str}
+		case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+			var digits []byte
+
+			if digits, err = s.getNumber(); err != nil {
+				if !errors.Is(err, c.ErrUnexpectedToken) {
+					return RawToken{}, false, err
+				}
+				// the rune that broke the number may start the next token
+				in.rewind()
+				return RawToken{}, true, err
+			}
+			token = RawToken{Token: NewNumberToken("", line, column), Bytes: digits}
+		default:
+			var text []byte
+
+			if text, err = s.getText(); err != nil {
+				return RawToken{}, false, err
+			}
+
+			switch string(text) {
+			case "true":
+				token.Token = NewBooleanToken("true", line, column)
+			case "false":
+				token.Token = NewBooleanToken("false", line, column)
+			case "null":
+				token.Token = NewNullToken(line, column)
+			default:
+				if !utf8.Valid(text) {
+					text = []byte(string([]rune(string(text)))) // invalid UTF-8 as U+FFFD
+				}
+				return RawToken{}, true, c.UnexpectedTokenErr{Token: string(text), Line: line, Column: column, Expected: []string{"value"}}
+			}
+		}
+
+		s.tokensCount++
+		return token, false, nil
 	}
-	return res.String(), nil
 }
 
-func (t *tokenizer) getNumber() (string, error) {
-	var res strings.Builder
+// getString reads a string, the opening quote being the current rune. The
+// value is a slice of the input unless the string holds escapes or invalid
+// UTF-8, in which case it is decoded into the scratch buffer.
+func (s *Scanner) getString() ([]byte, error) {
+	in := s.input
+	var invalid error // reported once the whole string is read
+
+	line, column := in.line, in.column
+	limit := s.config.MaxStringLength
+
+	in.mark = in.pos
+	defer func() { in.mark = -1 }()
 
-	res.WriteRune(t.runes.current)
+	plain := true // the value is the input as it is
+	s.scratch = s.scratch[:0]
 
-	dotCount := 0
-	isDigitSet := t.runes.current != '-'
+	// unplain copies the input read so far, the current rune left out, to
+	// the scratch buffer, which holds the value from then on
+	unplain := func() {
+		if plain {
+			s.scratch = append(s.scratch, in.buffer[in.mark:in.pos-in.width]...)
+			plain = false
+		}
+	}
+
+	length := func() int {
+		if plain {
+			return in.pos - in.mark
+		}
+		return len(s.scratch)
+	}
 
 	for {
-		if err := t.runes.move(); err != nil {
-			return "", err
-		}
-
-		if unicode.IsDigit(t.runes.current) {
-			isDigitSet = true
-			res.WriteRune(t.runes.current)
-		} else if t.runes.current == '.' && dotCount == 0 && isDigitSet {
-			dotCount++
-			res.WriteRune(t.runes.current)
-		} else {
-			t.runes.rewind()
+		// ASCII standing for itself, the bulk of most strings
+		n := 0
+		rest := in.buffer[in.pos:in.end]
+
+		for n < len(rest) && rest[n] >= 0x20 && rest[n] < utf8.RuneSelf && rest[n] != '"' && rest[n] != '\\' {
+			n++
+		}
+
+		if n > 0 {
+			if limit > 0 && length()+n > limit {
+				return nil, c.StringLengthLimitErr{Limit: limit, Line: line, Column: column}
+			}
+			if !plain {
+				s.scratch = append(s.scratch, rest[:n]...)
+			}
+			in.advance(n)
+		}
+
+		if err := in.move(); err != nil {
+			return nil, err
+		}
+
+		if in.done {
+			return nil, c.UnexpectedEndOfInputErr{Line: line, Column: column}
+		}
+
+		current := in.current
+
+		if current == '"' {
 			break
 		}
+
+		if current == '\\' {
+			unplain()
+
+			if err := s.getEscape(); err != nil {
+				if !errors.Is(err, c.ErrUnexpectedToken) {
+					return nil, err
+				}
+				if invalid == nil {
+					invalid = err
+				}
+			}
+		} else if current < 0x20 && s.config.Strict {
+			unplain()
+
+			if invalid == nil {
+				invalid = c.UnexpectedTokenErr{
+					Token:    strconv.QuoteRune(current),
+					Line:     in.line,
+					Column:   in.column,
+					Expected: []string{"escape sequence"},
+				}
+			}
+		} else if current == utf8.RuneError && in.width == 1 {
+			unplain()
+			s.write(current)
+		} else if !plain {
+			s.write(current)
+		}
+
+		if limit > 0 && length() > limit {
+			return nil, c.StringLengthLimitErr{Limit: limit, Line: line, Column: column}
+		}
 	}
 
-	// if unexpected end of number
-	if !unicode.IsDigit(t.runes.current) {
-		t.runes.move()
-		return string(t.runes.current), fmt.Errorf("invalid number")
+	if invalid != nil {
+		return nil, invalid
+	}
+	if plain {
+		return in.buffer[in.mark : in.pos-1], nil
 	}
+	return s.scratch, nil
+}
 
-	return res.String(), nil
+// write adds the rune to the value of the string being read.
+func (s *Scanner) write(r rune) {
+	s.scratch = utf8.AppendRune(s.scratch, r)
 }
 
-func (t *tokenizer) getText() (string, error) {
-	var res strings.Builder
+// getEscape decodes the escape sequence starting at the backslash under the
+// cursor. Unknown escapes are kept as they are and unpaired surrogates
+// become U+FFFD, unless the tokenizer is strict.
+func (s *Scanner) getEscape() error {
+	if err := s.input.move(); err != nil {
+		return err
+	}
 
-	res.WriteRune(t.runes.current)
+	if s.input.done {
+		return nil // the string is not terminated, reported by the caller
+	}
+	return s.decodeEscape()
+}
 
+// decodeEscape decodes the escape sequence whose backslash is right before
+// the cursor.
+func (s *Scanner) decodeEscape() error {
+	switch current := s.input.current; current {
+	case '"', '\\', '/':
+		s.write(current)
+	case 'b':
+		s.write('\b')
+	case 'f':
+		s.write('\f')
+	case 'n':
+		s.write('\n')
+	case 'r':
+		s.write('\r')
+	case 't':
+		s.write('\t')
+	case 'u':
+		r, err := s.getCodeUnit()
+		if err != nil {
+			return err
+		}
 
-	for {
-		if err := t.runes.move(); err != nil {
-			return "", err
+		if !utf16.IsSurrogate(r) {
+			s.write(r)
+			return nil
+		}
+		if r >= 0xDC00 { // low surrogate without a high one
+			s.write(unicode.ReplacementChar)
+			return s.loneSurrogate(r, s.input.line, s.input.column)
+		}
+		return s.getLowSurrogate(r)
+	default:
+		if s.config.Strict {
+			return c.UnexpectedTokenErr{
+				Token:    "\\" + string(current),
+				Line:     s.input.line,
+				Column:   s.input.column - 1,
+				Expected: []string{"escape sequence"},
+			}
 		}
+		s.write('\\')
+		s.write(current)
+	}
+	return nil
+}
 
-		if unicode.IsLetter(t.runes.current) {
-			res.WriteRune(t.runes.current)
-		} else {
-			t.runes.rewind()
-			break
+// getLowSurrogate reads the escape that should follow a high surrogate and
+// writes the pair decoded.
+func (s *Scanner) getLowSurrogate(high rune) error {
+	in := s.input
+	line, column := in.line, in.column
+
+	if err := in.move(); err != nil {
+		return err
+	}
+
+	if in.done || in.current != '\\' {
+		s.write(unicode.ReplacementChar)
+		in.rewind()
+		return s.loneSurrogate(high, line, column)
+	}
+
+	if err := in.move(); err != nil {
+		return err
+	}
+
+	if in.done {
+		return nil // the string is not terminated, reported by the caller
+	}
+
+	if in.current != 'u' {
+		// another escape follows the lonely high surrogate
+		s.write(unicode.ReplacementChar)
+
+		if err := s.decodeEscape(); err != nil {
+			return err
 		}
+		return s.loneSurrogate(high, line, column)
+	}
+
+	low, err := s.getCodeUnit()
+	if err != nil {
+		return err
 	}
-	return res.String(), nil
+
+	if r := utf16.DecodeRune(high, low); r != unicode.ReplacementChar {
+		s.write(r)
+		return nil
+	}
+
+	s.write(unicode.ReplacementChar)
+
+	if utf16.IsSurrogate(low) {
+		s.write(unicode.ReplacementChar)
+	} else {
+		s.write(low)
+	}
+	return s.loneSurrogate(high, line, column)
 }
 
-func (t *tokenizer) writeTokenResult(token Token) {
-	t.tokenStream <- TokenResult{Token: token, Error: nil}
+// loneSurrogate rejects, if the tokenizer is strict, the escape of a
+// surrogate that is not part of a pair, whose last hex digit is at the
+// given line and column. The string is decoded as it would be otherwise.
+func (s *Scanner) loneSurrogate(r rune, line int, column int) error {
+	if !s.config.Strict {
+		return nil
+	}
+	return c.UnexpectedTokenErr{
+		Token:    "\\u" + strconv.FormatInt(int64(r), 16),
+		Line:     line,
+		Column:   column - 5,
+		Expected: []string{"surrogate pair"},
+	}
 }
 
-func (t *tokenizer) writeError(err error) {
-	t.tokenStream <- TokenResult{Error: err}
+// getCodeUnit reads the four hex digits of a \u escape.
+func (s *Scanner) getCodeUnit() (rune, error) {
+	in := s.input
+	var r rune
+
+	for i := 0; i < 4; i++ {
+		if err := in.move(); err != nil {
+			return 0, err
+		}
+
+		if in.done {
+			return 0, nil // the string is not terminated, reported by the caller
+		}
+
+		digit := hexValue(in.current)
+
+		if digit < 0 {
+			err := c.UnexpectedTokenErr{
+				Token:    string(in.current),
+				Line:     in.line,
+				Column:   in.column,
+				Expected: []string{"hex digit"},
+			}
+			// the rune may be the closing quote
+			in.rewind()
+			return 0, err
+		}
+		r = r<<4 | digit
+	}
+	return r, nil
 }
 
-func (t *tokenizer) Tokenize() {
+func hexValue(r rune) rune {
+	switch {
+	case r >= '0' && r <= '9':
+		return r - '0'
+	case r >= 'a' && r <= 'f':
+		return r - 'a' + 10
+	case r >= 'A' && r <= 'F':
+		return r - 'A' + 10
+	}
+	return -1
+}
 
-	defer close(t.tokenStream)
+func isDigit(r rune) bool {
+	return r >= '0' && r <= '9'
+}
 
-	for {
-		t.runes.move()
+// accept moves to the next rune and adds it to the number if it is one of
+// the wanted ones. Otherwise the rune is left for the next lexeme.
+func (s *Scanner) accept(wanted func(rune) bool) (bool, error) {
+	in := s.input
 
-		if t.runes.done {
-			break
+	if err := in.move(); err != nil {
+		return false, err
+	}
+
+	if in.done {
+		return false, nil
+	}
+
+	if !wanted(in.current) {
+		in.rewind()
+		return false, nil
+	}
+
+	if limit := s.config.MaxNumberLength; limit > 0 && len(in.lexeme()) > limit {
+		return false, c.NumberLengthLimitErr{Limit: limit, Line: s.numberLine, Column: s.numberColumn}
+	}
+	return true, nil
+}
+
+// acceptASCIIDigits adds the digits 0 to 9 that follow to the number,
+// without decoding them one at a time.
+func (s *Scanner) acceptASCIIDigits() error {
+	in := s.input
+	n := 0
+
+	for rest := in.buffer[in.pos:in.end]; n < len(rest) && rest[n] >= '0' && rest[n] <= '9'; n++ {
+	}
+
+	if limit := s.config.MaxNumberLength; limit > 0 && len(in.lexeme())+n > limit {
+		return c.NumberLengthLimitErr{Limit: limit, Line: s.numberLine, Column: s.numberColumn}
+	}
+
+	in.advance(n)
+	return nil
+}
+
+// acceptDigits adds the digits that follow to the number. At least one is
+// required.
+func (s *Scanner) acceptDigits() error {
+	ok, err := s.accept(isDigit)
+
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return s.unexpectedInNumber("digit")
+	}
+
+	for ok {
+		if err := s.acceptASCIIDigits(); err != nil {
+			return err
 		}
+		if ok, err = s.accept(isDigit); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		current := t.runes.current
+// unexpectedInNumber reports the rune following the number read so far.
+func (s *Scanner) unexpectedInNumber(expected ...string) error {
+	in := s.input
+	line, column := in.line, in.column
 
-		line := t.runes.line
-		column := t.runes.column
+	if err := in.move(); err != nil {
+		return err
+	}
 
-		switch current {
-		case ' ':
-		case '\n':
-			continue
-		case '{':
-			t.writeTokenResult(NewLeftBraceToken(line, column))
-		case '}':
-			t.writeTokenResult(NewRightBraceToken(line, column))
-		case '[':
-			t.writeTokenResult(NewLeftBracketToken(line, column))
-		case ']':
-			t.writeTokenResult(NewRightBracketToken(line, column))
-		case ',':
-			t.writeTokenResult(NewCommaToken(line, column))
-		case ':':
-			t.writeTokenResult(NewColonToken(line, column))
-		case '"':
-			str, err := t.getString()
-			if err == nil {
-				t.writeTokenResult(NewStringToken(str, line, column))
-			} else {
-				t.writeError(err)
-				return
-			}
-		case '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
-			digit, err := t.getNumber()
-			if err == nil {
-				t.writeTokenResult(NewNumberToken(digit, line, column))
-			} else {
-				t.writeError(c.UnexpectedTokenErr{Token: digit, Line: t.runes.line, Column: t.runes.column})
-				return
-			}
-		default:
-			text, err := t.getText()
+	if in.done {
+		return c.UnexpectedEndOfInputErr{Line: line, Column: column}
+	}
 
-			if err != nil {
-				t.writeError(err)
-				return
+	return c.UnexpectedTokenErr{
+		Token:    string(in.current),
+		Line:     in.line,
+		Column:   in.column,
+		Expected: expected,
+	}
+}
+
+// getNumber reads a number as defined by RFC 8259, its first rune being
+// the current one. Numbers are written out as they are read, so leading
+// zeros and digits of other scripts than Latin are rejected even when the
+// tokenizer is not strict.
+func (s *Scanner) getNumber() ([]byte, error) {
+	in := s.input
+
+	s.numberLine, s.numberColumn = in.line, in.column
+
+	in.mark = in.pos - in.width
+	defer func() { in.mark = -1 }()
+
+	if in.current == '-' {
+		if ok, err := s.accept(isDigit); err != nil {
+			return nil, err
+		} else if !ok {
+			return nil, s.unexpectedInNumber("digit")
+		}
+	}
+
+	leadingZero := in.current == '0'
+
+	for {
+		if !leadingZero {
+			if err := s.acceptASCIIDigits(); err != nil {
+				return nil, err
 			}
+		}
 
-			if text == "true" || text == "false" {
-				t.writeTokenResult(NewBooleanToken(text, line, column))
-			} else if text == "null" {
-				t.writeTokenResult(NewNullToken(line, column))
-			} else {
-				t.writeError(c.UnexpectedTokenErr{Token: text, Line: line, Column: column})
-				return
+		ok, err := s.accept(isDigit)
+
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			break
+		}
+		if leadingZero {
+			return nil, c.UnexpectedTokenErr{
+				Token:    string(in.current),
+				Line:     in.line,
+				Column:   in.column,
+				Expected: []string{".", "e", "end of number"},
 			}
 		}
 	}
+
+	isDot := func(r rune) bool { return r == '.' }
+	isExponent := func(r rune) bool { return r == 'e' || r == 'E' }
+	isSign := func(r rune) bool { return r == '+' || r == '-' }
+
+	if ok, err := s.accept(isDot); err != nil {
+		return nil, err
+	} else if ok {
+		if err := s.acceptDigits(); err != nil {
+			return nil, err
+		}
+	}
+
+	if ok, err := s.accept(isExponent); err != nil {
+		return nil, err
+	} else if ok {
+		if _, err := s.accept(isSign); err != nil {
+			return nil, err
+		}
+		if err := s.acceptDigits(); err != nil {
+			return nil, err
+		}
+	}
+
+	return in.lexeme(), nil
+}
+
+// maxLiteralLength is the length of the longest literal, false. Text
+// running longer is cut one letter past it, so that a run of letters of
+// any length is not held in memory.
+const maxLiteralLength = 5
+
+// getText reads the letters starting with the current rune, true, false
+// and null when the input is valid, maxLiteralLength+1 of them at most. In
+// recovery mode the rest of a longer run is skipped, so that it makes a
+// single invalid token.
+func (s *Scanner) getText() ([]byte, error) {
+	in := s.input
+
+	in.mark = in.pos - in.width
+	defer func() { in.mark = -1 }()
+
+	for letters := 1; letters <= maxLiteralLength; letters++ {
+		n := 0
+
+		for rest := in.buffer[in.pos:in.end]; n < len(rest) && letters+n <= maxLiteralLength && isASCIILetter(rest[n]); n++ {
+		}
+		in.advance(n)
+		letters += n
+
+		if letters > maxLiteralLength {
+			break
+		}
+
+		if err := in.move(); err != nil {
+			return nil, err
+		}
+
+		if in.done {
+			return in.lexeme(), nil
+		}
+
+		if !unicode.IsLetter(in.current) {
+			in.rewind()
+			return in.lexeme(), nil
+		}
+	}
+
+	if !s.config.Recover {
+		return in.lexeme(), nil
+	}
+
+	s.scratch = append(s.scratch[:0], in.lexeme()...)
+	in.mark = -1
+
+	return s.scratch, s.skipLetters()
+}
+
+// skipLetters moves past the letters that follow.
+func (s *Scanner) skipLetters() error {
+	in := s.input
+
+	for {
+		n := 0
+
+		for rest := in.buffer[in.pos:in.end]; n < len(rest) && isASCIILetter(rest[n]); n++ {
+		}
+		in.advance(n)
+
+		if err := in.move(); err != nil {
+			return err
+		}
+
+		if in.done {
+			return nil
+		}
+
+		if !unicode.IsLetter(in.current) {
+			in.rewind()
+			return nil
+		}
+	}
+}
+
+func isASCIILetter(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
 }
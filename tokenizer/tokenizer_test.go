@@ -1,9 +1,14 @@
 package tokenizer
 
 import (
+	"fmt"
+	"io"
 	"reflect"
 	"strings"
 	"testing"
+	"testing/iotest"
+
+	c "github.com/rodic/jmatch/common"
 )
 
 func TestTokenizeValidInputs(t *testing.T) {
@@ -130,7 +135,63 @@ func TestTokenizeValidInputs(t *testing.T) {
 				NewRightBracketToken(1, 25),
 				NewRightBraceToken(1, 26)}},
 
+		// Escapes, exponents and whitespace
+		{name: "escapes",
+			input: `["a\"b\\c\/", "\b\f\n\r\t", "\u00fc\u20AC", "\ud83d\ude00"]`,
+			expected: []Token{
+				NewLeftBracketToken(1, 1),
+				NewStringToken("a\"b\\c/", 1, 2),
+				NewCommaToken(1, 13),
+				NewStringToken("\b\f\n\r\t", 1, 15),
+				NewCommaToken(1, 27),
+				NewStringToken("ü€", 1, 29),
+				NewCommaToken(1, 43),
+				NewStringToken("😀", 1, 45),
+				NewRightBracketToken(1, 59)}},
+		{name: "loneSurrogates",
+			input: `["\ud83dx", "\ude00", "\ud83d\n"]`,
+			expected: []Token{
+				NewLeftBracketToken(1, 1),
+				NewStringToken("\ufffdx", 1, 2),
+				NewCommaToken(1, 11),
+				NewStringToken("\ufffd", 1, 13),
+				NewCommaToken(1, 21),
+				NewStringToken("\ufffd\n", 1, 23),
+				NewRightBracketToken(1, 33)}},
+		{name: "unknownEscape",
+			input: `"\x"`,
+			expected: []Token{
+				NewStringToken("\\x", 1, 1)}},
+		{name: "exponents",
+			input: "[1e3, -1.5E+10, 2e-2, 0.5]",
+			expected: []Token{
+				NewLeftBracketToken(1, 1),
+				NewNumberToken("1e3", 1, 2),
+				NewCommaToken(1, 5),
+				NewNumberToken("-1.5E+10", 1, 7),
+				NewCommaToken(1, 15),
+				NewNumberToken("2e-2", 1, 17),
+				NewCommaToken(1, 21),
+				NewNumberToken("0.5", 1, 23),
+				NewRightBracketToken(1, 26)}},
+		{name: "tabsAndCarriageReturns",
+			input: "{\r\n\t\"a\":\t1\r\n}",
+			expected: []Token{
+				NewLeftBraceToken(1, 1),
+				NewStringToken("a", 2, 2),
+				NewColonToken(2, 5),
+				NewNumberToken("1", 2, 7),
+				NewRightBraceToken(3, 1)}},
+
 		// Nested
+		{name: "numberAtEnd",
+			input: "12",
+			expected: []Token{
+				NewNumberToken("12", 1, 1)}},
+		{name: "literalAtEnd",
+			input: "null",
+			expected: []Token{
+				NewNullToken(1, 1)}},
 		{name: "nested",
 			input: "{\"a\": \"ünicode\", \"b\"  : { \"list\": [true, [false, null, {  }]]}}",
 			expected: []Token{
@@ -166,7 +227,7 @@ func TestTokenizeValidInputs(t *testing.T) {
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
 			result := make([]Token, 0, 10)
-			tokenizer := NewTokenizer(strings.NewReader(tc.input))
+			tokenizer := NewTokenizer(strings.NewReader(tc.input), Config{})
 			go tokenizer.Tokenize()
 
 			for tokenResult := range tokenizer.GetTokenReadStream() {
@@ -191,24 +252,42 @@ func TestTokenizeInvalidInputs(t *testing.T) {
 	}{
 		{name: "invalidMinus",
 			input:    "{\"a\":-}",
-			expected: "invalid JSON. unexpected token } at line 1 column 7"},
+			expected: "invalid JSON. unexpected token } at line 1 column 7, expected digit"},
 		{name: "invalidDot",
 			input:    "{\"a\":.}",
-			expected: "invalid JSON. unexpected token . at line 1 column 6"},
+			expected: "invalid JSON. unexpected token . at line 1 column 6, expected value"},
 		{name: "invalidNumWithDot",
 			input:    "{\"a\":123.}",
-			expected: "invalid JSON. unexpected token } at line 1 column 10"},
+			expected: "invalid JSON. unexpected token } at line 1 column 10, expected digit"},
 		{name: "invalidNumber",
 			input:    "{\"a\":1.2.3}",
-			expected: "invalid JSON. unexpected token . at line 1 column 9"},
+			expected: "invalid JSON. unexpected token . at line 1 column 9, expected value"},
 		{name: "invalidText",
 			input:    "{\"a\":    truef}",
-			expected: "invalid JSON. unexpected token truef at line 1 column 10"},
+			expected: "invalid JSON. unexpected token truef at line 1 column 10, expected value"},
+		{name: "missingExponent",
+			input:    "[1e]",
+			expected: "invalid JSON. unexpected token ] at line 1 column 4, expected digit"},
+		{name: "invalidHexDigit",
+			input:    `"\u12g4"`,
+			expected: "invalid JSON. unexpected token g at line 1 column 6, expected hex digit"},
+		{name: "leadingZero",
+			input:    "[01]",
+			expected: "invalid JSON. unexpected token 1 at line 1 column 3, expected '.', 'e' or end of number"},
+		{name: "negativeLeadingZero",
+			input:    "-00.5",
+			expected: "invalid JSON. unexpected token 0 at line 1 column 3, expected '.', 'e' or end of number"},
+		{name: "nonLatinDigit",
+			input:    "1٣",
+			expected: "invalid JSON. unexpected token ٣ at line 1 column 2, expected value"},
+		{name: "unterminatedString",
+			input:    "{\"a\":\"1",
+			expected: "invalid JSON. Unexpected end of JSON input after line 1 column 6"},
 	}
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
-			tokenizer := NewTokenizer(strings.NewReader(tc.input))
+			tokenizer := NewTokenizer(strings.NewReader(tc.input), Config{})
 			go tokenizer.Tokenize()
 
 			var lastTokenResult TokenResult
@@ -225,3 +304,299 @@ func TestTokenizeInvalidInputs(t *testing.T) {
 		})
 	}
 }
+
+func TestTokenizeWithRecovery(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected []TokenResult
+	}{
+		{name: "invalidNumber",
+			input: "[-,1]",
+			expected: []TokenResult{
+				{Token: NewLeftBracketToken(1, 1)},
+				{Error: c.UnexpectedTokenErr{Token: ",", Line: 1, Column: 3, Expected: []string{"digit"}}},
+				{Token: NewCommaToken(1, 3)},
+				{Token: NewNumberToken("1", 1, 4)},
+				{Token: NewRightBracketToken(1, 5)}}},
+		{name: "invalidText",
+			input: "[truef,1]",
+			expected: []TokenResult{
+				{Token: NewLeftBracketToken(1, 1)},
+				{Error: c.UnexpectedTokenErr{Token: "truef", Line: 1, Column: 2, Expected: []string{"value"}}},
+				{Token: NewCommaToken(1, 7)},
+				{Token: NewNumberToken("1", 1, 8)},
+				{Token: NewRightBracketToken(1, 9)}}},
+		{name: "longText",
+			input: "[trueeeeeeeeé,1]",
+			expected: []TokenResult{
+				{Token: NewLeftBracketToken(1, 1)},
+				{Error: c.UnexpectedTokenErr{Token: "trueee", Line: 1, Column: 2, Expected: []string{"value"}}},
+				{Token: NewCommaToken(1, 14)},
+				{Token: NewNumberToken("1", 1, 15)},
+				{Token: NewRightBracketToken(1, 16)}}},
+		{name: "severalErrors",
+			input: "[1.2.3, nul]",
+			expected: []TokenResult{
+				{Token: NewLeftBracketToken(1, 1)},
+				{Token: NewNumberToken("1.2", 1, 2)},
+				{Error: c.UnexpectedTokenErr{Token: ".", Line: 1, Column: 5, Expected: []string{"value"}}},
+				{Token: NewNumberToken("3", 1, 6)},
+				{Token: NewCommaToken(1, 7)},
+				{Error: c.UnexpectedTokenErr{Token: "nul", Line: 1, Column: 9, Expected: []string{"value"}}},
+				{Token: NewRightBracketToken(1, 12)}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			result := make([]TokenResult, 0, 10)
+			tokenizer := NewTokenizer(strings.NewReader(tc.input), Config{Recover: true})
+			go tokenizer.Tokenize()
+
+			for tokenResult := range tokenizer.GetTokenReadStream() {
+				result = append(result, tokenResult)
+			}
+
+			if !reflect.DeepEqual(result, tc.expected) {
+				t.Errorf("Expected '%v', got '%v' instead\n", tc.expected, result)
+			}
+		})
+	}
+}
+
+func TestTokenizeLimits(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		config   Config
+		expected error
+	}{
+		{name: "stringLength",
+			input:    "[\"abc\", \"abcd\"]",
+			config:   Config{MaxStringLength: 3},
+			expected: c.StringLengthLimitErr{Limit: 3, Line: 1, Column: 9}},
+		{name: "numberLength",
+			input:    "[123, -1234]",
+			config:   Config{MaxNumberLength: 4},
+			expected: c.NumberLengthLimitErr{Limit: 4, Line: 1, Column: 7}},
+		{name: "tokenCount",
+			input:    "[1, 2, 3]",
+			config:   Config{MaxTokens: 4},
+			expected: c.TokenCountLimitErr{Limit: 4, Line: 1, Column: 6}},
+		{name: "inputSize",
+			input:    "[1, 2, 3]",
+			config:   Config{MaxInputBytes: 8},
+			expected: c.InputSizeLimitErr{Limit: 8}},
+		{name: "literalLength",
+			input:    "[t" + strings.Repeat("r", 100000),
+			expected: c.UnexpectedTokenErr{Token: "trrrrr", Line: 1, Column: 2, Expected: []string{"value"}}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tokenizer := NewTokenizer(strings.NewReader(tc.input), tc.config)
+			go tokenizer.Tokenize()
+
+			var lastTokenResult TokenResult
+
+			for tokenResult := range tokenizer.GetTokenReadStream() {
+				lastTokenResult = tokenResult
+			}
+
+			if !reflect.DeepEqual(lastTokenResult.Error, tc.expected) {
+				t.Errorf("Expected error '%v', got '%v' instead\n", tc.expected, lastTokenResult.Error)
+			}
+		})
+	}
+
+	tokenizer := NewTokenizer(strings.NewReader("[1, 2, 3]"), Config{MaxTokens: 7, MaxInputBytes: 9})
+	go tokenizer.Tokenize()
+
+	for tokenResult := range tokenizer.GetTokenReadStream() {
+		if tokenResult.Error != nil {
+			t.Errorf("Expected no error at the limits, got '%v'", tokenResult.Error)
+		}
+	}
+}
+
+func TestTokenizeStrict(t *testing.T) {
+	testCases := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{name: "unknownEscape",
+			input:    `"a\x"`,
+			expected: "invalid JSON. unexpected token \\x at line 1 column 3, expected escape sequence"},
+		{name: "controlCharacter",
+			input:    "\"a\tb\"",
+			expected: "invalid JSON. unexpected token '\\t' at line 1 column 3, expected escape sequence"},
+		{name: "loneHighSurrogate",
+			input:    `"a\ud800"`,
+			expected: "invalid JSON. unexpected token \\ud800 at line 1 column 3, expected surrogate pair"},
+		{name: "loneLowSurrogate",
+			input:    `"\udc00"`,
+			expected: "invalid JSON. unexpected token \\udc00 at line 1 column 2, expected surrogate pair"},
+		{name: "highSurrogateBeforeEscape",
+			input:    `"\ud83d\n"`,
+			expected: "invalid JSON. unexpected token \\ud83d at line 1 column 2, expected surrogate pair"},
+		{name: "highSurrogateBeforeLetter",
+			input:    `"\ud83d\u0041"`,
+			expected: "invalid JSON. unexpected token \\ud83d at line 1 column 2, expected surrogate pair"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tokenizer := NewTokenizer(strings.NewReader(tc.input), Config{Strict: true})
+			go tokenizer.Tokenize()
+
+			var lastTokenResult TokenResult
+
+			for tokenResult := range tokenizer.GetTokenReadStream() {
+				lastTokenResult = tokenResult
+			}
+
+			if lastTokenResult.Error == nil {
+				t.Errorf("Expected error %s but got %v", tc.expected, lastTokenResult.Token)
+			} else if lastTokenResult.Error.Error() != tc.expected {
+				t.Errorf("Expected error %s got %s", tc.expected, lastTokenResult.Error)
+			}
+		})
+	}
+
+	// the rest of an invalid string is skipped in recovery mode
+	tokenizer := NewTokenizer(strings.NewReader(`["a\qb", 1]`), Config{Strict: true, Recover: true})
+	go tokenizer.Tokenize()
+
+	expected := []TokenResult{
+		{Token: NewLeftBracketToken(1, 1)},
+		{Error: c.UnexpectedTokenErr{Token: "\\q", Line: 1, Column: 4, Expected: []string{"escape sequence"}}},
+		{Token: NewCommaToken(1, 8)},
+		{Token: NewNumberToken("1", 1, 10)},
+		{Token: NewRightBracketToken(1, 11)},
+	}
+
+	result := make([]TokenResult, 0, 5)
+
+	for tokenResult := range tokenizer.GetTokenReadStream() {
+		result = append(result, tokenResult)
+	}
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Errorf("Expected '%v', got '%v' instead\n", expected, result)
+	}
+}
+
+// benchmarkInput is a document of strings, numbers and literals, some
+// strings holding escapes and characters outside of ASCII.
+func benchmarkInput() string {
+	var input strings.Builder
+
+	input.WriteString("[")
+
+	for i := 0; i < 2000; i++ {
+		if i > 0 {
+			input.WriteString(",\n")
+		}
+		fmt.Fprintf(&input, `{"id": %d, "name": "user %d", "email": "user%d@example.com", "score": %d.%d, `+
+			`"active": %t, "manager": null, "bio": "café \"quoted\"\nnaïve", "tags": ["a", "b", "c"]}`,
+			i, i, i, i*7, i%100, i%2 == 0)
+	}
+
+	input.WriteString("]")
+	return input.String()
+}
+
+func BenchmarkTokenize(b *testing.B) {
+	input := benchmarkInput()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+
+	for i := 0; i < b.N; i++ {
+		tokenizer := NewTokenizer(strings.NewReader(input), Config{})
+		go tokenizer.Tokenize()
+
+		for range tokenizer.GetTokenReadStream() {
+		}
+	}
+}
+
+func BenchmarkScan(b *testing.B) {
+	input := benchmarkInput()
+
+	b.ReportAllocs()
+	b.SetBytes(int64(len(input)))
+
+	for i := 0; i < b.N; i++ {
+		scanner := NewScanner(strings.NewReader(input), Config{})
+
+		for {
+			if _, err := scanner.Scan(); err != nil {
+				break
+			}
+		}
+	}
+}
+
+func TestScanner(t *testing.T) {
+	long := strings.Repeat("abcé", 50)
+	input := `{"a": "x\ty", "` + long + `": [-1.5e3, true, null], "b": "` + long + `\n😀"} tru`
+
+	expected := []TokenResult{
+		{Token: NewLeftBraceToken(1, 1)},
+		{Token: NewStringToken("a", 1, 2)},
+		{Token: NewColonToken(1, 5)},
+		{Token: NewStringToken("x\ty", 1, 7)},
+		{Token: NewCommaToken(1, 13)},
+		{Token: NewStringToken(long, 1, 15)},
+		{Token: NewColonToken(1, 217)},
+		{Token: NewLeftBracketToken(1, 219)},
+		{Token: NewNumberToken("-1.5e3", 1, 220)},
+		{Token: NewCommaToken(1, 226)},
+		{Token: NewBooleanToken("true", 1, 228)},
+		{Token: NewCommaToken(1, 232)},
+		{Token: NewNullToken(1, 234)},
+		{Token: NewRightBracketToken(1, 238)},
+		{Token: NewCommaToken(1, 239)},
+		{Token: NewStringToken("b", 1, 241)},
+		{Token: NewColonToken(1, 244)},
+		{Token: NewStringToken(long+"\n😀", 1, 246)},
+		{Token: NewRightBraceToken(1, 451)},
+		{Error: c.UnexpectedTokenErr{Token: "tru", Line: 1, Column: 453, Expected: []string{"value"}}},
+	}
+
+	// input read a byte at a time through the smallest buffer, so that
+	// values straddle refills
+	readers := map[string]io.Reader{
+		"whole":   strings.NewReader(input),
+		"oneByte": iotest.OneByteReader(strings.NewReader(input)),
+	}
+
+	for name, reader := range readers {
+		t.Run(name, func(t *testing.T) {
+			scanner := NewScanner(reader, Config{BufferSize: 16})
+			result := make([]TokenResult, 0, len(expected))
+
+			for {
+				token, err := scanner.Scan()
+
+				if err == io.EOF {
+					break
+				}
+				if err != nil {
+					result = append(result, TokenResult{Error: err})
+					continue
+				}
+				if (token.IsString() || token.IsNumber()) && token.Value != "" {
+					t.Errorf("Expected the value in Bytes only, got '%v' instead\n", token.Value)
+				}
+				result = append(result, TokenResult{Token: token.Copy()})
+			}
+
+			if !reflect.DeepEqual(result, expected) {
+				t.Errorf("Expected '%v', got '%v' instead\n", expected, result)
+			}
+		})
+	}
+}